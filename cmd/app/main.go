@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/gorilla/mux"
 	delivery "github.com/tyriis/go-locking-service/internal/delivery/http/service"
+	"github.com/tyriis/go-locking-service/internal/domain"
 	"github.com/tyriis/go-locking-service/internal/infrastructure"
 	"github.com/tyriis/go-locking-service/internal/metrics"
 	"github.com/tyriis/go-locking-service/internal/repositories"
@@ -29,39 +32,168 @@ func main() {
 		log.Fatal("App.main - Failed to load config")
 	}
 
-	// initialize repository
-	redisHandler := infrastructure.NewRedisHandler(*config, logger)
-	lockRepo := repositories.NewLockRepository(redisHandler, logger)
+	namespaces, err := domain.ConfiguredNamespaces(config)
+	if err != nil {
+		log.Fatal("App.main - Invalid namespaces > " + err.Error())
+	}
+	webhooks, err := domain.ConfiguredWebhooks(config)
+	if err != nil {
+		log.Fatal("App.main - Invalid webhooks > " + err.Error())
+	}
+	history, err := domain.ConfiguredHistory(config)
+	if err != nil {
+		log.Fatal("App.main - Invalid history > " + err.Error())
+	}
 
-	// initialize use case
-	lockUseCase := usecases.NewLockUseCase(lockRepo, logger)
+	// initialize repository, the memory backend keeps everything in the process and the file backend
+	// in a database of the data dir. With redis nodes the locks are kept on all of them (Redlock)
+	// and everything else on the first one
+	var lockStore repositories.KVStoreHandler
+	var store interface {
+		repositories.SemaphoreStoreHandler
+		repositories.SessionStoreHandler
+		repositories.EventStoreHandler
+		repositories.WebhookStoreHandler
+		repositories.HistoryStoreHandler
+	}
+	// storeCloser is closed after the server shut down, so no request uses the store anymore
+	var storeCloser io.Closer
+	var redisHandler *infrastructure.RedisHandler
+	switch config.Backend {
+	case domain.BackendMemory:
+		memoryHandler := infrastructure.NewMemoryHandler(logger)
+		lockStore, store, storeCloser = memoryHandler, memoryHandler, memoryHandler
+	case domain.BackendFile:
+		boltHandler, err := infrastructure.NewBoltHandler(*config, logger)
+		if err != nil {
+			log.Fatal("App.main - Invalid data dir > " + err.Error())
+		}
+		lockStore, store, storeCloser = boltHandler, boltHandler, boltHandler
+	default:
+		var notifier interface {
+			EnableKeyspaceNotifications(ctx context.Context) error
+		}
+		if len(config.Redis.Nodes) > 0 {
+			redlockHandler, err := infrastructure.NewRedlockHandler(*config, logger)
+			if err != nil {
+				log.Fatal("App.main - Invalid redis config > " + err.Error())
+			}
+			redisHandler, lockStore, notifier, storeCloser = redlockHandler.Node(0), redlockHandler, redlockHandler, redlockHandler
+			logger.Warn("App.main - with redis nodes locks can't be bound to sessions and everything but the locks is kept on the first node only, it remains a single point of failure for semaphores, sessions, events, webhooks and the history")
+		} else {
+			redisHandler, err = infrastructure.NewRedisHandler(*config, logger)
+			if err != nil {
+				log.Fatal("App.main - Invalid redis config > " + err.Error())
+			}
+			lockStore, notifier, storeCloser = redisHandler, redisHandler, redisHandler
+		}
+		store = redisHandler
+		if err := notifier.EnableKeyspaceNotifications(context.Background()); err != nil {
+			logger.Warn("App.main - keyspace notifications could not be enabled, unless the server has them enabled already (see redis.skipNotifySetup) waiting requests only retry when the held lock expires and expired locks are not reported > " + err.Error())
+		}
+	}
+	lockRepo := repositories.NewLockRepository(lockStore, logger)
+	semaphoreRepo := repositories.NewSemaphoreRepository(store, logger)
+	sessionRepo := repositories.NewSessionRepository(store, logger)
+	eventRepo := repositories.NewEventRepository(store, logger)
+	webhookRepo := repositories.NewWebhookRepository(store, logger)
+	historyRepo := repositories.NewHistoryRepository(store, history, logger)
 
-	// initialize http handler
-	webserviceHandler := delivery.NewWebserviceHandler(lockUseCase, logger)
+	// initialize use case
+	lockUseCase := usecases.NewLockUseCase(lockRepo, eventRepo, historyRepo, logger)
+	semaphoreUseCase := usecases.NewSemaphoreUseCase(semaphoreRepo, logger)
+	sessionUseCase := usecases.NewSessionUseCase(sessionRepo, logger)
+	adminTokens := config.Admin.Tokens
+	if config.Admin.Token.Value != "" {
+		adminTokens = append(adminTokens, domain.AdminToken{Name: "admin", Token: config.Admin.Token})
+	}
+	adminUseCase := usecases.NewAdminUseCase(lockRepo, eventRepo, historyRepo, adminTokens, logger)
+	eventUseCase := usecases.NewEventUseCase(eventRepo, logger)
+	webhookUseCase := usecases.NewWebhookUseCase(webhookRepo, eventRepo, infrastructure.NewHTTPWebhookClient(), webhooks, namespaces, logger)
 
 	// initialize metrics service and middleware
 	metricsService := metrics.NewPrometheusMetricsService()
 	metricsMiddleware := metrics.NewMetricsMiddleware(metricsService)
+	expiryUseCase := usecases.NewExpiryUseCase(lockRepo, eventRepo, historyRepo, metricsService, namespaces, logger)
+
+	// start delivering the lock events to the webhooks, reporting the expired locks and the Redis failovers
+	subscribersCtx, stopSubscribers := context.WithCancel(context.Background())
+	if err := webhookUseCase.Start(subscribersCtx); err != nil {
+		logger.Error("App.main - webhooks do not receive lock events > " + err.Error())
+	}
+	if err := expiryUseCase.Start(subscribersCtx); err != nil {
+		logger.Error("App.main - expired locks are not reported > " + err.Error())
+	}
+	if redisHandler != nil {
+		redisHandler.WatchFailovers(subscribersCtx, metricsService)
+	}
+
+	// initialize http handler
+	webserviceHandler := delivery.NewWebserviceHandler(lockUseCase, semaphoreUseCase, sessionUseCase, adminUseCase, eventUseCase, webhookUseCase, logger)
+	namespaceMiddleware := delivery.NewNamespaceMiddleware(namespaces, webserviceHandler)
+	clientIPMiddleware, err := delivery.NewClientIPMiddleware(config.Api.TrustedProxies)
+	if err != nil {
+		log.Fatal("App.main - Invalid trusted proxies > " + err.Error())
+	}
 
 	// Initialize and start metrics updater
-	metricsUpdater := metrics.NewMetricsUpdater(lockRepo, metricsService, logger)
+	metricsUpdater := metrics.NewMetricsUpdater(lockRepo, metricsService, namespaces, logger)
 	metricsUpdater.Start()
 
 	r := mux.NewRouter()
+	r.Use(clientIPMiddleware.Middleware)
 
 	// Apply metrics middleware to all routes
 	r.Handle("/metrics", delivery.MetricsHandler())
-	r.Handle("/api/v1/locks", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.CreateLock))).Methods("POST")
-	r.Handle("/api/v1/locks/{key}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.DeleteLock))).Methods("DELETE")
-	r.Handle("/api/v1/locks/{key}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowOneLock))).Methods("GET")
-	r.Handle("/api/v1/locks", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowAllLocks))).Methods("GET")
+
+	// The lock routes serve the default namespace and every namespace below /api/v1/namespaces/{ns}
+	lockHandler := func(handler http.HandlerFunc) http.Handler {
+		return metricsMiddleware.Middleware(namespaceMiddleware.Middleware(handler))
+	}
+	for _, prefix := range []string{"/api/v1", "/api/v1/namespaces/{ns}"} {
+		r.Handle(prefix+"/locks", lockHandler(webserviceHandler.CreateLock)).Methods("POST")
+		r.Handle(prefix+"/locks:batchAcquire", lockHandler(webserviceHandler.CreateLocks)).Methods("POST")
+		r.Handle(prefix+"/locks/{key}", lockHandler(webserviceHandler.DeleteLock)).Methods("DELETE")
+		r.Handle(prefix+"/locks/{key}", lockHandler(webserviceHandler.RenewLock)).Methods("PATCH")
+		r.Handle(prefix+"/locks/{key}", lockHandler(webserviceHandler.ShowOneLock)).Methods("GET")
+		r.Handle(prefix+"/locks", lockHandler(webserviceHandler.ShowAllLocks)).Methods("GET")
+		r.Handle(prefix+"/locks/{key}/holders", lockHandler(webserviceHandler.ShowLockHolders)).Methods("GET")
+		r.Handle(prefix+"/locks/{key}/queue", lockHandler(webserviceHandler.ShowLockQueue)).Methods("GET")
+		r.Handle(prefix+"/locks/{key}/history", lockHandler(webserviceHandler.ShowLockHistory)).Methods("GET")
+		r.Handle(prefix+"/locks/{key}/handoff", lockHandler(webserviceHandler.HandoffLock)).Methods("POST")
+		r.Handle(prefix+"/locks/{key}/takeover", lockHandler(webserviceHandler.TakeoverLock)).Methods("POST")
+		r.Handle(prefix+"/locks/{key}/forceRelease", lockHandler(webserviceHandler.ForceReleaseLock)).Methods("POST")
+		r.Handle(prefix+"/events", lockHandler(webserviceHandler.StreamEvents)).Methods("GET")
+	}
+
+	r.Handle("/api/v1/semaphores", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.CreateSemaphore))).Methods("POST")
+	r.Handle("/api/v1/semaphores", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowAllSemaphores))).Methods("GET")
+	r.Handle("/api/v1/semaphores/{key}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowOneSemaphore))).Methods("GET")
+	r.Handle("/api/v1/semaphores/{key}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.DeleteSemaphore))).Methods("DELETE")
+	r.Handle("/api/v1/semaphores/{key}/permits", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.AcquirePermit))).Methods("POST")
+	r.Handle("/api/v1/semaphores/{key}/permits", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ReleasePermit))).Methods("DELETE")
+	r.Handle("/api/v1/sessions", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.CreateSession))).Methods("POST")
+	r.Handle("/api/v1/sessions/{id}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowOneSession))).Methods("GET")
+	r.Handle("/api/v1/sessions/{id}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.DeleteSession))).Methods("DELETE")
+	r.Handle("/api/v1/sessions/{id}/heartbeat", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.RenewSession))).Methods("PUT")
+	r.Handle("/api/v1/webhooks", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.CreateWebhook))).Methods("POST")
+	r.Handle("/api/v1/webhooks", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowAllWebhooks))).Methods("GET")
+	r.Handle("/api/v1/webhooks/{id}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowOneWebhook))).Methods("GET")
+	r.Handle("/api/v1/webhooks/{id}", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.DeleteWebhook))).Methods("DELETE")
+	r.Handle("/api/v1/webhooks/{id}/deliveries", metricsMiddleware.Middleware(http.HandlerFunc(webserviceHandler.ShowWebhookDeliveries))).Methods("GET")
 
 	logger.Info("App.main - Server is running on http://" + config.Api.Host + ":" + config.Api.Port)
 
+	// Requests run in a context that is cancelled on shutdown, so open event streams end
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
 	srv := &http.Server{
-		Addr:    fmt.Sprintf("%s:%s", config.Api.Host, config.Api.Port),
-		Handler: r,
+		Addr:              fmt.Sprintf("%s:%s", config.Api.Host, config.Api.Port),
+		Handler:           r,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		ReadHeaderTimeout: delivery.ReadHeaderTimeout,
+		WriteTimeout:      delivery.WriteTimeout,
 	}
+	srv.RegisterOnShutdown(cancelRequests)
 
 	// Graceful shutdown
 	go func() {
@@ -75,13 +207,17 @@ func main() {
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
-	// Stop metrics updater before shutting down
+	// Stop metrics updater, webhook deliveries and expiry reports before shutting down
 	metricsUpdater.Stop()
+	stopSubscribers()
 
 	// Shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("App.main - Server forced to shutdown:", err)
+		logger.Error("App.main - Server forced to shutdown > " + err.Error())
+	}
+	if err := storeCloser.Close(); err != nil {
+		logger.Error("App.main - storeCloser.Close > " + err.Error())
 	}
 }
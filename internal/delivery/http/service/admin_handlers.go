@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// adminCredential reads the bearer token of the Authorization header.
+func adminCredential(req *http.Request) string {
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+}
+
+// decodeAdminInput authorizes an admin request and decodes its input,
+// the actor of the change is the name of the admin credential.
+func (h WebserviceHandler) decodeAdminInput(req *http.Request, takeover bool) (*domain.LockAdminInput, error) {
+	actor, err := h.AdminUseCase.Authorize(adminCredential(req))
+	if err != nil {
+		return nil, err
+	}
+	var input domain.LockAdminInput
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return nil, &domain.InputError{Message: "WebserviceHandler.decodeAdminInput - json.Decode >"}
+	}
+	if err := domain.ValidateLockAdminInput(&input, takeover); err != nil {
+		return nil, err
+	}
+	input.Actor = actor
+	return &input, nil
+}
+
+/**
+ * TakeoverLock handles admin POST requests to reassign a held lock to a new owner.
+ */
+func (h WebserviceHandler) TakeoverLock(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.TakeoverLock - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	input, err := h.decodeAdminInput(req, true)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	lock, err := h.AdminUseCase.TakeoverLock(req.Context(), key, input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(lock).Data)
+	h.logger.Debug("WebserviceHandler.TakeoverLock - END")
+}
+
+/**
+ * ForceReleaseLock handles admin POST requests to release a held lock regardless of its owner.
+ */
+func (h WebserviceHandler) ForceReleaseLock(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ForceReleaseLock - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	input, err := h.decodeAdminInput(req, false)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := h.AdminUseCase.ForceReleaseLock(req.Context(), key, input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(nil).Data)
+	h.logger.Debug("WebserviceHandler.ForceReleaseLock - END")
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// ClientIPMiddleware adds the address of the client to the request context, so the lock history can name it.
+type ClientIPMiddleware struct {
+	// trusted are the proxies whose X-Forwarded-For header is believed
+	trusted []*net.IPNet
+}
+
+// NewClientIPMiddleware creates a new ClientIPMiddleware that trusts the X-Forwarded-For header of the proxies,
+// given as addresses or CIDR ranges. It fails if one of them is neither.
+func NewClientIPMiddleware(trustedProxies []string) (*ClientIPMiddleware, error) {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				const msg = "NewClientIPMiddleware - invalid address %q"
+				return nil, fmt.Errorf(msg, proxy)
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			const msg = "NewClientIPMiddleware - net.ParseCIDR > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		trusted = append(trusted, network)
+	}
+	return &ClientIPMiddleware{trusted: trusted}, nil
+}
+
+/**
+ * Middleware adds the client address of the request to its context. The client is the direct peer,
+ * unless that is a trusted proxy. Then it is the last address of the X-Forwarded-For header that is not
+ * a trusted proxy, as the addresses before were added by the client and may be forged.
+ */
+func (m *ClientIPMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(res, req.WithContext(domain.WithClientIP(req.Context(), m.clientIP(req))))
+	})
+}
+
+// clientIP returns the address of the client of the request.
+func (m *ClientIPMiddleware) clientIP(req *http.Request) string {
+	peer, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		peer = req.RemoteAddr
+	}
+	if !m.isTrusted(peer) {
+		return peer
+	}
+	var forwarded []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	client := peer
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		client = strings.TrimSpace(forwarded[i])
+		if !m.isTrusted(client) {
+			break
+		}
+	}
+	return client
+}
+
+// isTrusted reports whether the address is one of the trusted proxies.
+func (m *ClientIPMiddleware) isTrusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range m.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
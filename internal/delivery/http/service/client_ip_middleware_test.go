@@ -0,0 +1,139 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+func TestClientIPMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   []string
+		peer      string
+		forwarded []string
+		expected  string
+	}{
+		{
+			name:     "NoProxy",
+			trusted:  []string{"10.0.0.1"},
+			peer:     "192.0.2.7:41000",
+			expected: "192.0.2.7",
+		},
+		{
+			name:      "UntrustedPeerIsNotBelieved",
+			trusted:   []string{"10.0.0.1"},
+			peer:      "192.0.2.7:41000",
+			forwarded: []string{"198.51.100.9"},
+			expected:  "192.0.2.7",
+		},
+		{
+			name:      "TrustedPeer",
+			trusted:   []string{"10.0.0.1"},
+			peer:      "10.0.0.1:41000",
+			forwarded: []string{"198.51.100.9"},
+			expected:  "198.51.100.9",
+		},
+		{
+			name:     "TrustedPeerWithoutHeader",
+			trusted:  []string{"10.0.0.1"},
+			peer:     "10.0.0.1:41000",
+			expected: "10.0.0.1",
+		},
+		{
+			name:      "TrustedPeerInRange",
+			trusted:   []string{"10.0.0.0/8"},
+			peer:      "10.1.2.3:41000",
+			forwarded: []string{"198.51.100.9"},
+			expected:  "198.51.100.9",
+		},
+		{
+			name:      "SingleAddressIsNoRange",
+			trusted:   []string{"10.0.0.1"},
+			peer:      "10.0.0.2:41000",
+			forwarded: []string{"198.51.100.9"},
+			expected:  "10.0.0.2",
+		},
+		{
+			name:      "ForgedLeftmostEntry",
+			trusted:   []string{"10.0.0.1"},
+			peer:      "10.0.0.1:41000",
+			forwarded: []string{"203.0.113.66, 198.51.100.9"},
+			expected:  "198.51.100.9",
+		},
+		{
+			name:      "ChainOfTrustedProxies",
+			trusted:   []string{"10.0.0.0/8", "172.16.0.5"},
+			peer:      "10.0.0.1:41000",
+			forwarded: []string{"203.0.113.66, 198.51.100.9, 172.16.0.5, 10.2.0.1"},
+			expected:  "198.51.100.9",
+		},
+		{
+			name:      "MultipleHeaderValues",
+			trusted:   []string{"10.0.0.0/8"},
+			peer:      "10.0.0.1:41000",
+			forwarded: []string{"203.0.113.66, 198.51.100.9", "10.2.0.1"},
+			expected:  "198.51.100.9",
+		},
+		{
+			name:      "OnlyTrustedProxies",
+			trusted:   []string{"10.0.0.0/8"},
+			peer:      "10.0.0.1:41000",
+			forwarded: []string{"10.3.0.1, 10.2.0.1"},
+			expected:  "10.3.0.1",
+		},
+		{
+			name:      "IPv6",
+			trusted:   []string{"fd00::/8"},
+			peer:      "[fd00::1]:41000",
+			forwarded: []string{"2001:db8::7"},
+			expected:  "2001:db8::7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			middleware, err := NewClientIPMiddleware(tt.trusted)
+			assert.NoError(t, err)
+			var clientIP string
+			handler := middleware.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				clientIP = domain.ClientIPFromContext(req.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/locks", nil)
+			req.RemoteAddr = tt.peer
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			// Act
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// Assert
+			assert.Equal(t, tt.expected, clientIP)
+		})
+	}
+}
+
+func TestNewClientIPMiddlewareInvalidProxy(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy string
+	}{
+		{name: "InvalidAddress", proxy: "proxy.local"},
+		{name: "InvalidRange", proxy: "10.0.0.0/33"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			middleware, err := NewClientIPMiddleware([]string{tt.proxy})
+
+			// Assert
+			assert.Error(t, err)
+			assert.Nil(t, middleware)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// eventKeepaliveInterval is the time after which an idle event stream sends a comment,
+// so proxies do not close the connection.
+const eventKeepaliveInterval = 15 * time.Second
+
+/**
+ * StreamEvents handles GET requests for a Server-Sent Events stream of lock events.
+ * The "prefix" and "owner" query parameters filter the events by key prefix and owner.
+ */
+func (h WebserviceHandler) StreamEvents(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.StreamEvents - START")
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		h.handleError(res, fmt.Errorf("WebserviceHandler.StreamEvents - streaming is not supported"))
+		return
+	}
+
+	filter := &domain.LockEventFilter{
+		KeyPrefix: req.URL.Query().Get("prefix"),
+		Owner:     req.URL.Query().Get("owner"),
+	}
+	events, err := h.EventUseCase.StreamEvents(req.Context(), filter)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	// the stream stays open until the client goes away, so it is exempt from the WriteTimeout
+	if err := http.NewResponseController(res).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("WebserviceHandler.StreamEvents - SetWriteDeadline > " + err.Error())
+	}
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			h.logger.Debug("WebserviceHandler.StreamEvents - END")
+			return
+		case <-keepalive.C:
+			fmt.Fprint(res, ": keepalive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				h.logger.Debug("WebserviceHandler.StreamEvents - END")
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Error("WebserviceHandler.StreamEvents - json.Marshal > " + err.Error())
+				continue
+			}
+			fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
@@ -3,7 +3,11 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/tyriis/go-locking-service/internal/domain"
@@ -23,13 +27,22 @@ func (h WebserviceHandler) respondWithError(res http.ResponseWriter, status int,
 	h.respondWithJSON(res, status, domain.NewErrorResponse(status, message).Error)
 }
 
+// decodeJSON decodes the JSON body of the request into input, a malformed body is a validation error.
+func decodeJSON(req *http.Request, input interface{}) error {
+	if err := json.NewDecoder(req.Body).Decode(input); err != nil {
+		return domain.NewValidationError("INVALID_JSON", fmt.Sprintf("request body is not valid JSON: %s", err))
+	}
+	return nil
+}
+
 /**
  * CreateLock handles POST requests to create a new lock.
+ * With a "wait" duration the request is held open until the lock is acquired or the wait passed.
  */
 func (h WebserviceHandler) CreateLock(res http.ResponseWriter, req *http.Request) {
 	h.logger.Debug("WebserviceHandler.CreateLock - START")
 	var input domain.LockInput
-	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+	if err := decodeJSON(req, &input); err != nil {
 		h.handleError(res, err)
 		return
 	}
@@ -38,8 +51,13 @@ func (h WebserviceHandler) CreateLock(res http.ResponseWriter, req *http.Request
 		h.handleError(res, err)
 		return
 	}
+	if wait, _ := time.ParseDuration(input.Wait); wait > 0 {
+		if err := http.NewResponseController(res).SetWriteDeadline(time.Now().Add(wait + WriteTimeout)); err != nil {
+			h.logger.Warn("WebserviceHandler.CreateLock - SetWriteDeadline > " + err.Error())
+		}
+	}
 
-	lock, err := h.LockUseCase.CreateLock(&input)
+	lock, err := h.LockUseCase.CreateLock(req.Context(), &input)
 	if err != nil {
 		h.handleError(res, err)
 		return
@@ -50,14 +68,72 @@ func (h WebserviceHandler) CreateLock(res http.ResponseWriter, req *http.Request
 }
 
 /**
- * DeleteLock handles DELETE requests to remove an existing lock.
+ * CreateLocks handles POST requests to acquire the locks of several keys at once, all of them or none.
+ */
+func (h WebserviceHandler) CreateLocks(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.CreateLocks - START")
+	var input domain.LockBatchInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := domain.ValidateLockBatchInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	locks, err := h.LockUseCase.CreateLocks(req.Context(), &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusCreated, domain.NewSuccessResponse(locks).Data)
+	h.logger.Debug("WebserviceHandler.CreateLocks - END")
+}
+
+// ownerHeader is the request header that can carry the lock owner.
+const ownerHeader = "X-Lock-Owner"
+
+// ownerFromQueryOrHeader reads the lock owner from the "owner" query parameter or the X-Lock-Owner header.
+func ownerFromQueryOrHeader(req *http.Request) string {
+	if owner := req.URL.Query().Get("owner"); owner != "" {
+		return owner
+	}
+	return req.Header.Get(ownerHeader)
+}
+
+// ownerFromRequest reads the lock owner from the "owner" query parameter,
+// the X-Lock-Owner header or an {"owner": "..."} JSON body, in that order.
+func ownerFromRequest(req *http.Request) (string, error) {
+	if owner := ownerFromQueryOrHeader(req); owner != "" {
+		return owner, nil
+	}
+	var input struct {
+		Owner string `json:"owner"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil && err != io.EOF {
+		return "", &domain.InputError{Message: "WebserviceHandler.ownerFromRequest - json.Decode >"}
+	}
+	return input.Owner, nil
+}
+
+/**
+ * DeleteLock handles DELETE requests to release an existing lock held by the requesting owner.
  */
 func (h WebserviceHandler) DeleteLock(res http.ResponseWriter, req *http.Request) {
 	h.logger.Debug("WebserviceHandler.DeleteLock - START")
 	vars := mux.Vars(req)
 	key := vars["key"]
 
-	if err := h.LockUseCase.DeleteLock(key); err != nil {
+	owner, err := ownerFromRequest(req)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := h.LockUseCase.DeleteLock(req.Context(), key, owner); err != nil {
 		h.handleError(res, err)
 		return
 	}
@@ -67,13 +143,77 @@ func (h WebserviceHandler) DeleteLock(res http.ResponseWriter, req *http.Request
 }
 
 /**
- * ShowOneLock handles GET requests to retrieve a specific lock.
+ * HandoffLock handles POST requests to pass a lock held by the requesting owner on to a new owner.
+ */
+func (h WebserviceHandler) HandoffLock(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.HandoffLock - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	var input domain.LockHandoffInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	if input.Owner == "" {
+		input.Owner = ownerFromQueryOrHeader(req)
+	}
+
+	if err := domain.ValidateLockHandoffInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	lock, err := h.LockUseCase.HandoffLock(req.Context(), key, &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(lock).Data)
+	h.logger.Debug("WebserviceHandler.HandoffLock - END")
+}
+
+/**
+ * RenewLock handles PATCH requests to extend the TTL of a lock held by the requesting owner.
+ */
+func (h WebserviceHandler) RenewLock(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.RenewLock - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	var input domain.LockRenewInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	if input.Owner == "" {
+		input.Owner = ownerFromQueryOrHeader(req)
+	}
+
+	if err := domain.ValidateLockRenewInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	lock, err := h.LockUseCase.RenewLock(req.Context(), key, &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(lock).Data)
+	h.logger.Debug("WebserviceHandler.RenewLock - END")
+}
+
+/**
+ * ShowOneLock handles GET requests to retrieve a specific lock, a shared lock is represented by its first holder.
  */
 func (h WebserviceHandler) ShowOneLock(res http.ResponseWriter, req *http.Request) {
 	h.logger.Debug("WebserviceHandler.ShowOneLock - START")
 	vars := mux.Vars(req)
 	key := vars["key"]
-	lock, err := h.LockUseCase.GetLock(key)
+	lock, err := h.LockUseCase.GetLock(req.Context(), key)
 	if err != nil {
 		h.handleError(res, err)
 		return
@@ -83,12 +223,90 @@ func (h WebserviceHandler) ShowOneLock(res http.ResponseWriter, req *http.Reques
 	h.logger.Debug("WebserviceHandler.ShowOneLock - END")
 }
 
+/**
+ * ShowLockHolders handles GET requests to retrieve every holder of a specific lock.
+ */
+func (h WebserviceHandler) ShowLockHolders(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowLockHolders - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+	locks, err := h.LockUseCase.GetLockHolders(req.Context(), key)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(locks).Data)
+	h.logger.Debug("WebserviceHandler.ShowLockHolders - END")
+}
+
+/**
+ * ShowLockQueue handles GET requests to retrieve the owners waiting for a lock.
+ */
+func (h WebserviceHandler) ShowLockQueue(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowLockQueue - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+	entries, err := h.LockUseCase.GetLockQueue(req.Context(), key)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(entries).Data)
+	h.logger.Debug("WebserviceHandler.ShowLockQueue - END")
+}
+
+/**
+ * ShowLockHistory handles GET requests to retrieve the audit history of a lock,
+ * the optional "from" and "to" query parameters limit it to a time range (RFC 3339).
+ */
+func (h WebserviceHandler) ShowLockHistory(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowLockHistory - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	from, err := timeFromQuery(req, "from")
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+	to, err := timeFromQuery(req, "to")
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	entries, err := h.LockUseCase.GetLockHistory(req.Context(), key, from, to)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(entries).Data)
+	h.logger.Debug("WebserviceHandler.ShowLockHistory - END")
+}
+
+// timeFromQuery reads the RFC 3339 time of the query parameter name, it is zero if the parameter is missing.
+func timeFromQuery(req *http.Request, name string) (time.Time, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		const msg = "WebserviceHandler.timeFromQuery - %s is not a RFC 3339 time >"
+		return time.Time{}, &domain.InputError{Message: fmt.Sprintf(msg, name)}
+	}
+	return t, nil
+}
+
 /**
  * ShowAllLocks handles GET requests to retrieve all locks.
  */
 func (h WebserviceHandler) ShowAllLocks(res http.ResponseWriter, req *http.Request) {
 	h.logger.Debug("WebserviceHandler.ShowAllLocks - START")
-	locks, err := h.LockUseCase.ListLocks()
+	locks, err := h.LockUseCase.ListLocks(req.Context())
 	if err != nil {
 		h.handleError(res, err)
 		return
@@ -102,11 +320,30 @@ func (h WebserviceHandler) handleError(res http.ResponseWriter, err error) {
 	h.logger.Error(err.Error())
 	switch e := err.(type) {
 	case *domain.LockConflictError:
+		if len(e.Keys) > 0 {
+			h.respondWithError(res, http.StatusConflict, "locks already exist: "+strings.Join(e.Keys, ", "))
+			return
+		}
 		h.respondWithError(res, http.StatusConflict, "lock already exists!")
+	case *domain.LockOwnerMismatchError:
+		h.respondWithError(res, http.StatusForbidden, "lock is held by another owner!")
+	case *domain.LockNotHeldError:
+		h.respondWithError(res, http.StatusNotFound, "lock is not held!")
+	case *domain.LockLostError:
+		response := domain.NewErrorResponse(http.StatusConflict, "lock was lost!").Error
+		response.Code = "LOCK_LOST"
+		response.Details = e
+		h.respondWithJSON(res, http.StatusConflict, response)
+	case *domain.SemaphoreExhaustedError:
+		h.respondWithError(res, http.StatusConflict, "no permits available!")
+	case *domain.UnauthorizedError:
+		h.respondWithError(res, http.StatusUnauthorized, "admin credential required!")
 	case *domain.NotFoundError:
 		h.respondWithError(res, http.StatusNotFound, "not found")
 	case *domain.InputError:
 		h.respondWithError(res, http.StatusBadRequest, e.Error())
+	case *domain.ValidationError:
+		h.respondWithError(res, http.StatusBadRequest, e.Error())
 	default:
 		h.respondWithError(res, http.StatusInternalServerError, "An unexpected error occurred")
 	}
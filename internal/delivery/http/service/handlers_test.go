@@ -0,0 +1,253 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+	"github.com/tyriis/go-locking-service/internal/usecases"
+)
+
+const testAdminToken = "admin-secret"
+
+// testNamespaces are the namespaces of the test router, the default one and team-a.
+var testNamespaces = []*domain.Namespace{{Name: domain.DefaultNamespace}, {Name: "team-a"}}
+
+// newTestRouter routes the lock, admin and webhook requests like the app does, the lock use cases run on lockRepo.
+// Without tokens the admin endpoints are disabled.
+func newTestRouter(lockRepo *repositories.MockLockRepository, tokens []domain.AdminToken) *mux.Router {
+	logger := infrastructure.NewMockLogger()
+	eventRepo := new(repositories.MockEventRepository)
+	eventRepo.On("Publish", mock.Anything, mock.Anything).Return(nil)
+	historyRepo := new(repositories.MockHistoryRepository)
+	historyRepo.On("Append", mock.Anything, mock.Anything).Return(nil)
+	handler := NewWebserviceHandler(
+		usecases.NewLockUseCase(lockRepo, eventRepo, historyRepo, logger),
+		nil,
+		nil,
+		usecases.NewAdminUseCase(lockRepo, eventRepo, historyRepo, tokens, logger),
+		nil,
+		usecases.NewWebhookUseCase(new(repositories.MockWebhookRepository), eventRepo, nil, nil, testNamespaces, logger),
+		logger,
+	)
+	namespaceMiddleware := NewNamespaceMiddleware(testNamespaces, handler)
+
+	r := mux.NewRouter()
+	for _, prefix := range []string{"/api/v1", "/api/v1/namespaces/{ns}"} {
+		r.Handle(prefix+"/locks", namespaceMiddleware.Middleware(http.HandlerFunc(handler.CreateLock))).Methods("POST")
+		r.Handle(prefix+"/locks:batchAcquire", namespaceMiddleware.Middleware(http.HandlerFunc(handler.CreateLocks))).Methods("POST")
+		r.Handle(prefix+"/locks/{key}", namespaceMiddleware.Middleware(http.HandlerFunc(handler.DeleteLock))).Methods("DELETE")
+		r.Handle(prefix+"/locks/{key}", namespaceMiddleware.Middleware(http.HandlerFunc(handler.RenewLock))).Methods("PATCH")
+		r.Handle(prefix+"/locks/{key}/handoff", namespaceMiddleware.Middleware(http.HandlerFunc(handler.HandoffLock))).Methods("POST")
+		r.Handle(prefix+"/locks/{key}/takeover", namespaceMiddleware.Middleware(http.HandlerFunc(handler.TakeoverLock))).Methods("POST")
+		r.Handle(prefix+"/locks/{key}/forceRelease", namespaceMiddleware.Middleware(http.HandlerFunc(handler.ForceReleaseLock))).Methods("POST")
+	}
+	r.HandleFunc("/api/v1/webhooks", handler.CreateWebhook).Methods("POST")
+	r.HandleFunc("/api/v1/webhooks", handler.ShowAllWebhooks).Methods("GET")
+	r.HandleFunc("/api/v1/webhooks/{id}", handler.ShowOneWebhook).Methods("GET")
+	r.HandleFunc("/api/v1/webhooks/{id}", handler.DeleteWebhook).Methods("DELETE")
+	r.HandleFunc("/api/v1/webhooks/{id}/deliveries", handler.ShowWebhookDeliveries).Methods("GET")
+	return r
+}
+
+// serve sends the request to the router and decodes the error of the response, if it has one.
+func serve(t *testing.T, r *mux.Router, req *http.Request) (*httptest.ResponseRecorder, *domain.APIError) {
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	if res.Code < http.StatusBadRequest {
+		return res, nil
+	}
+	var apiErr domain.APIError
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &apiErr))
+	return res, &apiErr
+}
+
+func TestInvalidJSONBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{name: "CreateLock", method: http.MethodPost, path: "/api/v1/locks"},
+		{name: "CreateLocks", method: http.MethodPost, path: "/api/v1/locks:batchAcquire"},
+		{name: "RenewLock", method: http.MethodPatch, path: "/api/v1/locks/deploy"},
+		{name: "HandoffLock", method: http.MethodPost, path: "/api/v1/locks/deploy/handoff"},
+		{name: "NamespacedCreateLock", method: http.MethodPost, path: "/api/v1/namespaces/team-a/locks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			lockRepo := new(repositories.MockLockRepository)
+			r := newTestRouter(lockRepo, nil)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"owner": "ci",`))
+
+			// Act
+			res, apiErr := serve(t, r, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, res.Code)
+			assert.True(t, strings.HasPrefix(apiErr.Message, "INVALID_JSON: "), apiErr.Message)
+			lockRepo.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			lockRepo.AssertNotCalled(t, "Renew", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestLockLost(t *testing.T) {
+	expiredAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lostErr := &domain.LockLostError{Message: "LockRepository.Release(deploy) >", Owners: []string{"ci-2"}, ExpiredAt: expiredAt}
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		arrange func(lockRepo *repositories.MockLockRepository)
+	}{
+		{
+			name:   "DeleteLock",
+			method: http.MethodDelete,
+			path:   "/api/v1/locks/deploy?owner=ci-1",
+			arrange: func(lockRepo *repositories.MockLockRepository) {
+				lockRepo.On("Release", mock.Anything, "deploy", "ci-1").Return(lostErr)
+			},
+		},
+		{
+			name:   "RenewLock",
+			method: http.MethodPatch,
+			path:   "/api/v1/locks/deploy",
+			body:   `{"owner": "ci-1", "duration": "1m"}`,
+			arrange: func(lockRepo *repositories.MockLockRepository) {
+				lockRepo.On("Renew", mock.Anything, "deploy", "ci-1", time.Minute).Return(nil, lostErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			lockRepo := new(repositories.MockLockRepository)
+			tt.arrange(lockRepo)
+			r := newTestRouter(lockRepo, nil)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+
+			// Act
+			res, apiErr := serve(t, r, req)
+
+			// Assert
+			assert.Equal(t, http.StatusConflict, res.Code)
+			assert.Equal(t, "LOCK_LOST", apiErr.Code)
+			assert.Equal(t, http.StatusConflict, apiErr.Status)
+			assert.Equal(t, map[string]interface{}{"owners": []interface{}{"ci-2"}, "expiredAt": "2024-05-01T12:00:00Z"}, apiErr.Details)
+			lockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminRoutesUnauthorized(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPost, path: "/api/v1/locks/deploy/takeover", body: `{"owner": "ops", "duration": "1m"}`},
+		{method: http.MethodPost, path: "/api/v1/locks/deploy/forceRelease", body: `{}`},
+		{method: http.MethodPost, path: "/api/v1/webhooks", body: `{"url": "https://chatops.example.com/locks", "keyPattern": "*"}`},
+		{method: http.MethodGet, path: "/api/v1/webhooks"},
+		{method: http.MethodGet, path: "/api/v1/webhooks/chatops"},
+		{method: http.MethodDelete, path: "/api/v1/webhooks/chatops"},
+		{method: http.MethodGet, path: "/api/v1/webhooks/chatops/deliveries"},
+	}
+	tests := []struct {
+		name          string
+		tokens        []domain.AdminToken
+		authorization string
+	}{
+		{
+			name:   "Disabled",
+			tokens: nil,
+		},
+		{
+			name:          "DisabledWithCredential",
+			tokens:        nil,
+			authorization: "Bearer " + testAdminToken,
+		},
+		{
+			name:   "MissingCredential",
+			tokens: []domain.AdminToken{{Name: "admin", Token: domain.Secret{Value: testAdminToken}}},
+		},
+		{
+			name:          "InvalidCredential",
+			tokens:        []domain.AdminToken{{Name: "admin", Token: domain.Secret{Value: testAdminToken}}},
+			authorization: "Bearer guess",
+		},
+	}
+	for _, tt := range tests {
+		for _, route := range routes {
+			t.Run(tt.name+"/"+route.method+" "+route.path, func(t *testing.T) {
+				// Arrange
+				lockRepo := new(repositories.MockLockRepository)
+				r := newTestRouter(lockRepo, tt.tokens)
+				req := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+
+				// Act
+				res, apiErr := serve(t, r, req)
+
+				// Assert
+				assert.Equal(t, http.StatusUnauthorized, res.Code)
+				assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+				lockRepo.AssertNotCalled(t, "Takeover", mock.Anything, mock.Anything, mock.Anything)
+				lockRepo.AssertNotCalled(t, "ForceRelease", mock.Anything, mock.Anything)
+			})
+		}
+	}
+}
+
+func TestNamespaceMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		namespace string
+	}{
+		{name: "DefaultNamespace", path: "/api/v1/locks/deploy", status: http.StatusOK, namespace: domain.DefaultNamespace},
+		{name: "ConfiguredNamespace", path: "/api/v1/namespaces/team-a/locks/deploy", status: http.StatusOK, namespace: "team-a"},
+		{name: "UnknownNamespace", path: "/api/v1/namespaces/team-b/locks/deploy", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			handler := NewWebserviceHandler(nil, nil, nil, nil, nil, nil, infrastructure.NewMockLogger())
+			namespaceMiddleware := NewNamespaceMiddleware(testNamespaces, handler)
+			namespace := ""
+			r := mux.NewRouter()
+			for _, prefix := range []string{"/api/v1", "/api/v1/namespaces/{ns}"} {
+				r.Handle(prefix+"/locks/{key}", namespaceMiddleware.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+					namespace = domain.NamespaceFromContext(req.Context()).Name
+				}))).Methods("GET")
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			// Act
+			res, apiErr := serve(t, r, req)
+
+			// Assert
+			assert.Equal(t, tt.status, res.Code)
+			assert.Equal(t, tt.namespace, namespace)
+			if tt.status == http.StatusNotFound {
+				assert.Equal(t, http.StatusNotFound, apiErr.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// NamespaceMiddleware scopes the lock requests to the namespace of the route.
+type NamespaceMiddleware struct {
+	namespaces map[string]*domain.Namespace
+	handler    *WebserviceHandler
+}
+
+// NewNamespaceMiddleware creates a new NamespaceMiddleware for the configured namespaces,
+// the handler writes the error responses.
+func NewNamespaceMiddleware(namespaces []*domain.Namespace, handler *WebserviceHandler) *NamespaceMiddleware {
+	byName := make(map[string]*domain.Namespace, len(namespaces))
+	for _, ns := range namespaces {
+		byName[ns.Name] = ns
+	}
+	return &NamespaceMiddleware{namespaces: byName, handler: handler}
+}
+
+/**
+ * Middleware resolves the {ns} route variable against the configured namespaces and adds it to the request context,
+ * routes without {ns} use the default namespace. Unknown namespaces are rejected with 404.
+ */
+func (m *NamespaceMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		name, ok := mux.Vars(req)["ns"]
+		if !ok {
+			name = domain.DefaultNamespace
+		}
+		ns, ok := m.namespaces[name]
+		if !ok {
+			const msg = "NamespaceMiddleware.Middleware - namespace not found > %s"
+			m.handler.handleError(res, &domain.NotFoundError{Message: fmt.Sprintf(msg, name)})
+			return
+		}
+		next.ServeHTTP(res, req.WithContext(domain.WithNamespace(req.Context(), ns)))
+	})
+}
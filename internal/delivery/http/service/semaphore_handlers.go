@@ -0,0 +1,139 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+/**
+ * CreateSemaphore handles POST requests to create a new counting semaphore.
+ */
+func (h WebserviceHandler) CreateSemaphore(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.CreateSemaphore - START")
+	var input domain.SemaphoreInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := domain.ValidateSemaphoreInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	semaphore, err := h.SemaphoreUseCase.CreateSemaphore(req.Context(), &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusCreated, domain.NewSuccessResponse(semaphore).Data)
+	h.logger.Debug("WebserviceHandler.CreateSemaphore - END")
+}
+
+/**
+ * DeleteSemaphore handles DELETE requests to remove a semaphore without held permits.
+ */
+func (h WebserviceHandler) DeleteSemaphore(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.DeleteSemaphore - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	if err := h.SemaphoreUseCase.DeleteSemaphore(req.Context(), key); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(nil).Data)
+	h.logger.Debug("WebserviceHandler.DeleteSemaphore - END")
+}
+
+/**
+ * ShowOneSemaphore handles GET requests to retrieve a semaphore with its holders.
+ */
+func (h WebserviceHandler) ShowOneSemaphore(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowOneSemaphore - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	semaphore, err := h.SemaphoreUseCase.GetSemaphore(req.Context(), key)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(semaphore).Data)
+	h.logger.Debug("WebserviceHandler.ShowOneSemaphore - END")
+}
+
+/**
+ * ShowAllSemaphores handles GET requests to retrieve all semaphores.
+ */
+func (h WebserviceHandler) ShowAllSemaphores(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowAllSemaphores - START")
+	semaphores, err := h.SemaphoreUseCase.ListSemaphores(req.Context())
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(semaphores).Data)
+	h.logger.Debug("WebserviceHandler.ShowAllSemaphores - END")
+}
+
+/**
+ * AcquirePermit handles POST requests to take one permit of a semaphore.
+ */
+func (h WebserviceHandler) AcquirePermit(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.AcquirePermit - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	var input domain.SemaphoreAcquireInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	if input.Owner == "" {
+		input.Owner = ownerFromQueryOrHeader(req)
+	}
+
+	if err := domain.ValidateSemaphoreAcquireInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	permit, err := h.SemaphoreUseCase.AcquirePermit(req.Context(), key, &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusCreated, domain.NewSuccessResponse(permit).Data)
+	h.logger.Debug("WebserviceHandler.AcquirePermit - END")
+}
+
+/**
+ * ReleasePermit handles DELETE requests to return the permit held by the requesting owner.
+ */
+func (h WebserviceHandler) ReleasePermit(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ReleasePermit - START")
+	vars := mux.Vars(req)
+	key := vars["key"]
+
+	owner, err := ownerFromRequest(req)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := h.SemaphoreUseCase.ReleasePermit(req.Context(), key, owner); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(nil).Data)
+	h.logger.Debug("WebserviceHandler.ReleasePermit - END")
+}
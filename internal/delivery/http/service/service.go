@@ -2,20 +2,48 @@
 package service
 
 import (
+	"time"
+
 	"github.com/tyriis/go-locking-service/internal/domain"
 	"github.com/tyriis/go-locking-service/internal/usecases"
 )
 
+// Timeouts of the HTTP server. ReadHeaderTimeout limits the time a client has to send the request headers.
+// WriteTimeout limits the time a request has to be answered, event streams are exempt from it
+// and a lock request that waits for a held lock has the wait on top.
+const (
+	ReadHeaderTimeout = 10 * time.Second
+	WriteTimeout      = 30 * time.Second
+)
+
 // WebserviceHandler handles HTTP requests for the lock management API.
 type WebserviceHandler struct {
-	LockUseCase *usecases.LockUseCase
-	logger      domain.Logger
+	LockUseCase      *usecases.LockUseCase
+	SemaphoreUseCase *usecases.SemaphoreUseCase
+	SessionUseCase   *usecases.SessionUseCase
+	AdminUseCase     *usecases.AdminUseCase
+	EventUseCase     *usecases.EventUseCase
+	WebhookUseCase   *usecases.WebhookUseCase
+	logger           domain.Logger
 }
 
-// NewWebserviceHandler creates a new WebserviceHandler with the given use case and logger.
-func NewWebserviceHandler(lockUseCase *usecases.LockUseCase, logger domain.Logger) *WebserviceHandler {
+// NewWebserviceHandler creates a new WebserviceHandler with the given use cases and logger.
+func NewWebserviceHandler(
+	lockUseCase *usecases.LockUseCase,
+	semaphoreUseCase *usecases.SemaphoreUseCase,
+	sessionUseCase *usecases.SessionUseCase,
+	adminUseCase *usecases.AdminUseCase,
+	eventUseCase *usecases.EventUseCase,
+	webhookUseCase *usecases.WebhookUseCase,
+	logger domain.Logger,
+) *WebserviceHandler {
 	return &WebserviceHandler{
-		LockUseCase: lockUseCase,
-		logger:      logger,
+		LockUseCase:      lockUseCase,
+		SemaphoreUseCase: semaphoreUseCase,
+		SessionUseCase:   sessionUseCase,
+		AdminUseCase:     adminUseCase,
+		EventUseCase:     eventUseCase,
+		WebhookUseCase:   webhookUseCase,
+		logger:           logger,
 	}
 }
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+/**
+ * CreateSession handles POST requests to create a new session, locks acquired with its id live as long as it.
+ */
+func (h WebserviceHandler) CreateSession(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.CreateSession - START")
+	var input domain.SessionInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := domain.ValidateSessionInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	session, err := h.SessionUseCase.CreateSession(req.Context(), &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusCreated, domain.NewSuccessResponse(session).Data)
+	h.logger.Debug("WebserviceHandler.CreateSession - END")
+}
+
+/**
+ * ShowOneSession handles GET requests to retrieve a session with the keys of its locks.
+ */
+func (h WebserviceHandler) ShowOneSession(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowOneSession - START")
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	session, err := h.SessionUseCase.GetSession(req.Context(), id)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(session).Data)
+	h.logger.Debug("WebserviceHandler.ShowOneSession - END")
+}
+
+/**
+ * RenewSession handles heartbeat requests, the session and all its locks live for another TTL.
+ */
+func (h WebserviceHandler) RenewSession(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.RenewSession - START")
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	session, err := h.SessionUseCase.RenewSession(req.Context(), id)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(session).Data)
+	h.logger.Debug("WebserviceHandler.RenewSession - END")
+}
+
+/**
+ * DeleteSession handles DELETE requests to destroy a session, all its locks are released at once.
+ */
+func (h WebserviceHandler) DeleteSession(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.DeleteSession - START")
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	released, err := h.SessionUseCase.DeleteSession(req.Context(), id)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(map[string]int{"released": released}).Data)
+	h.logger.Debug("WebserviceHandler.DeleteSession - END")
+}
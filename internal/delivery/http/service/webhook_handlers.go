@@ -0,0 +1,122 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+/**
+ * CreateWebhook handles POST requests to register a webhook, the response holds its secret once.
+ */
+func (h WebserviceHandler) CreateWebhook(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.CreateWebhook - START")
+	if _, err := h.AdminUseCase.Authorize(adminCredential(req)); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	var input domain.WebhookInput
+	if err := decodeJSON(req, &input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	if err := domain.ValidateWebhookInput(&input); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	webhook, err := h.WebhookUseCase.CreateWebhook(req.Context(), &input)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusCreated, domain.NewSuccessResponse(webhook).Data)
+	h.logger.Debug("WebserviceHandler.CreateWebhook - END")
+}
+
+/**
+ * ShowAllWebhooks handles GET requests to retrieve the configured and registered webhooks.
+ */
+func (h WebserviceHandler) ShowAllWebhooks(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowAllWebhooks - START")
+	if _, err := h.AdminUseCase.Authorize(adminCredential(req)); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	webhooks, err := h.WebhookUseCase.ListWebhooks(req.Context())
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(webhooks).Data)
+	h.logger.Debug("WebserviceHandler.ShowAllWebhooks - END")
+}
+
+/**
+ * ShowOneWebhook handles GET requests to retrieve a webhook.
+ */
+func (h WebserviceHandler) ShowOneWebhook(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowOneWebhook - START")
+	if _, err := h.AdminUseCase.Authorize(adminCredential(req)); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	webhook, err := h.WebhookUseCase.GetWebhook(req.Context(), id)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(webhook).Data)
+	h.logger.Debug("WebserviceHandler.ShowOneWebhook - END")
+}
+
+/**
+ * DeleteWebhook handles DELETE requests to remove a registered webhook.
+ */
+func (h WebserviceHandler) DeleteWebhook(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.DeleteWebhook - START")
+	if _, err := h.AdminUseCase.Authorize(adminCredential(req)); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	if err := h.WebhookUseCase.DeleteWebhook(req.Context(), id); err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(nil).Data)
+	h.logger.Debug("WebserviceHandler.DeleteWebhook - END")
+}
+
+/**
+ * ShowWebhookDeliveries handles GET requests to retrieve the delivery log of a webhook.
+ */
+func (h WebserviceHandler) ShowWebhookDeliveries(res http.ResponseWriter, req *http.Request) {
+	h.logger.Debug("WebserviceHandler.ShowWebhookDeliveries - START")
+	if _, err := h.AdminUseCase.Authorize(adminCredential(req)); err != nil {
+		h.handleError(res, err)
+		return
+	}
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	deliveries, err := h.WebhookUseCase.GetDeliveries(req.Context(), id)
+	if err != nil {
+		h.handleError(res, err)
+		return
+	}
+
+	h.respondWithJSON(res, http.StatusOK, domain.NewSuccessResponse(deliveries).Data)
+	h.logger.Debug("WebserviceHandler.ShowWebhookDeliveries - END")
+}
@@ -1,13 +1,125 @@
 package domain
 
+// Backends that store the locks.
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+	BackendFile   = "file"
+)
+
 type Config struct {
-	Redis struct {
+	// Backend stores the locks, Redis unless it is BackendMemory or BackendFile
+	Backend string `yaml:"backend"`
+	// DataDir is the directory of the database of BackendFile
+	DataDir string `yaml:"dataDir"`
+	Redis   struct {
 		Host   string `yaml:"host"`
 		Port   string `yaml:"port"`
 		Prefix string `yaml:"keyPrefix"`
+		// Nodes are independent Redis instances, with them the locks are kept on all nodes following
+		// the Redlock algorithm instead of on Host
+		Nodes []RedisNode `yaml:"nodes"`
+		// NodeTimeout limits every call to one of the Nodes as timestring f.e. 100ms, the default
+		NodeTimeout string `yaml:"nodeTimeout"`
+		// Sentinels monitor the master Name, with them the master is resolved through the sentinels
+		// instead of Host and followed on failover
+		Sentinels []RedisNode `yaml:"sentinels"`
+		Name      string      `yaml:"name"`
+		// Cluster are seed nodes of a Redis Cluster, with them the keys are spread across its masters
+		Cluster []RedisNode `yaml:"cluster"`
+		// Username and Password authenticate with Redis, Username selects an ACL user
+		Username string `yaml:"username"`
+		Password Secret `yaml:"password"`
+		// SentinelUsername and SentinelPassword authenticate with the Sentinels, if they require it
+		SentinelUsername string `yaml:"sentinelUsername"`
+		SentinelPassword Secret `yaml:"sentinelPassword"`
+		// DB is the database index, a cluster only has database 0
+		DB  int      `yaml:"db"`
+		TLS RedisTLS `yaml:"tls"`
+		// SkipNotifySetup leaves notify-keyspace-events of the server as it is, f.e. on managed Redis
+		// that rejects CONFIG SET, instead of enabling the keyspace notifications the service needs
+		SkipNotifySetup bool `yaml:"skipNotifySetup"`
 	} `yaml:"redis"`
 	Api struct {
 		Port string `yaml:"port"`
 		Host string `yaml:"host"`
+		// TrustedProxies are the addresses or CIDR ranges of the proxies whose X-Forwarded-For header
+		// names the client, the direct peer is the client of any other request
+		TrustedProxies []string `yaml:"trustedProxies"`
 	} `yaml:"api"`
+	// Namespaces are served below /api/v1/namespaces/{ns}, "default" configures the routes without one
+	Namespaces []Namespace `yaml:"namespaces"`
+	// Webhooks receive the lock events besides the ones registered via the API
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+	// History configures the audit history of the locks
+	History HistoryConfig `yaml:"history"`
+	Admin   struct {
+		// Token is a credential of the admin endpoints, changes made with it are recorded as by "admin"
+		Token Secret `yaml:"token"`
+		// Tokens are named credentials of the admin endpoints, changes are recorded as by their name.
+		// The admin endpoints are disabled without any credential.
+		Tokens []AdminToken `yaml:"tokens"`
+	} `yaml:"admin"`
+}
+
+// AdminToken is a credential of the admin endpoints, Name is recorded as the actor of the changes made with it.
+type AdminToken struct {
+	Name  string `yaml:"name"`
+	Token Secret `yaml:"token"`
+}
+
+// RedisNode is a single Redis instance.
+type RedisNode struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+}
+
+// RedisTLS configures TLS for the Redis connections, the server is verified against the system CAs
+// or CAFile. CertFile and KeyFile are the client certificate, ServerName overrides the verified host name.
+type RedisTLS struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"caFile"`
+	CertFile   string `yaml:"certFile"`
+	KeyFile    string `yaml:"keyFile"`
+	ServerName string `yaml:"serverName"`
+}
+
+// Secret is a credential of the config, either the plain Value or read from the env var FromEnv
+// or the file FromFile when the config is loaded.
+type Secret struct {
+	Value    string `yaml:"-"`
+	FromEnv  string `yaml:"fromEnv"`
+	FromFile string `yaml:"fromFile"`
+}
+
+// UnmarshalYAML accepts a plain string as Value besides a mapping of FromEnv or FromFile. It implements the
+// function based unmarshaler of the YAML decoder, so the domain does not depend on the decoder package.
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*s = Secret{Value: value}
+		return nil
+	}
+	type secret Secret
+	return unmarshal((*secret)(s))
+}
+
+// Secrets returns all secrets of the config, they are resolved when the config is loaded.
+func (c *Config) Secrets() []*Secret {
+	secrets := []*Secret{&c.Redis.Password, &c.Redis.SentinelPassword, &c.Admin.Token}
+	for i := range c.Admin.Tokens {
+		secrets = append(secrets, &c.Admin.Tokens[i].Token)
+	}
+	for i := range c.Webhooks {
+		secrets = append(secrets, &c.Webhooks[i].Secret)
+	}
+	return secrets
+}
+
+// MarshalJSON redacts the secret, so it does not leak into logs.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s.Value == "" {
+		return []byte(`""`), nil
+	}
+	return []byte(`"***"`), nil
 }
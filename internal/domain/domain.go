@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -13,12 +14,36 @@ type ConfigValidator interface {
 	Validate(data interface{}) error
 }
 
+// Lock modes, a shared lock can be held by any number of owners at once, an exclusive lock only by one.
+const (
+	LockModeExclusive = "exclusive"
+	LockModeShared    = "shared"
+)
+
 type Lock struct {
 	Key       string    `json:"key"`
 	Owner     string    `json:"owner"`
+	Mode      string    `json:"mode"`
 	Duration  int64     `json:"duration"`
 	ExpireAt  time.Time `json:"expireAt"`
 	CreatedAt time.Time `json:"createdAt"`
+	// FencingToken increases with every acquisition of the key, downstream systems can use it to reject stale holders
+	FencingToken int64 `json:"fencingToken"`
+	// Reentrant locks can be acquired again by their owner, every acquisition increments HoldCount
+	Reentrant bool `json:"reentrant,omitempty"`
+	// HoldCount is the number of acquisitions the owner still has to release before the key is freed
+	HoldCount int `json:"holdCount"`
+	// SessionID binds the lock to a session, it lives as long as the session instead of its own duration
+	SessionID string `json:"sessionId,omitempty"`
+	// Metadata records administrative changes of the lock, f.e. who took it over and why
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// LockQueueEntry is an owner waiting for a held lock, the lock is granted in order of Position.
+type LockQueueEntry struct {
+	Key      string `json:"key"`
+	Owner    string `json:"owner"`
+	Position int    `json:"position"`
 }
 
 type LockError struct {
@@ -27,10 +52,20 @@ type LockError struct {
 }
 
 type LockRepository interface {
-	Get(key string) ([]*Lock, error)
-	Set(key string, value string, ttl time.Duration) (*Lock, error)
-	Del(key string) error
-	Count() (int, error)
+	Get(ctx context.Context, key string) ([]*Lock, error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) (*Lock, error)
+	SetMultiple(ctx context.Context, locks []*Lock) ([]*Lock, error)
+	Takeover(ctx context.Context, lock *Lock, ttl time.Duration) (*Lock, []*Lock, error)
+	ForceRelease(ctx context.Context, key string) ([]*Lock, error)
+	Del(ctx context.Context, key string) error
+	Release(ctx context.Context, key string, owner string) error
+	Renew(ctx context.Context, key string, owner string, ttl time.Duration) (*Lock, error)
+	Handoff(ctx context.Context, key string, owner string, newOwner string) (*Lock, error)
+	Watch(ctx context.Context, key string) (<-chan struct{}, error)
+	Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error)
+	Dequeue(ctx context.Context, key string, owner string) error
+	Queue(ctx context.Context, key string) ([]*LockQueueEntry, error)
+	Count(ctx context.Context) (int, error)
 }
 
 type ValidationError struct {
@@ -53,6 +88,15 @@ type LockInput struct {
 	Key      string `json:"key"`
 	Owner    string `json:"owner"`
 	Duration string `json:"duration"`
+	// Mode is either "exclusive" (default) or "shared"
+	Mode string `json:"mode,omitempty"`
+	// Reentrant lets the owner acquire the lock again while it holds it instead of getting a conflict,
+	// the acquisition that takes the free lock decides it
+	Reentrant bool `json:"reentrant,omitempty"`
+	// SessionID binds the lock to a session, it lives as long as the session and needs no duration then
+	SessionID string `json:"sessionId,omitempty"`
+	// Wait is an optional timestring, if set the request waits up to this long for a held lock to become free
+	Wait string `json:"wait,omitempty"`
 }
 
 func ValidateLockInput(input *LockInput) error {
@@ -64,13 +108,125 @@ func ValidateLockInput(input *LockInput) error {
 	if input.Owner == "" {
 		return NewValidationError("LOCK_REQUIRES_OWNER", "owner is required")
 	}
-	// input.Duration need to be a string and valid duration as timestring f.e. 1h20m
-	if _, err := time.ParseDuration(input.Duration); err != nil {
+	// input.Duration need to be a string and valid duration as timestring f.e. 1h20m, a session lock may omit it as it is not used
+	if input.SessionID == "" || input.Duration != "" {
+		if _, err := time.ParseDuration(input.Duration); err != nil {
+			return NewValidationError("LOCK_INVALID_DURATION", fmt.Sprintf("duration '%s' is invalid", input.Duration))
+		}
+	}
+	// input.Mode is optional, if set it need to be a known lock mode
+	if input.Mode != "" && input.Mode != LockModeExclusive && input.Mode != LockModeShared {
+		return NewValidationError("LOCK_INVALID_MODE", fmt.Sprintf("mode '%s' is invalid", input.Mode))
+	}
+	// input.Wait is optional, if set it need to be a valid, not negative duration as timestring f.e. 30s
+	if input.Wait != "" {
+		if wait, err := time.ParseDuration(input.Wait); err != nil || wait < 0 {
+			return NewValidationError("LOCK_INVALID_WAIT", fmt.Sprintf("wait '%s' is invalid", input.Wait))
+		}
+	}
+	return nil
+}
+
+// LockBatchInput acquires all keys for one owner and duration, or none of them.
+type LockBatchInput struct {
+	Keys     []string `json:"keys"`
+	Owner    string   `json:"owner"`
+	Duration string   `json:"duration"`
+}
+
+func ValidateLockBatchInput(input *LockBatchInput) error {
+	// input.Keys need to contain at least one key, every key valid and only once
+	if len(input.Keys) == 0 {
+		return NewValidationError("LOCK_REQUIRES_KEYS", "keys are required")
+	}
+	seen := make(map[string]bool, len(input.Keys))
+	for i := range input.Keys {
+		if err := ValidateLockKeyInput(&input.Keys[i]); err != nil {
+			return err
+		}
+		if seen[input.Keys[i]] {
+			return NewValidationError("LOCK_DUPLICATE_KEY", fmt.Sprintf("key '%s' is duplicated", input.Keys[i]))
+		}
+		seen[input.Keys[i]] = true
+	}
+	// input.Owner need to be a string, not empty
+	if input.Owner == "" {
+		return NewValidationError("LOCK_REQUIRES_OWNER", "owner is required")
+	}
+	// input.Duration need to be a positive duration as timestring f.e. 1h20m
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil || duration <= 0 {
 		return NewValidationError("LOCK_INVALID_DURATION", fmt.Sprintf("duration '%s' is invalid", input.Duration))
 	}
 	return nil
 }
 
+// LockAdminInput is the input of administrative lock changes, Owner and Duration are only used by a takeover.
+type LockAdminInput struct {
+	Owner    string `json:"owner,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	// Actor and Reason are recorded with the change, Actor is the name of the admin credential
+	// of the request and can't be chosen by the client
+	Actor  string `json:"-"`
+	Reason string `json:"reason"`
+}
+
+func ValidateLockAdminInput(input *LockAdminInput, takeover bool) error {
+	// input.Reason need to be a string, not empty
+	if input.Reason == "" {
+		return NewValidationError("LOCK_REQUIRES_REASON", "reason is required")
+	}
+	if !takeover {
+		return nil
+	}
+	// input.Owner need to be a string, not empty
+	if input.Owner == "" {
+		return NewValidationError("LOCK_REQUIRES_OWNER", "owner is required")
+	}
+	// input.Duration need to be a positive duration as timestring f.e. 1h20m
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil || duration <= 0 {
+		return NewValidationError("LOCK_INVALID_DURATION", fmt.Sprintf("duration '%s' is invalid", input.Duration))
+	}
+	return nil
+}
+
+type LockRenewInput struct {
+	Owner    string `json:"owner"`
+	Duration string `json:"duration"`
+}
+
+func ValidateLockRenewInput(input *LockRenewInput) error {
+	// input.Owner need to be a string, not empty
+	if input.Owner == "" {
+		return NewValidationError("LOCK_REQUIRES_OWNER", "owner is required")
+	}
+	// input.Duration need to be a positive duration as timestring f.e. 1h20m
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil || duration <= 0 {
+		return NewValidationError("LOCK_INVALID_DURATION", fmt.Sprintf("duration '%s' is invalid", input.Duration))
+	}
+	return nil
+}
+
+// LockHandoffInput passes a lock held by Owner on to NewOwner.
+type LockHandoffInput struct {
+	Owner    string `json:"owner"`
+	NewOwner string `json:"newOwner"`
+}
+
+func ValidateLockHandoffInput(input *LockHandoffInput) error {
+	// input.Owner need to be a string, not empty
+	if input.Owner == "" {
+		return NewValidationError("LOCK_REQUIRES_OWNER", "owner is required")
+	}
+	// input.NewOwner need to be a string, not empty and differ from input.Owner
+	if input.NewOwner == "" || input.NewOwner == input.Owner {
+		return NewValidationError("LOCK_INVALID_NEW_OWNER", fmt.Sprintf("newOwner '%s' is invalid", input.NewOwner))
+	}
+	return nil
+}
+
 func ValidateLockKeyInput(key *string) error {
 	// input.Key need to be a string, not empty and minimum 3 character
 	if len(*key) < 3 {
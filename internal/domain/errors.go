@@ -1,6 +1,9 @@
 package domain
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // InputError represents an error when the input is invalid
 type InputError struct {
@@ -25,6 +28,8 @@ func (e *NotFoundError) Error() string {
 // LockConflictError represents an error when a lock already exists
 type LockConflictError struct {
 	Message string
+	// Keys names the held keys if several keys were acquired at once
+	Keys []string
 }
 
 func (e *LockConflictError) Error() string {
@@ -32,6 +37,61 @@ func (e *LockConflictError) Error() string {
 	return msg
 }
 
+// LockOwnerMismatchError represents an error when a lock is held by another owner
+type LockOwnerMismatchError struct {
+	Message string
+}
+
+func (e *LockOwnerMismatchError) Error() string {
+	msg := fmt.Sprintf("%s lock is held by another owner!", e.Message)
+	return msg
+}
+
+// LockNotHeldError represents an error when a lock is not held (anymore), f.e. because it expired
+type LockNotHeldError struct {
+	Message string
+}
+
+func (e *LockNotHeldError) Error() string {
+	msg := fmt.Sprintf("%s lock is not held!", e.Message)
+	return msg
+}
+
+// LockLostError represents an error when the lease of an owner ended before it released or renewed the lock,
+// so the owner was not protected by the lock since ExpiredAt
+type LockLostError struct {
+	Message string `json:"-"`
+	// Owners are the current holders of the lock, it is empty if nobody acquired it since
+	Owners []string `json:"owners"`
+	// ExpiredAt is the time the lease of the owner ended
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+func (e *LockLostError) Error() string {
+	msg := fmt.Sprintf("%s lock was lost!", e.Message)
+	return msg
+}
+
+// SemaphoreExhaustedError represents an error when all permits of a semaphore are held
+type SemaphoreExhaustedError struct {
+	Message string
+}
+
+func (e *SemaphoreExhaustedError) Error() string {
+	msg := fmt.Sprintf("%s no permits available!", e.Message)
+	return msg
+}
+
+// UnauthorizedError represents an error when a request lacks a valid admin credential
+type UnauthorizedError struct {
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	msg := fmt.Sprintf("%s unauthorized!", e.Message)
+	return msg
+}
+
 // InternalError represents an internal server error
 type InternalError struct {
 	Message string
@@ -51,6 +111,10 @@ type APIResponse struct {
 type APIError struct {
 	Message string `json:"message"`
 	Status  int    `json:"status"`
+	// Code identifies errors clients are expected to handle, f.e. LOCK_LOST
+	Code string `json:"code,omitempty"`
+	// Details carries the data of the error the client needs to handle it
+	Details interface{} `json:"details,omitempty"`
 }
 
 // NewErrorResponse creates a new API error response
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Lock event types.
+const (
+	// LockEventAcquired is emitted when an owner acquired a lock or another hold of it
+	LockEventAcquired = "acquired"
+	// LockEventRenewed is emitted when an owner extended its lease of a lock
+	LockEventRenewed = "renewed"
+	// LockEventReleased is emitted when an owner released its hold of a lock
+	LockEventReleased = "released"
+	// LockEventExpired is emitted when a lock expired without being released
+	LockEventExpired = "expired"
+	// LockEventHandoff is emitted when an owner passed a lock on to a new owner
+	LockEventHandoff = "handoff"
+	// LockEventTakeover is emitted when an admin reassigned a lock to a new owner
+	LockEventTakeover = "takeover"
+	// LockEventForceReleased is emitted when an admin released a lock regardless of its owner
+	LockEventForceReleased = "force_released"
+)
+
+// LockEvent describes a change of a lock that its (previous) holders can observe.
+type LockEvent struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+	// Owner is the owner of the lock after the change, if any
+	Owner string `json:"owner,omitempty"`
+	// PreviousOwners are the owners that held the lock before the change
+	PreviousOwners []string `json:"previousOwners,omitempty"`
+	// FencingToken is the token of the lock after the change, if any
+	FencingToken int64 `json:"fencingToken,omitempty"`
+	// Actor and Reason tell who caused an administrative change and why
+	Actor  string    `json:"actor,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// LockEventFilter selects the events of an event stream, empty fields match every event.
+type LockEventFilter struct {
+	KeyPrefix string
+	Owner     string
+}
+
+// Matches reports whether the event is about a lock with the key prefix and concerns the owner,
+// as its owner after the change or as one of its previous owners.
+func (f *LockEventFilter) Matches(event *LockEvent) bool {
+	if !strings.HasPrefix(event.Key, f.KeyPrefix) {
+		return false
+	}
+	if f.Owner == "" || event.Owner == f.Owner {
+		return true
+	}
+	for _, owner := range event.PreviousOwners {
+		if owner == f.Owner {
+			return true
+		}
+	}
+	return false
+}
+
+type EventRepository interface {
+	Publish(ctx context.Context, event *LockEvent) error
+	// Subscribe streams the events of the locks with the key prefix until ctx is done
+	Subscribe(ctx context.Context, keyPrefix string) (<-chan *LockEvent, error)
+	// Claim reports whether the caller is the first to claim the handling of an event until ttl passed,
+	// so an event every replica observes is handled once
+	Claim(ctx context.Context, id string, ttl time.Duration) (bool, error)
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Defaults of the lock history if the configuration leaves them out.
+const (
+	DefaultHistoryRetention  = 7 * 24 * time.Hour
+	DefaultHistoryMaxEntries = 1000
+)
+
+// HistoryConfig configures the audit history that is kept for every lock.
+type HistoryConfig struct {
+	// Retention is the time an entry is kept as timestring f.e. 720h
+	Retention string `yaml:"retention"`
+	// MaxEntries is the number of entries kept per lock, older ones are dropped first
+	MaxEntries int64 `yaml:"maxEntries"`
+}
+
+// HistoryPolicy is the validated HistoryConfig.
+type HistoryPolicy struct {
+	Retention  time.Duration
+	MaxEntries int64
+}
+
+// ConfiguredHistory returns the validated history policy of config, unset limits get their defaults.
+func ConfiguredHistory(config *Config) (*HistoryPolicy, error) {
+	policy := &HistoryPolicy{Retention: DefaultHistoryRetention, MaxEntries: DefaultHistoryMaxEntries}
+	if config.History.Retention != "" {
+		retention, err := time.ParseDuration(config.History.Retention)
+		if err != nil || retention <= 0 {
+			return nil, NewValidationError("HISTORY_INVALID_RETENTION", fmt.Sprintf("retention '%s' is invalid", config.History.Retention))
+		}
+		policy.Retention = retention
+	}
+	if config.History.MaxEntries < 0 {
+		return nil, NewValidationError("HISTORY_INVALID_MAX_ENTRIES", fmt.Sprintf("maxEntries %d is invalid", config.History.MaxEntries))
+	}
+	if config.History.MaxEntries > 0 {
+		policy.MaxEntries = config.History.MaxEntries
+	}
+	return policy, nil
+}
+
+// LockHistoryEntry is a change of a lock in its audit history.
+type LockHistoryEntry struct {
+	LockEvent
+	// ClientIP is the address of the client that changed the lock, it is empty if the lock expired
+	ClientIP string `json:"clientIp,omitempty"`
+}
+
+// HistoryRepository keeps the audit history of every lock in the namespace of ctx,
+// it outlives the lock until the retention of its entries passed.
+type HistoryRepository interface {
+	// Append adds the entry to the history of its lock
+	Append(ctx context.Context, entry *LockHistoryEntry) error
+	// List returns the entries of the history of the lock from oldest to newest,
+	// limited to the time range between from and to, a zero time leaves its end of the range open
+	List(ctx context.Context, key string, from time.Time, to time.Time) ([]*LockHistoryEntry, error)
+}
+
+type clientIPContextKey struct{}
+
+// WithClientIP returns a copy of ctx that records ip as the client of the lock operations.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, ip)
+}
+
+// ClientIPFromContext returns the client address of ctx, it is empty if there is none.
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPContextKey{}).(string)
+	return ip
+}
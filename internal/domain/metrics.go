@@ -5,5 +5,7 @@ type MetricsRecorder interface {
 	ObserveHTTPRequest(method, path string, statusCode int, duration float64)
 	RecordUserAction(action string)
 	IncrementErrorCount(errorType string)
-	SetLockCount(value float64)
+	SetLockCount(namespace string, value float64)
+	IncrementExpiredLocks(namespace string)
+	IncrementRedisFailovers()
 }
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultNamespace is the namespace of the routes without a namespace, its keys carry no namespace.
+const DefaultNamespace = "default"
+
+// namespaceNamePattern keeps namespace names apart from the rest of the key layout.
+var namespaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Namespace separates the locks of a tenant, every namespace can limit its locks with its own policy.
+type Namespace struct {
+	Name string `yaml:"name" json:"name"`
+	// MaxDuration is the longest lease a lock of the namespace can be acquired or renewed for
+	MaxDuration string `yaml:"maxDuration" json:"maxDuration,omitempty"`
+	// MaxWait is the longest time an acquisition in the namespace can wait for a held lock
+	MaxWait string `yaml:"maxWait" json:"maxWait,omitempty"`
+}
+
+// ValidateNamespace checks the name and policy of a configured namespace.
+func ValidateNamespace(ns *Namespace) error {
+	if !namespaceNamePattern.MatchString(ns.Name) {
+		return NewValidationError("NAMESPACE_INVALID_NAME", fmt.Sprintf("namespace '%s' is invalid", ns.Name))
+	}
+	for _, limit := range []string{ns.MaxDuration, ns.MaxWait} {
+		if limit == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(limit); err != nil || d <= 0 {
+			return NewValidationError("NAMESPACE_INVALID_POLICY", fmt.Sprintf("namespace '%s' limit '%s' is invalid", ns.Name, limit))
+		}
+	}
+	return nil
+}
+
+// ConfiguredNamespaces returns the validated namespaces of config, the default namespace is always part of them.
+func ConfiguredNamespaces(config *Config) ([]*Namespace, error) {
+	namespaces := []*Namespace{{Name: DefaultNamespace}}
+	seen := map[string]bool{}
+	for i := range config.Namespaces {
+		ns := config.Namespaces[i]
+		if err := ValidateNamespace(&ns); err != nil {
+			return nil, err
+		}
+		if seen[ns.Name] {
+			return nil, NewValidationError("NAMESPACE_DUPLICATE", fmt.Sprintf("namespace '%s' is declared twice", ns.Name))
+		}
+		seen[ns.Name] = true
+		if ns.Name == DefaultNamespace {
+			namespaces[0] = &ns
+			continue
+		}
+		namespaces = append(namespaces, &ns)
+	}
+	return namespaces, nil
+}
+
+// HasNamespace reports whether the namespace name is one of namespaces, an empty name is the default namespace.
+func HasNamespace(namespaces []*Namespace, name string) bool {
+	if name == "" {
+		name = DefaultNamespace
+	}
+	for _, ns := range namespaces {
+		if ns.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDuration returns a *ValidationError if the lease d exceeds the policy of the namespace.
+func (ns *Namespace) CheckDuration(d time.Duration) error {
+	if ns.MaxDuration == "" {
+		return nil
+	}
+	if limit, _ := time.ParseDuration(ns.MaxDuration); d > limit {
+		return NewValidationError("LOCK_DURATION_EXCEEDS_POLICY", fmt.Sprintf("duration exceeds %s of namespace '%s'", ns.MaxDuration, ns.Name))
+	}
+	return nil
+}
+
+// CheckWait returns a *ValidationError if the wait d exceeds the policy of the namespace.
+func (ns *Namespace) CheckWait(d time.Duration) error {
+	if ns.MaxWait == "" {
+		return nil
+	}
+	if limit, _ := time.ParseDuration(ns.MaxWait); d > limit {
+		return NewValidationError("LOCK_WAIT_EXCEEDS_POLICY", fmt.Sprintf("wait exceeds %s of namespace '%s'", ns.MaxWait, ns.Name))
+	}
+	return nil
+}
+
+type namespaceContextKey struct{}
+
+// WithNamespace returns a copy of ctx that scopes all lock operations to ns.
+func WithNamespace(ctx context.Context, ns *Namespace) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, ns)
+}
+
+// NamespaceFromContext returns the namespace of ctx, the default namespace without policy if it has none.
+func NamespaceFromContext(ctx context.Context) *Namespace {
+	if ns, ok := ctx.Value(namespaceContextKey{}).(*Namespace); ok && ns != nil {
+		return ns
+	}
+	return &Namespace{Name: DefaultNamespace}
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Semaphore caps the number of owners that can hold a resource at once to Permits.
+type Semaphore struct {
+	Key       string             `json:"key"`
+	Permits   int                `json:"permits"`
+	Available int                `json:"available"`
+	Holders   []*SemaphorePermit `json:"holders"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// SemaphorePermit is one permit of a semaphore held by an owner until it expires.
+type SemaphorePermit struct {
+	Key       string    `json:"key"`
+	Owner     string    `json:"owner"`
+	Duration  int64     `json:"duration"`
+	ExpireAt  time.Time `json:"expireAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SemaphoreRepository interface {
+	Create(ctx context.Context, semaphore *Semaphore) error
+	Get(ctx context.Context, key string) (*Semaphore, error)
+	List(ctx context.Context) ([]*Semaphore, error)
+	Delete(ctx context.Context, key string) error
+	Acquire(ctx context.Context, permit *SemaphorePermit, ttl time.Duration) (*SemaphorePermit, error)
+	Release(ctx context.Context, key string, owner string) error
+}
+
+type SemaphoreInput struct {
+	Key     string `json:"key"`
+	Permits int    `json:"permits"`
+}
+
+func ValidateSemaphoreInput(input *SemaphoreInput) error {
+	// input.Key need to be a string, not empty and minimum 3 character
+	if err := ValidateLockKeyInput(&input.Key); err != nil {
+		return err
+	}
+	// input.Permits need to allow at least one holder
+	if input.Permits < 1 {
+		return NewValidationError("SEMAPHORE_INVALID_PERMITS", fmt.Sprintf("permits '%d' is invalid", input.Permits))
+	}
+	return nil
+}
+
+type SemaphoreAcquireInput struct {
+	Owner    string `json:"owner"`
+	Duration string `json:"duration"`
+}
+
+func ValidateSemaphoreAcquireInput(input *SemaphoreAcquireInput) error {
+	// input.Owner need to be a string, not empty
+	if input.Owner == "" {
+		return NewValidationError("SEMAPHORE_REQUIRES_OWNER", "owner is required")
+	}
+	// input.Duration need to be a positive duration as timestring f.e. 1h20m
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil || duration <= 0 {
+		return NewValidationError("SEMAPHORE_INVALID_DURATION", fmt.Sprintf("duration '%s' is invalid", input.Duration))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Session owns locks as long as its client sends heartbeats, all locks of a session
+// are released at once when the session expires or is destroyed.
+type Session struct {
+	ID string `json:"id"`
+	// TTL is the time in seconds the session lives after its last heartbeat
+	TTL       int64     `json:"ttl"`
+	ExpireAt  time.Time `json:"expireAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Keys are the locks held by the session
+	Keys []string `json:"keys"`
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	Get(ctx context.Context, id string) (*Session, error)
+	Renew(ctx context.Context, session *Session) (*Session, error)
+	Delete(ctx context.Context, id string) (int, error)
+}
+
+type SessionInput struct {
+	// TTL is a timestring f.e. 30s, the session expires if no heartbeat arrives within it
+	TTL string `json:"ttl"`
+}
+
+func ValidateSessionInput(input *SessionInput) error {
+	// input.TTL need to be a duration of at least one second as timestring f.e. 30s
+	ttl, err := time.ParseDuration(input.TTL)
+	if err != nil || ttl < time.Second {
+		return NewValidationError("SESSION_INVALID_TTL", fmt.Sprintf("ttl '%s' is invalid", input.TTL))
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Webhook is an HTTP callback that receives the lock events matching its key pattern,
+// it is declared in the config or registered via the API.
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// KeyPattern selects the locks by key, * matches any characters and ? a single one
+	KeyPattern string `json:"keyPattern"`
+	// Namespace of the locks, the default namespace if empty
+	Namespace string `json:"namespace,omitempty"`
+	// Events are the lock event types the webhook receives, all of them if empty
+	Events []string `json:"events,omitempty"`
+	// Secret signs the deliveries, it is only returned when the webhook is registered
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookConfig is a webhook declared in the config, its Secret can be read from an env var or a file.
+type WebhookConfig struct {
+	Name       string   `yaml:"name"`
+	URL        string   `yaml:"url"`
+	KeyPattern string   `yaml:"keyPattern"`
+	Namespace  string   `yaml:"namespace"`
+	Events     []string `yaml:"events"`
+	Secret     Secret   `yaml:"secret"`
+}
+
+// Matches reports whether the webhook receives the event about a lock of the namespace.
+func (w *Webhook) Matches(namespace string, event *LockEvent) bool {
+	own := w.Namespace
+	if own == "" {
+		own = DefaultNamespace
+	}
+	if own != namespace || !MatchKeyPattern(w.KeyPattern, event.Key) {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, eventType := range w.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchKeyPattern reports whether key matches the pattern, * matches any characters and ? a single one.
+func MatchKeyPattern(pattern string, key string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	matched, _ := regexp.MatchString("^"+expr+"$", key)
+	return matched
+}
+
+// WebhookPayload is the body of a webhook delivery.
+type WebhookPayload struct {
+	Delivery  string     `json:"delivery"`
+	Webhook   string     `json:"webhook"`
+	Namespace string     `json:"namespace"`
+	Event     *LockEvent `json:"event"`
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId"`
+	EventType string `json:"eventType"`
+	Key       string `json:"key"`
+	Attempt   int    `json:"attempt"`
+	// StatusCode is the response status of the receiver, 0 if no response arrived
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Success    bool      `json:"success"`
+	At         time.Time `json:"at"`
+}
+
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *Webhook) error
+	Get(ctx context.Context, id string) (*Webhook, error)
+	List(ctx context.Context) ([]*Webhook, error)
+	Delete(ctx context.Context, id string) error
+	LogDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	Deliveries(ctx context.Context, id string) ([]*WebhookDelivery, error)
+}
+
+// WebhookClient sends the deliveries of webhooks.
+type WebhookClient interface {
+	// Post sends body to url with the given headers and returns the response status.
+	Post(ctx context.Context, url string, headers map[string]string, body []byte) (int, error)
+}
+
+type WebhookInput struct {
+	URL        string   `json:"url"`
+	KeyPattern string   `json:"keyPattern"`
+	Namespace  string   `json:"namespace"`
+	Events     []string `json:"events"`
+	// Secret signs the deliveries, a random one is generated if empty
+	Secret string `json:"secret"`
+}
+
+// webhookEvents are the lock event types a webhook can receive.
+var webhookEvents = []string{
+	LockEventAcquired, LockEventRenewed, LockEventReleased, LockEventExpired,
+	LockEventHandoff, LockEventTakeover, LockEventForceReleased,
+}
+
+func ValidateWebhookInput(input *WebhookInput) error {
+	// input.URL need to be an absolute http(s) url
+	target, err := url.Parse(input.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return NewValidationError("WEBHOOK_INVALID_URL", fmt.Sprintf("url '%s' is invalid", input.URL))
+	}
+	if input.KeyPattern == "" {
+		return NewValidationError("WEBHOOK_REQUIRES_KEY_PATTERN", "keyPattern is required")
+	}
+	for _, eventType := range input.Events {
+		valid := false
+		for _, known := range webhookEvents {
+			valid = valid || eventType == known
+		}
+		if !valid {
+			return NewValidationError("WEBHOOK_INVALID_EVENT", fmt.Sprintf("event '%s' is invalid", eventType))
+		}
+	}
+	return nil
+}
+
+// ConfiguredWebhooks returns the validated webhooks of config, they need a unique name and a secret.
+func ConfiguredWebhooks(config *Config) ([]*Webhook, error) {
+	namespaces, err := ConfiguredNamespaces(config)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]*Webhook, 0, len(config.Webhooks))
+	seen := map[string]bool{}
+	for _, webhook := range config.Webhooks {
+		if webhook.Name == "" || seen[webhook.Name] {
+			return nil, NewValidationError("WEBHOOK_INVALID_NAME", fmt.Sprintf("webhook name '%s' is missing or duplicated", webhook.Name))
+		}
+		seen[webhook.Name] = true
+		input := &WebhookInput{URL: webhook.URL, KeyPattern: webhook.KeyPattern, Namespace: webhook.Namespace, Events: webhook.Events}
+		if err := ValidateWebhookInput(input); err != nil {
+			return nil, err
+		}
+		if webhook.Secret.Value == "" {
+			return nil, NewValidationError("WEBHOOK_REQUIRES_SECRET", fmt.Sprintf("webhook '%s' requires a secret", webhook.Name))
+		}
+		if !HasNamespace(namespaces, webhook.Namespace) {
+			return nil, NewValidationError("WEBHOOK_INVALID_NAMESPACE", fmt.Sprintf("namespace '%s' is not configured", webhook.Namespace))
+		}
+		webhooks = append(webhooks, &Webhook{
+			ID:         webhook.Name,
+			URL:        webhook.URL,
+			KeyPattern: webhook.KeyPattern,
+			Namespace:  webhook.Namespace,
+			Events:     webhook.Events,
+			Secret:     webhook.Secret.Value,
+		})
+	}
+	return webhooks, nil
+}
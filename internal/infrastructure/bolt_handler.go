@@ -0,0 +1,166 @@
+package infrastructure
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// boltFile is the name of the database file in the data dir.
+const boltFile = "locks.db"
+
+// boltBucket is the bucket of the database that holds the entries.
+var boltBucket = []byte("entries")
+
+// boltSweepFlushInterval is the time since the last commit after which the writes of the expiry sweeper
+// are committed on their own instead of waiting for the next operation.
+const boltSweepFlushInterval = time.Second
+
+// BoltHandler implements lock storage in a bbolt database file of the data dir, so locks survive a restart
+// of the service without Redis. It works like the MemoryHandler on a copy of the entries in memory, every
+// operation is committed to the file with fsync before it takes effect, so a crash loses no acknowledged
+// write and never leaves half of an operation behind. Locks that expired while the service was down are
+// removed and reported by the expiry sweeper after the start.
+//
+// The removals of the sweeper are collected and committed with the next operation that writes, with a sweep
+// boltSweepFlushInterval after the last commit or on Close. After a crash they are recomputed from the
+// expiries, the expired locks may be reported again.
+// Reads are served from memory, but every write waits for its fsync under the exclusive lock of the
+// MemoryHandler, so writes are serialized to the fsync rate of the disk: a few hundred per second on
+// a local SSD, far less on network storage. Reads wait while a write commits.
+type BoltHandler struct {
+	*MemoryHandler
+	db *bolt.DB
+	// pending are the deferred writes of the sweeper since flushed, guarded by the lock of the MemoryHandler
+	pending map[string]*memoryEntry
+	flushed time.Time
+}
+
+// NewBoltHandler opens the database in the data dir of the configuration, it is created if it does not exist.
+// It fails if the database can't be opened, f.e. while another process uses it.
+func NewBoltHandler(config domain.Config, logger domain.Logger) (*BoltHandler, error) {
+	if err := os.MkdirAll(config.DataDir, 0o700); err != nil {
+		const msg = "NewBoltHandler - os.MkdirAll > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	path := filepath.Join(config.DataDir, boltFile)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		const msg = "NewBoltHandler - bolt.Open(%s) > %w"
+		return nil, fmt.Errorf(msg, path, err)
+	}
+	entries := map[string]memoryEntry{}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key []byte, value []byte) error {
+			entry, err := decodeBoltEntry(value)
+			if err != nil {
+				return fmt.Errorf("%s > %w", key, err)
+			}
+			entries[string(key)] = entry
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		const msg = "NewBoltHandler - db.Update > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	logger.Info(fmt.Sprintf("NewBoltHandler - loaded %d entries from %s", len(entries), path))
+	h := &BoltHandler{db: db, pending: map[string]*memoryEntry{}, flushed: time.Now()}
+	h.MemoryHandler = newMemoryHandler(logger, entries, h.persist)
+	return h, nil
+}
+
+// persist commits the writes of a unit of work to the database in one transaction together with the
+// pending writes of the sweeper. Deferrable writes are only collected until boltSweepFlushInterval passed.
+func (h *BoltHandler) persist(writes map[string]*memoryEntry, deferrable bool) error {
+	if deferrable && time.Since(h.flushed) < boltSweepFlushInterval {
+		for key, entry := range writes {
+			h.pending[key] = entry
+		}
+		return nil
+	}
+	batch := make(map[string]*memoryEntry, len(h.pending)+len(writes))
+	for key, entry := range h.pending {
+		batch[key] = entry
+	}
+	for key, entry := range writes {
+		batch[key] = entry
+	}
+	if err := h.commit(batch); err != nil {
+		return err
+	}
+	h.pending = map[string]*memoryEntry{}
+	h.flushed = time.Now()
+	return nil
+}
+
+// commit writes the entries to the database in one transaction, nil entries are removed.
+func (h *BoltHandler) commit(writes map[string]*memoryEntry) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for key, entry := range writes {
+			var err error
+			if entry == nil {
+				err = bucket.Delete([]byte(key))
+			} else {
+				err = bucket.Put([]byte(key), encodeBoltEntry(*entry))
+			}
+			if err != nil {
+				return fmt.Errorf("BoltHandler.commit(%s) > %w", key, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops the expiry sweeper, commits its pending writes and closes the database.
+func (h *BoltHandler) Close() error {
+	h.MemoryHandler.Close()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.pending) > 0 {
+		if err := h.commit(h.pending); err != nil {
+			h.db.Close()
+			const msg = "BoltHandler.Close - h.commit > %w"
+			return fmt.Errorf(msg, err)
+		}
+		h.pending = map[string]*memoryEntry{}
+	}
+	return h.db.Close()
+}
+
+// encodeBoltEntry returns the stored form of the entry, its expiry in Unix nanoseconds (0 if it never expires)
+// followed by its value.
+func encodeBoltEntry(entry memoryEntry) []byte {
+	var expireAt int64
+	if !entry.expireAt.IsZero() {
+		expireAt = entry.expireAt.UnixNano()
+	}
+	data := make([]byte, 8, 8+len(entry.value))
+	binary.BigEndian.PutUint64(data, uint64(expireAt))
+	return append(data, entry.value...)
+}
+
+// decodeBoltEntry returns the entry of its stored form, see encodeBoltEntry.
+func decodeBoltEntry(data []byte) (memoryEntry, error) {
+	if len(data) < 8 {
+		return memoryEntry{}, fmt.Errorf("decodeBoltEntry - entry of %d bytes is truncated", len(data))
+	}
+	var entry memoryEntry
+	if expireAt := int64(binary.BigEndian.Uint64(data)); expireAt != 0 {
+		entry.expireAt = time.Unix(0, expireAt)
+	}
+	entry.value = string(data[8:])
+	return entry, nil
+}
@@ -0,0 +1,142 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// newTestBoltHandler opens a BoltHandler on the data dir that is closed when the test ends.
+func newTestBoltHandler(t *testing.T, dataDir string) *BoltHandler {
+	var config domain.Config
+	config.DataDir = dataDir
+	handler, err := NewBoltHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	return handler
+}
+
+func TestBoltHandlerKeepsLocksAcrossRestart(t *testing.T) {
+	// Arrange
+	dataDir := t.TempDir()
+	ctx := context.Background()
+	handler := newTestBoltHandler(t, dataDir)
+	first, err := handler.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+	assert.NoError(t, err)
+	values, err := handler.Get(ctx, "deploy")
+	assert.NoError(t, err)
+	assert.NoError(t, handler.Close())
+
+	// Act
+	restarted := newTestBoltHandler(t, dataDir)
+	kept, err := restarted.Get(ctx, "deploy")
+	assert.NoError(t, err)
+	released, releaseErr := restarted.CompareAndDelete(ctx, "deploy", kept[0])
+	second, acquireErr := restarted.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, releaseErr)
+	assert.NoError(t, acquireErr)
+	assert.Equal(t, int64(1), first)
+	assert.Equal(t, values, kept)
+	assert.True(t, released)
+	assert.Equal(t, int64(2), second)
+}
+
+func TestBoltHandlerReportsLocksExpiredWhileDown(t *testing.T) {
+	// Arrange
+	dataDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler := newTestBoltHandler(t, dataDir)
+	_, err := handler.Acquire(ctx, "deploy/api", "ci", "", testRedlockValue(t, "ci"), 20*time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, handler.Close())
+	time.Sleep(50 * time.Millisecond)
+
+	// Act
+	restarted := newTestBoltHandler(t, dataDir)
+	_, expired, err := restarted.SubscribeEvents(ctx, "deploy/")
+	assert.NoError(t, err)
+
+	// Assert
+	select {
+	case key := <-expired:
+		assert.Equal(t, "deploy/api", key)
+	case <-time.After(time.Second):
+		t.Fatal("expired lock was not reported")
+	}
+	count, err := restarted.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestBoltHandlerDefersSweeperWrites(t *testing.T) {
+	// Arrange
+	dataDir := t.TempDir()
+	ctx := context.Background()
+	handler := newTestBoltHandler(t, dataDir)
+	_, err := handler.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), 20*time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(300 * time.Millisecond)
+
+	// Act
+	values, getErr := handler.Get(ctx, "deploy")
+	stored := false
+	viewErr := handler.db.View(func(tx *bolt.Tx) error {
+		stored = tx.Bucket(boltBucket).Get([]byte("lock:deploy")) != nil
+		return nil
+	})
+	closeErr := handler.Close()
+	restarted := newTestBoltHandler(t, dataDir)
+	flushed := false
+	flushedErr := restarted.db.View(func(tx *bolt.Tx) error {
+		flushed = tx.Bucket(boltBucket).Get([]byte("lock:deploy")) == nil
+		return nil
+	})
+
+	// Assert
+	assert.NoError(t, getErr)
+	assert.NoError(t, viewErr)
+	assert.NoError(t, closeErr)
+	assert.NoError(t, flushedErr)
+	assert.Nil(t, values)
+	assert.True(t, stored)
+	assert.True(t, flushed)
+}
+
+func TestBoltHandlerDiscardsFailedWrite(t *testing.T) {
+	// Arrange
+	handler := newTestBoltHandler(t, t.TempDir())
+	ctx := context.Background()
+	assert.NoError(t, handler.db.Close())
+
+	// Act
+	_, err := handler.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+	values, getErr := handler.Get(ctx, "deploy")
+
+	// Assert
+	assert.Error(t, err)
+	assert.NoError(t, getErr)
+	assert.Nil(t, values)
+}
+
+func TestNewBoltHandlerDataDirInUse(t *testing.T) {
+	// Arrange
+	dataDir := t.TempDir()
+	newTestBoltHandler(t, dataDir)
+	var config domain.Config
+	config.DataDir = dataDir
+
+	// Act
+	handler, err := NewBoltHandler(config, NewMockLogger())
+
+	// Assert
+	assert.Nil(t, handler)
+	assert.Error(t, err)
+}
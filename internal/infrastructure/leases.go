@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// shadowGrace is the time the shadow copy of a lock outlives the lock.
+const shadowGrace = time.Minute
+
+// leaseGrace is the time the lease of an owner outlives the lock.
+const leaseGrace = 24 * time.Hour
+
+// endedLeases returns the leases of the holders of the lock value by owner, ending at the latest at at,
+// so holders whose lock is taken from them learn at once that they lost it.
+func endedLeases(value string, at time.Time) (map[string]string, error) {
+	var holders []*domain.Lock
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		holders = []*domain.Lock{{}}
+		if err := json.Unmarshal([]byte(value), holders[0]); err != nil {
+			return nil, fmt.Errorf("endedLeases - json.Unmarshal > %w", err)
+		}
+	} else if err := json.Unmarshal([]byte(value), &holders); err != nil {
+		return nil, fmt.Errorf("endedLeases - json.Unmarshal > %w", err)
+	}
+	leases := make(map[string]string, len(holders))
+	for _, holder := range holders {
+		if holder.ExpireAt.After(at) {
+			holder.ExpireAt = at.UTC()
+		}
+		lease, err := json.Marshal(holder)
+		if err != nil {
+			return nil, fmt.Errorf("endedLeases - json.Marshal > %w", err)
+		}
+		leases[holder.Owner] = string(lease)
+	}
+	return leases, nil
+}
@@ -0,0 +1,1458 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// memorySweepInterval is the interval in which the expired keys of a MemoryHandler are removed and reported.
+const memorySweepInterval = 100 * time.Millisecond
+
+// memorySubscriberBuffer is the number of events a subscriber of a MemoryHandler may fall behind,
+// further events are dropped until it catches up.
+const memorySubscriberBuffer = 100
+
+// MemoryHandler implements lock storage in the memory of the process, for single node deployments
+// and tests that run without Redis. It keeps the same keys and semantics as the RedisHandler,
+// every operation runs atomically and expired keys are removed and reported by a background sweeper.
+// Reads run concurrently with each other, only writes and due sweeps take the exclusive lock.
+type MemoryHandler struct {
+	logger domain.Logger
+	// mu guards the entries with their index and expiries, the watchers and the subscribers
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+	// index holds the keys of the entries in lexical order, expiries the ones that expire by their expiry
+	index       memoryKeys
+	expiries    *memoryExpiries
+	watchers    map[string]map[chan struct{}]struct{}
+	subscribers map[*memorySubscriber]struct{}
+	// persist durably stores the writes of every unit of work before they take effect,
+	// without it they are kept in memory only. Deferrable writes, the ones of the sweeper,
+	// may be stored later together with others, they are recomputed from the expiries after a crash.
+	persist  func(writes map[string]*memoryEntry, deferrable bool) error
+	stop     chan struct{}
+	stopOnce sync.Once
+	swept    chan struct{}
+}
+
+// memoryEntry is a value of the MemoryHandler, it expires at expireAt unless that is zero.
+type memoryEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+// expired reports whether the entry expired at now.
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// memorySubscriber receives the events of the locks with keyPrefix in the namespace with the key prefix namespace.
+type memorySubscriber struct {
+	namespace string
+	keyPrefix string
+	values    chan string
+	expired   chan string
+}
+
+// memoryWaiter is an owner in the wait queue of a lock until its deadline.
+type memoryWaiter struct {
+	Owner    string    `json:"owner"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// memoryPermit is the permit of a semaphore held by an owner until expireAt.
+type memoryPermit struct {
+	Owner    string    `json:"owner"`
+	Value    string    `json:"value"`
+	ExpireAt time.Time `json:"expireAt"`
+}
+
+// memoryHistoryEntry is an entry of the audit history of a lock added at At.
+type memoryHistoryEntry struct {
+	At    time.Time `json:"at"`
+	Entry string    `json:"entry"`
+}
+
+// NewMemoryHandler creates a new MemoryHandler and starts its expiry sweeper, Close stops it.
+func NewMemoryHandler(logger domain.Logger) *MemoryHandler {
+	return newMemoryHandler(logger, map[string]memoryEntry{}, nil)
+}
+
+// newMemoryHandler creates a MemoryHandler that starts with the entries and stores its writes with persist.
+func newMemoryHandler(logger domain.Logger, entries map[string]memoryEntry, persist func(writes map[string]*memoryEntry, deferrable bool) error) *MemoryHandler {
+	h := &MemoryHandler{
+		logger:      logger,
+		entries:     entries,
+		expiries:    newMemoryExpiries(),
+		watchers:    map[string]map[chan struct{}]struct{}{},
+		subscribers: map[*memorySubscriber]struct{}{},
+		persist:     persist,
+		stop:        make(chan struct{}),
+		swept:       make(chan struct{}),
+	}
+	for key, entry := range entries {
+		h.index = append(h.index, key)
+		h.expiries.set(key, entry.expireAt)
+	}
+	sort.Strings(h.index)
+	go h.sweep()
+	return h
+}
+
+// Close stops the expiry sweeper and waits for it to finish.
+func (h *MemoryHandler) Close() error {
+	h.stopOnce.Do(func() { close(h.stop) })
+	<-h.swept
+	return nil
+}
+
+// sweep removes the expired keys every memorySweepInterval until the handler is closed,
+// so expired locks are reported even if nobody accesses them. The locks of an expired session
+// are released at once like in DeleteSession.
+func (h *MemoryHandler) sweep() {
+	defer close(h.swept)
+	ticker := time.NewTicker(memorySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			if !h.sweepDue(time.Now()) {
+				continue
+			}
+			err := h.commit(true, func(tx *memoryTx) error {
+				for _, key := range tx.keys("session-locks:") {
+					id := strings.TrimPrefix(key, "session-locks:")
+					if _, live := tx.get(h.sessionKeys(id)[0]); !live {
+						if _, err := h.releaseSession(tx, id); err != nil {
+							return err
+						}
+					}
+				}
+				for _, key := range h.expiries.due(tx.now) {
+					tx.lookup(key)
+				}
+				return nil
+			})
+			if err != nil {
+				h.logger.Warn("MemoryHandler.sweep - h.commit > " + err.Error())
+			}
+		}
+	}
+}
+
+// sweepDue reports whether the sweeper has work at now, a key expired or a session expired
+// while its locks are still indexed. It only takes the read lock, so an idle sweep blocks no reader.
+func (h *MemoryHandler) sweepDue(now time.Time) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.expiries.hasDue(now) {
+		return true
+	}
+	for _, key := range h.index.withPrefix("session-locks:") {
+		session, ok := h.entries[h.sessionKeys(strings.TrimPrefix(key, "session-locks:"))[0]]
+		if !ok || session.expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryTx is an atomic unit of work on the entries of a MemoryHandler, its writes only replace the entries
+// once it succeeded. It records the keys it changed and the keys it found expired, so watchers and
+// subscribers are notified when it ends. A read-only unit of work sees expired entries as removed
+// but leaves their removal to the sweeper.
+type memoryTx struct {
+	entries map[string]memoryEntry
+	index   memoryKeys
+	// writes are the entries stored by the unit of work, nil for removed ones
+	writes   map[string]*memoryEntry
+	readOnly bool
+	now      time.Time
+	changed  []string
+	expired  []string
+}
+
+// lookup returns the entry of the key, an expired entry is removed and recorded.
+func (tx *memoryTx) lookup(key string) (memoryEntry, bool) {
+	entry, ok := tx.entries[key]
+	if written, found := tx.writes[key]; found {
+		if written == nil {
+			return memoryEntry{}, false
+		}
+		entry, ok = *written, true
+	}
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if entry.expired(tx.now) {
+		if !tx.readOnly {
+			tx.writes[key] = nil
+			tx.expired = append(tx.expired, key)
+		}
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// get returns the value of the key and whether it exists.
+func (tx *memoryTx) get(key string) (string, bool) {
+	entry, ok := tx.lookup(key)
+	return entry.value, ok
+}
+
+// ttl returns the time the key lives on, 0 if it does not exist or never expires.
+func (tx *memoryTx) ttl(key string) time.Duration {
+	entry, ok := tx.lookup(key)
+	if !ok || entry.expireAt.IsZero() {
+		return 0
+	}
+	return entry.expireAt.Sub(tx.now)
+}
+
+// set stores the value of the key for ttl, it never expires if ttl is not positive.
+func (tx *memoryTx) set(key string, value string, ttl time.Duration) {
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = tx.now.Add(ttl)
+	}
+	tx.setUntil(key, value, expireAt)
+}
+
+// setUntil stores the value of the key until expireAt, it never expires if expireAt is zero.
+func (tx *memoryTx) setUntil(key string, value string, expireAt time.Time) {
+	tx.writes[key] = &memoryEntry{value: value, expireAt: expireAt}
+	tx.changed = append(tx.changed, key)
+}
+
+// del removes the key and reports whether it existed.
+func (tx *memoryTx) del(key string) bool {
+	if _, ok := tx.lookup(key); !ok {
+		return false
+	}
+	tx.writes[key] = nil
+	tx.changed = append(tx.changed, key)
+	return true
+}
+
+// keys returns the existing keys with the prefix in lexical order.
+func (tx *memoryTx) keys(prefix string) []string {
+	candidates := map[string]struct{}{}
+	for _, key := range tx.index.withPrefix(prefix) {
+		candidates[key] = struct{}{}
+	}
+	for key := range tx.writes {
+		if strings.HasPrefix(key, prefix) {
+			candidates[key] = struct{}{}
+		}
+	}
+	keys := []string{}
+	for key := range candidates {
+		if _, ok := tx.lookup(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getJSON decodes the value of the key into v and reports whether the key exists.
+func (tx *memoryTx) getJSON(key string, v interface{}) (bool, error) {
+	value, ok := tx.get(key)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return false, fmt.Errorf("memoryTx.getJSON(%s) - json.Unmarshal > %w", key, err)
+	}
+	return true, nil
+}
+
+// setJSON stores v encoded as JSON in the key until expireAt.
+func (tx *memoryTx) setJSON(key string, v interface{}, expireAt time.Time) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("memoryTx.setJSON(%s) - json.Marshal > %w", key, err)
+	}
+	tx.setUntil(key, string(value), expireAt)
+	return nil
+}
+
+// update runs fn as one atomic unit of work, nothing of it is kept if fn or persisting its writes fails.
+// It notifies the watchers of the keys it changed and the watchers and subscribers of the keys that expired.
+func (h *MemoryHandler) update(fn func(tx *memoryTx) error) error {
+	return h.commit(false, fn)
+}
+
+// commit runs fn as one atomic unit of work like update, the persisting of its writes may be deferred
+// if they are deferrable.
+func (h *MemoryHandler) commit(deferrable bool, fn func(tx *memoryTx) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tx := &memoryTx{entries: h.entries, index: h.index, writes: map[string]*memoryEntry{}, now: time.Now()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if h.persist != nil && len(tx.writes) > 0 {
+		if err := h.persist(tx.writes, deferrable); err != nil {
+			return fmt.Errorf("MemoryHandler.commit - h.persist > %w", err)
+		}
+	}
+	for key, entry := range tx.writes {
+		if entry == nil {
+			delete(h.entries, key)
+			h.index.remove(key)
+			h.expiries.remove(key)
+			continue
+		}
+		if _, ok := h.entries[key]; !ok {
+			h.index.add(key)
+		}
+		h.entries[key] = *entry
+		h.expiries.set(key, entry.expireAt)
+	}
+	for _, key := range append(tx.changed, tx.expired...) {
+		for changes := range h.watchers[key] {
+			// coalesce notifications, the receiver only needs to know that something changed
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+	for _, key := range tx.expired {
+		for subscriber := range h.subscribers {
+			lockPrefix := subscriber.namespace + "lock:"
+			if strings.HasPrefix(key, lockPrefix) && strings.HasPrefix(strings.TrimPrefix(key, lockPrefix), subscriber.keyPrefix) {
+				h.deliver(subscriber.expired, strings.TrimPrefix(key, lockPrefix))
+			}
+		}
+	}
+	return nil
+}
+
+// view runs fn as a read-only unit of work, views run concurrently with each other.
+// fn must not write, expired entries it meets are removed by the sweeper.
+func (h *MemoryHandler) view(fn func(tx *memoryTx) error) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return fn(&memoryTx{entries: h.entries, index: h.index, readOnly: true, now: time.Now()})
+}
+
+// deliver sends the value to a subscriber without waiting for it, a subscriber that fell behind misses it.
+func (h *MemoryHandler) deliver(out chan string, value string) {
+	select {
+	case out <- value:
+	default:
+		h.logger.Warn("MemoryHandler.deliver - subscriber fell behind, dropped " + value)
+	}
+}
+
+// namespacePrefix returns the prefix of the lock keys in the namespace of ctx, the keys of the
+// default namespace carry no namespace.
+func (h *MemoryHandler) namespacePrefix(ctx context.Context) string {
+	ns := domain.NamespaceFromContext(ctx)
+	if ns.Name == domain.DefaultNamespace {
+		return ""
+	}
+	return ns.Name + "."
+}
+
+// lockKey returns the key that stores the lock for key in the namespace of ctx.
+func (h *MemoryHandler) lockKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock:" + key
+}
+
+// fenceKey returns the key of the fencing counter for key, it never expires
+// so tokens keep increasing across acquisitions.
+func (h *MemoryHandler) fenceKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "fence:" + key
+}
+
+// queueKey returns the key of the wait queue for key, the waiters in arrival order.
+func (h *MemoryHandler) queueKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "queue:" + key
+}
+
+// shadowKey returns the key of the shadow copy of the lock for key, it outlives the lock
+// so its last holders are known when the lock expires.
+func (h *MemoryHandler) shadowKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock-shadow:" + key
+}
+
+// leaseKey returns the key of the last lease of every owner of the lock for key.
+func (h *MemoryHandler) leaseKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock-leases:" + key
+}
+
+// historyKey returns the key of the audit history of the lock for key.
+func (h *MemoryHandler) historyKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock-history:" + key
+}
+
+// semaphoreKeys returns the keys of a semaphore, its definition and its permits.
+func (h *MemoryHandler) semaphoreKeys(key string) []string {
+	return []string{"semaphore:" + key, "semaphore-permits:" + key}
+}
+
+// sessionKeys returns the keys of a session, its definition and the index of the locks it holds.
+func (h *MemoryHandler) sessionKeys(id string) []string {
+	return []string{"session:" + id, "session-locks:" + id}
+}
+
+// webhookKeys returns the keys of a webhook, its definition and its delivery log.
+func (h *MemoryHandler) webhookKeys(id string) []string {
+	return []string{"webhook:" + id, "webhook-deliveries:" + id}
+}
+
+// queue returns the waiters of the wait queue, waiters whose deadline passed are removed.
+func (h *MemoryHandler) queue(tx *memoryTx, key string) ([]memoryWaiter, error) {
+	var waiters []memoryWaiter
+	if _, err := tx.getJSON(key, &waiters); err != nil {
+		return nil, err
+	}
+	waiting := waiters[:0:0]
+	for _, waiter := range waiters {
+		if tx.now.Before(waiter.Deadline) {
+			waiting = append(waiting, waiter)
+		}
+	}
+	if len(waiting) == len(waiters) || tx.readOnly {
+		return waiting, nil
+	}
+	return waiting, h.setQueue(tx, key, waiting)
+}
+
+// setQueue stores the waiters of the wait queue, it expires with the last deadline.
+func (h *MemoryHandler) setQueue(tx *memoryTx, key string, waiters []memoryWaiter) error {
+	if len(waiters) == 0 {
+		tx.del(key)
+		return nil
+	}
+	var last time.Time
+	for _, waiter := range waiters {
+		if waiter.Deadline.After(last) {
+			last = waiter.Deadline
+		}
+	}
+	return tx.setJSON(key, waiters, last)
+}
+
+// dequeue removes the owner from the wait queue.
+func (h *MemoryHandler) dequeue(tx *memoryTx, key string, owner string) error {
+	waiters, err := h.queue(tx, key)
+	if err != nil {
+		return err
+	}
+	for i, waiter := range waiters {
+		if waiter.Owner == owner {
+			return h.setQueue(tx, key, append(waiters[:i:i], waiters[i+1:]...))
+		}
+	}
+	return nil
+}
+
+// incr increments the counter of the key and returns it.
+func (h *MemoryHandler) incr(tx *memoryTx, key string) (int64, error) {
+	var counter int64
+	if value, ok := tx.get(key); ok {
+		var err error
+		if counter, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, fmt.Errorf("MemoryHandler.incr - strconv.ParseInt > %w", err)
+		}
+	}
+	counter++
+	tx.set(key, strconv.FormatInt(counter, 10), 0)
+	return counter, nil
+}
+
+// stamp assigns the token to every holder of the value without a fencing token, holders of the session
+// expire with it. Holders that all have a fencing token are returned as given.
+func stamp(value string, token int64, session *domain.Session) (string, error) {
+	var holders []*domain.Lock
+	if err := json.Unmarshal([]byte(value), &holders); err != nil {
+		return "", fmt.Errorf("stamp - json.Unmarshal > %w", err)
+	}
+	stamped := false
+	for _, holder := range holders {
+		if holder.FencingToken != 0 {
+			continue
+		}
+		holder.FencingToken, stamped = token, true
+		if session != nil && holder.SessionID == session.ID {
+			holder.ExpireAt = session.ExpireAt
+		}
+	}
+	if !stamped {
+		return value, nil
+	}
+	result, err := json.Marshal(holders)
+	if err != nil {
+		return "", fmt.Errorf("stamp - json.Marshal > %w", err)
+	}
+	return string(result), nil
+}
+
+// setLock stores the lock value in lockKey for ttl with its shadow copy, which outlives it by shadowGrace,
+// and records the lease of every holder, which outlives it by leaseGrace.
+func (h *MemoryHandler) setLock(tx *memoryTx, lockKey string, value string, ttl time.Duration) error {
+	tx.set(lockKey, value, ttl)
+	tx.set(relatedKey(lockKey, "lock-shadow:"), value, ttl+shadowGrace)
+	var holders []*domain.Lock
+	if err := json.Unmarshal([]byte(value), &holders); err != nil {
+		return fmt.Errorf("MemoryHandler.setLock - json.Unmarshal > %w", err)
+	}
+	leaseKey := relatedKey(lockKey, "lock-leases:")
+	leases := map[string]string{}
+	if _, err := tx.getJSON(leaseKey, &leases); err != nil {
+		return err
+	}
+	for _, holder := range holders {
+		lease, err := json.Marshal(holder)
+		if err != nil {
+			return fmt.Errorf("MemoryHandler.setLock - json.Marshal > %w", err)
+		}
+		leases[holder.Owner] = string(lease)
+	}
+	return tx.setJSON(leaseKey, leases, tx.now.Add(ttl+leaseGrace))
+}
+
+// endLeases stores the ended leases of the holders of the lock value with the leases of lockKey.
+func (h *MemoryHandler) endLeases(tx *memoryTx, lockKey string, value string) error {
+	ended, err := endedLeases(value, tx.now)
+	if err != nil {
+		return err
+	}
+	leaseKey := relatedKey(lockKey, "lock-leases:")
+	leases := map[string]string{}
+	if _, err := tx.getJSON(leaseKey, &leases); err != nil {
+		return err
+	}
+	for owner, lease := range ended {
+		leases[owner] = lease
+	}
+	return tx.setJSON(leaseKey, leases, tx.now.Add(max(tx.ttl(leaseKey), leaseGrace)))
+}
+
+// forgetLease removes the lease of owner from the leases of lockKey.
+func (h *MemoryHandler) forgetLease(tx *memoryTx, lockKey string, owner string) error {
+	leaseKey := relatedKey(lockKey, "lock-leases:")
+	leases := map[string]string{}
+	if ok, err := tx.getJSON(leaseKey, &leases); err != nil || !ok {
+		return err
+	}
+	if _, ok := leases[owner]; !ok {
+		return nil
+	}
+	delete(leases, owner)
+	if len(leases) == 0 {
+		tx.del(leaseKey)
+		return nil
+	}
+	return tx.setJSON(leaseKey, leases, tx.now.Add(tx.ttl(leaseKey)))
+}
+
+// relatedKey returns the key with the kind prefix, f.e. "lock-shadow:", that belongs to the lock stored in lockKey.
+func relatedKey(lockKey string, kind string) string {
+	at := strings.Index(lockKey, "lock:")
+	return lockKey[:at] + kind + lockKey[at+len("lock:"):]
+}
+
+// check returns 1 if owner can acquire the lock that is expected to hold expected, 0 if others are
+// queued first and -1 if the current value did not match.
+func (h *MemoryHandler) check(ctx context.Context, tx *memoryTx, key string, owner string, expected string) (int64, error) {
+	waiters, err := h.queue(tx, h.queueKey(ctx, key))
+	if err != nil {
+		return 0, err
+	}
+	if current, _ := tx.get(h.lockKey(ctx, key)); current != expected {
+		return -1, nil
+	}
+	if len(waiters) > 0 && waiters[0].Owner != owner {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// store removes owner from the wait queue and stores the value with the next fencing token, it returns the token.
+func (h *MemoryHandler) store(ctx context.Context, tx *memoryTx, key string, owner string, value string, ttl time.Duration, session *domain.Session) (int64, error) {
+	if err := h.dequeue(tx, h.queueKey(ctx, key), owner); err != nil {
+		return 0, err
+	}
+	token, err := h.incr(tx, h.fenceKey(ctx, key))
+	if err != nil {
+		return 0, err
+	}
+	value, err = stamp(value, token, session)
+	if err != nil {
+		return 0, err
+	}
+	return token, h.setLock(tx, h.lockKey(ctx, key), value, ttl)
+}
+
+// Acquire atomically stores the holders of a lock if its current value still equals expected,
+// an empty expected value means the lock must not exist. It stamps new holders with the next fencing token.
+// Waiters in the queue of the key take precedence, only the first of them can acquire it.
+// It returns the fencing token, 0 if others are queued first or -1 if the current value did not match.
+func (h *MemoryHandler) Acquire(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (int64, error) {
+	var token int64
+	err := h.update(func(tx *memoryTx) error {
+		var err error
+		if token, err = h.check(ctx, tx, key, owner, expected); err != nil || token != 1 {
+			return err
+		}
+		token, err = h.store(ctx, tx, key, owner, value, ttl, nil)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.Acquire - h.update > %w", err)
+	}
+	return token, nil
+}
+
+// AcquireMultiple acquires the locks of all keys for owner at once, or none of them. Every key is checked
+// like in Acquire against its expected value, value and ttl are stored for it if all checks pass.
+// It returns the fencing tokens of all keys, or if any check failed per key 1 if it passed,
+// 0 if others are queued first or -1 if the current value did not match.
+func (h *MemoryHandler) AcquireMultiple(ctx context.Context, owner string, keys []string, expected []string, values []string, ttls []time.Duration) ([]int64, error) {
+	results := make([]int64, len(keys))
+	err := h.update(func(tx *memoryTx) error {
+		failed := false
+		for i, key := range keys {
+			var err error
+			if results[i], err = h.check(ctx, tx, key, owner, expected[i]); err != nil {
+				return err
+			}
+			failed = failed || results[i] != 1
+		}
+		if failed {
+			return nil
+		}
+		for i, key := range keys {
+			var err error
+			if results[i], err = h.store(ctx, tx, key, owner, values[i], ttls[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MemoryHandler.AcquireMultiple - h.update > %w", err)
+	}
+	return results, nil
+}
+
+// Takeover replaces the lock with value if its current value equals expected, regardless of the wait queue.
+// It stamps new holders with the next fencing token and returns it, or -1 if the current value did not match.
+// The leases of the replaced holders end at once.
+func (h *MemoryHandler) Takeover(ctx context.Context, key string, expected string, value string, ttl time.Duration) (int64, error) {
+	var token int64
+	err := h.update(func(tx *memoryTx) error {
+		if current, _ := tx.get(h.lockKey(ctx, key)); current != expected {
+			token = -1
+			return nil
+		}
+		if err := h.endLeases(tx, h.lockKey(ctx, key), expected); err != nil {
+			return err
+		}
+		fence, err := h.incr(tx, h.fenceKey(ctx, key))
+		if err != nil {
+			return err
+		}
+		value, err := stamp(value, fence, nil)
+		if err != nil {
+			return err
+		}
+		token = fence
+		return h.setLock(tx, h.lockKey(ctx, key), value, ttl)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.Takeover - h.update > %w", err)
+	}
+	return token, nil
+}
+
+// Get retrieves a lock by key in the namespace of ctx. If key is "*", returns all locks of the namespace.
+func (h *MemoryHandler) Get(ctx context.Context, key string) ([]string, error) {
+	var values []string
+	err := h.view(func(tx *memoryTx) error {
+		if key == "*" {
+			values = []string{}
+			for _, lockKey := range tx.keys(h.lockKey(ctx, "")) {
+				value, _ := tx.get(lockKey)
+				values = append(values, value)
+			}
+			return nil
+		}
+		if value, ok := tx.get(h.lockKey(ctx, key)); ok {
+			values = []string{value}
+		}
+		return nil
+	})
+	return values, err
+}
+
+// Del removes a lock by key.
+func (h *MemoryHandler) Del(ctx context.Context, key string) error {
+	return h.update(func(tx *memoryTx) error {
+		tx.del(h.lockKey(ctx, key))
+		return nil
+	})
+}
+
+// CompareAndDelete atomically removes a lock if its stored value still equals expected.
+// It reports whether the lock was removed.
+func (h *MemoryHandler) CompareAndDelete(ctx context.Context, key string, expected string) (bool, error) {
+	deleted := false
+	err := h.update(func(tx *memoryTx) error {
+		if current, ok := tx.get(h.lockKey(ctx, key)); ok && current == expected {
+			deleted = tx.del(h.lockKey(ctx, key))
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// CompareAndSwap atomically replaces a lock and its TTL if its stored value still equals expected.
+// It reports whether the lock was replaced.
+func (h *MemoryHandler) CompareAndSwap(ctx context.Context, key string, expected string, value string, ttl time.Duration) (bool, error) {
+	swapped := false
+	err := h.update(func(tx *memoryTx) error {
+		if current, ok := tx.get(h.lockKey(ctx, key)); !ok || current != expected {
+			return nil
+		}
+		swapped = true
+		return h.setLock(tx, h.lockKey(ctx, key), value, ttl)
+	})
+	if err != nil {
+		return false, fmt.Errorf("MemoryHandler.CompareAndSwap - h.update > %w", err)
+	}
+	return swapped, nil
+}
+
+// CompareAndRelease atomically replaces a lock and its TTL like CompareAndSwap, or removes it if value is empty,
+// and removes the lease of owner at once. It reports whether the lock was replaced.
+func (h *MemoryHandler) CompareAndRelease(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (bool, error) {
+	released := false
+	err := h.update(func(tx *memoryTx) error {
+		if current, ok := tx.get(h.lockKey(ctx, key)); !ok || current != expected {
+			return nil
+		}
+		released = true
+		if value == "" {
+			tx.del(h.lockKey(ctx, key))
+		} else if err := h.setLock(tx, h.lockKey(ctx, key), value, ttl); err != nil {
+			return err
+		}
+		return h.forgetLease(tx, h.lockKey(ctx, key), owner)
+	})
+	if err != nil {
+		return false, fmt.Errorf("MemoryHandler.CompareAndRelease - h.update > %w", err)
+	}
+	return released, nil
+}
+
+// CompareAndRevoke atomically removes a lock if its stored value still equals expected and ends the leases
+// of its holders at once. It reports whether the lock was removed.
+func (h *MemoryHandler) CompareAndRevoke(ctx context.Context, key string, expected string) (bool, error) {
+	revoked := false
+	err := h.update(func(tx *memoryTx) error {
+		if current, ok := tx.get(h.lockKey(ctx, key)); !ok || current != expected {
+			return nil
+		}
+		revoked = tx.del(h.lockKey(ctx, key))
+		return h.endLeases(tx, h.lockKey(ctx, key), expected)
+	})
+	if err != nil {
+		return false, fmt.Errorf("MemoryHandler.CompareAndRevoke - h.update > %w", err)
+	}
+	return revoked, nil
+}
+
+// Watch signals on the returned channel whenever the lock is written, deleted or expires and whenever
+// its wait queue changes. The subscription ends when ctx is done.
+func (h *MemoryHandler) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	changes := make(chan struct{}, 1)
+	keys := []string{h.lockKey(ctx, key), h.queueKey(ctx, key)}
+	h.mu.Lock()
+	for _, key := range keys {
+		if h.watchers[key] == nil {
+			h.watchers[key] = map[chan struct{}]struct{}{}
+		}
+		h.watchers[key][changes] = struct{}{}
+	}
+	h.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, key := range keys {
+			delete(h.watchers[key], changes)
+			if len(h.watchers[key]) == 0 {
+				delete(h.watchers, key)
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// Enqueue adds the owner to the wait queue of a lock until deadline, an owner that is already queued keeps its place.
+// It returns the zero based position of the owner in the queue.
+func (h *MemoryHandler) Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error) {
+	position := 0
+	err := h.update(func(tx *memoryTx) error {
+		waiters, err := h.queue(tx, h.queueKey(ctx, key))
+		if err != nil {
+			return err
+		}
+		position = len(waiters)
+		for i, waiter := range waiters {
+			if waiter.Owner == owner {
+				position = i
+				break
+			}
+		}
+		if position == len(waiters) {
+			waiters = append(waiters, memoryWaiter{Owner: owner, Deadline: deadline})
+		} else if deadline.After(waiters[position].Deadline) {
+			waiters[position].Deadline = deadline
+		}
+		return h.setQueue(tx, h.queueKey(ctx, key), waiters)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.Enqueue - h.update > %w", err)
+	}
+	return position, nil
+}
+
+// Dequeue removes the owner from the wait queue of a lock.
+func (h *MemoryHandler) Dequeue(ctx context.Context, key string, owner string) error {
+	return h.update(func(tx *memoryTx) error {
+		return h.dequeue(tx, h.queueKey(ctx, key), owner)
+	})
+}
+
+// Queue returns the owners waiting for a lock in arrival order.
+func (h *MemoryHandler) Queue(ctx context.Context, key string) ([]string, error) {
+	owners := []string{}
+	err := h.view(func(tx *memoryTx) error {
+		waiters, err := h.queue(tx, h.queueKey(ctx, key))
+		for _, waiter := range waiters {
+			owners = append(owners, waiter.Owner)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MemoryHandler.Queue - h.view > %w", err)
+	}
+	return owners, nil
+}
+
+// Count returns the number of locks of the namespace of ctx.
+func (h *MemoryHandler) Count(ctx context.Context) (int, error) {
+	count := 0
+	err := h.view(func(tx *memoryTx) error {
+		count = len(tx.keys(h.lockKey(ctx, "")))
+		return nil
+	})
+	return count, err
+}
+
+// GetLease returns the last lease of the owner of the lock in the namespace of ctx, it is recorded
+// whenever the owner acquires or renews the lock. It is empty if there is none.
+func (h *MemoryHandler) GetLease(ctx context.Context, key string, owner string) (string, error) {
+	var lease string
+	err := h.view(func(tx *memoryTx) error {
+		leases := map[string]string{}
+		_, err := tx.getJSON(h.leaseKey(ctx, key), &leases)
+		lease = leases[owner]
+		return err
+	})
+	return lease, err
+}
+
+// permits returns the unexpired permits of a semaphore, expired permits are reclaimed.
+func (h *MemoryHandler) permits(tx *memoryTx, key string) ([]memoryPermit, error) {
+	var permits []memoryPermit
+	if _, err := tx.getJSON(key, &permits); err != nil {
+		return nil, err
+	}
+	held := permits[:0:0]
+	for _, permit := range permits {
+		if tx.now.Before(permit.ExpireAt) {
+			held = append(held, permit)
+		}
+	}
+	if len(held) == len(permits) || tx.readOnly {
+		return held, nil
+	}
+	if len(held) == 0 {
+		tx.del(key)
+		return held, nil
+	}
+	return held, tx.setJSON(key, held, time.Time{})
+}
+
+// CreateSemaphore stores the definition of a semaphore only if the key is not used yet,
+// it reports whether the semaphore was created.
+func (h *MemoryHandler) CreateSemaphore(ctx context.Context, key string, value string) (bool, error) {
+	created := false
+	err := h.update(func(tx *memoryTx) error {
+		if _, ok := tx.get(h.semaphoreKeys(key)[0]); !ok {
+			tx.set(h.semaphoreKeys(key)[0], value, 0)
+			created = true
+		}
+		return nil
+	})
+	return created, err
+}
+
+// GetSemaphore returns the definition of a semaphore and its unexpired permits.
+// The definition is empty if the semaphore does not exist.
+func (h *MemoryHandler) GetSemaphore(ctx context.Context, key string) (string, []string, error) {
+	var definition string
+	var values []string
+	err := h.view(func(tx *memoryTx) error {
+		var ok bool
+		if definition, ok = tx.get(h.semaphoreKeys(key)[0]); !ok {
+			return nil
+		}
+		permits, err := h.permits(tx, h.semaphoreKeys(key)[1])
+		values = make([]string, 0, len(permits))
+		for _, permit := range permits {
+			values = append(values, permit.Value)
+		}
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("MemoryHandler.GetSemaphore - h.view > %w", err)
+	}
+	return definition, values, nil
+}
+
+// ListSemaphores returns the keys of all semaphores.
+func (h *MemoryHandler) ListSemaphores(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := h.view(func(tx *memoryTx) error {
+		prefix := h.semaphoreKeys("")[0]
+		keys = tx.keys(prefix)
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, prefix)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// DeleteSemaphore removes a semaphore if none of its permits are held.
+// It returns 1 if it was deleted, 0 while permits are held and -1 if it does not exist.
+func (h *MemoryHandler) DeleteSemaphore(ctx context.Context, key string) (int64, error) {
+	var result int64
+	err := h.update(func(tx *memoryTx) error {
+		keys := h.semaphoreKeys(key)
+		if _, ok := tx.get(keys[0]); !ok {
+			result = -1
+			return nil
+		}
+		permits, err := h.permits(tx, keys[1])
+		if err != nil || len(permits) > 0 {
+			return err
+		}
+		tx.del(keys[0])
+		result = 1
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.DeleteSemaphore - h.update > %w", err)
+	}
+	return result, nil
+}
+
+// AcquirePermit atomically takes one permit of a semaphore for owner, expired permits are reclaimed first.
+// It returns 1 if the permit was acquired, 0 if all permits are held, -1 if the semaphore does not exist
+// and -2 if the owner holds a permit already.
+func (h *MemoryHandler) AcquirePermit(ctx context.Context, key string, owner string, value string, ttl time.Duration) (int64, error) {
+	var result int64
+	err := h.update(func(tx *memoryTx) error {
+		keys := h.semaphoreKeys(key)
+		var definition struct {
+			Permits int `json:"permits"`
+		}
+		if ok, err := tx.getJSON(keys[0], &definition); err != nil || !ok {
+			result = -1
+			return err
+		}
+		permits, err := h.permits(tx, keys[1])
+		if err != nil {
+			return err
+		}
+		for _, permit := range permits {
+			if permit.Owner == owner {
+				result = -2
+				return nil
+			}
+		}
+		if len(permits) >= definition.Permits {
+			return nil
+		}
+		result = 1
+		return tx.setJSON(keys[1], append(permits, memoryPermit{Owner: owner, Value: value, ExpireAt: tx.now.Add(ttl)}), time.Time{})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.AcquirePermit - h.update > %w", err)
+	}
+	return result, nil
+}
+
+// ReleasePermit returns the permit of owner, it reports whether owner held one.
+func (h *MemoryHandler) ReleasePermit(ctx context.Context, key string, owner string) (bool, error) {
+	released := false
+	err := h.update(func(tx *memoryTx) error {
+		permits, err := h.permits(tx, h.semaphoreKeys(key)[1])
+		if err != nil {
+			return err
+		}
+		for i, permit := range permits {
+			if permit.Owner != owner {
+				continue
+			}
+			released = true
+			if len(permits) == 1 {
+				tx.del(h.semaphoreKeys(key)[1])
+				return nil
+			}
+			return tx.setJSON(h.semaphoreKeys(key)[1], append(permits[:i:i], permits[i+1:]...), time.Time{})
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("MemoryHandler.ReleasePermit - h.update > %w", err)
+	}
+	return released, nil
+}
+
+// sessionHolders returns the holders of the lock stored in lockKey and whether one of them belongs to the session.
+func sessionHolders(tx *memoryTx, lockKey string, id string) ([]*domain.Lock, bool, error) {
+	var holders []*domain.Lock
+	if _, err := tx.getJSON(lockKey, &holders); err != nil {
+		return nil, false, err
+	}
+	for _, holder := range holders {
+		if holder.SessionID == id {
+			return holders, true, nil
+		}
+	}
+	return holders, false, nil
+}
+
+// sessionLockKey returns the key of a lock in the index of a session, keys of a namespace
+// other than the default one are returned as namespace/key.
+func sessionLockKey(lockKey string) string {
+	if strings.HasPrefix(lockKey, "lock:") {
+		return strings.TrimPrefix(lockKey, "lock:")
+	}
+	if ns, lock, ok := strings.Cut(lockKey, ".lock:"); ok {
+		return ns + "/" + lock
+	}
+	return lockKey
+}
+
+// setHolders stores the holders of the lock in lockKey for ttl like setLock.
+func (h *MemoryHandler) setHolders(tx *memoryTx, lockKey string, holders []*domain.Lock, ttl time.Duration) error {
+	value, err := json.Marshal(holders)
+	if err != nil {
+		return fmt.Errorf("MemoryHandler.setHolders - json.Marshal > %w", err)
+	}
+	return h.setLock(tx, lockKey, string(value), ttl)
+}
+
+// CreateSession stores a new session for ttl, it reports whether the id was still unused.
+func (h *MemoryHandler) CreateSession(ctx context.Context, id string, value string, ttl time.Duration) (bool, error) {
+	created := false
+	err := h.update(func(tx *memoryTx) error {
+		if _, ok := tx.get(h.sessionKeys(id)[0]); !ok {
+			tx.set(h.sessionKeys(id)[0], value, ttl)
+			created = true
+		}
+		return nil
+	})
+	return created, err
+}
+
+// GetSession returns the session and the keys of the locks it holds, the session is empty if it does not exist.
+func (h *MemoryHandler) GetSession(ctx context.Context, id string) (string, []string, error) {
+	var session string
+	var keys []string
+	err := h.view(func(tx *memoryTx) error {
+		var ok bool
+		if session, ok = tx.get(h.sessionKeys(id)[0]); !ok {
+			return nil
+		}
+		var index []string
+		if _, err := tx.getJSON(h.sessionKeys(id)[1], &index); err != nil {
+			return err
+		}
+		keys = make([]string, 0, len(index))
+		for _, lockKey := range index {
+			_, held, err := sessionHolders(tx, lockKey, id)
+			if err != nil {
+				return err
+			}
+			if held {
+				keys = append(keys, sessionLockKey(lockKey))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("MemoryHandler.GetSession - h.view > %w", err)
+	}
+	return session, keys, nil
+}
+
+// RenewSession replaces the session with value for ttl, the locks it holds expire with it at expireAt.
+// It reports whether the session exists.
+func (h *MemoryHandler) RenewSession(ctx context.Context, id string, value string, expireAt time.Time, ttl time.Duration) (bool, error) {
+	renewed := false
+	err := h.update(func(tx *memoryTx) error {
+		keys := h.sessionKeys(id)
+		if _, ok := tx.get(keys[0]); !ok {
+			return nil
+		}
+		renewed = true
+		tx.set(keys[0], value, ttl)
+		var index, held []string
+		if _, err := tx.getJSON(keys[1], &index); err != nil {
+			return err
+		}
+		for _, lockKey := range index {
+			holders, ok, err := sessionHolders(tx, lockKey, id)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			for _, holder := range holders {
+				if holder.SessionID == id {
+					holder.ExpireAt = expireAt.UTC()
+				}
+			}
+			// the lock outlives the session, so it is released with the other locks of the session by the sweeper
+			lockTTL := tx.ttl(lockKey)
+			if ttl+shadowGrace > lockTTL {
+				lockTTL = ttl + shadowGrace
+			}
+			if err := h.setHolders(tx, lockKey, holders, lockTTL); err != nil {
+				return err
+			}
+			held = append(held, lockKey)
+		}
+		if len(held) == 0 {
+			tx.del(keys[1])
+			return nil
+		}
+		return tx.setJSON(keys[1], held, tx.now.Add(ttl+shadowGrace))
+	})
+	if err != nil {
+		return false, fmt.Errorf("MemoryHandler.RenewSession - h.update > %w", err)
+	}
+	return renewed, nil
+}
+
+// DeleteSession removes the session and releases all locks it holds at once.
+// It returns the number of released locks or -1 if the session does not exist.
+func (h *MemoryHandler) DeleteSession(ctx context.Context, id string) (int64, error) {
+	var released int64
+	err := h.update(func(tx *memoryTx) error {
+		keys := h.sessionKeys(id)
+		if _, ok := tx.get(keys[0]); !ok {
+			released = -1
+			return nil
+		}
+		var err error
+		if released, err = h.releaseSession(tx, id); err != nil {
+			return err
+		}
+		tx.del(keys[0])
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.DeleteSession - h.update > %w", err)
+	}
+	return released, nil
+}
+
+// releaseSession releases all locks of the index of the session held by it and removes the index,
+// a lock is deleted with its last holder. It returns the number of released locks.
+func (h *MemoryHandler) releaseSession(tx *memoryTx, id string) (int64, error) {
+	var released int64
+	var index []string
+	if _, err := tx.getJSON(h.sessionKeys(id)[1], &index); err != nil {
+		return 0, err
+	}
+	for _, lockKey := range index {
+		holders, held, err := sessionHolders(tx, lockKey, id)
+		if err != nil {
+			return 0, err
+		}
+		if !held {
+			continue
+		}
+		released++
+		remaining := holders[:0:0]
+		for _, holder := range holders {
+			if holder.SessionID != id {
+				remaining = append(remaining, holder)
+			}
+		}
+		if len(remaining) == 0 {
+			tx.del(lockKey)
+			continue
+		}
+		if err := h.setHolders(tx, lockKey, remaining, tx.ttl(lockKey)); err != nil {
+			return 0, err
+		}
+	}
+	tx.del(h.sessionKeys(id)[1])
+	return released, nil
+}
+
+// AcquireInSession acquires the lock like Acquire and binds the new holder to the session,
+// it expires with the session then. It returns -2 if the session does not exist.
+func (h *MemoryHandler) AcquireInSession(ctx context.Context, key string, owner string, session string, expected string, value string, ttl time.Duration) (int64, error) {
+	var token int64
+	err := h.update(func(tx *memoryTx) error {
+		keys := h.sessionKeys(session)
+		var s domain.Session
+		if ok, err := tx.getJSON(keys[0], &s); err != nil || !ok {
+			token = -2
+			return err
+		}
+		var err error
+		if token, err = h.check(ctx, tx, key, owner, expected); err != nil || token != 1 {
+			return err
+		}
+		s.ID = session
+		// the lock and the index outlive the session, so the sweeper releases the locks of the session at once
+		remaining := tx.ttl(keys[0]) + shadowGrace
+		if remaining > ttl {
+			ttl = remaining
+		}
+		if token, err = h.store(ctx, tx, key, owner, value, ttl, &s); err != nil {
+			return err
+		}
+		var index []string
+		if _, err := tx.getJSON(keys[1], &index); err != nil {
+			return err
+		}
+		lockKey := h.lockKey(ctx, key)
+		for _, indexed := range index {
+			if indexed == lockKey {
+				return tx.setJSON(keys[1], index, tx.now.Add(remaining))
+			}
+		}
+		return tx.setJSON(keys[1], append(index, lockKey), tx.now.Add(remaining))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("MemoryHandler.AcquireInSession - h.update > %w", err)
+	}
+	return token, nil
+}
+
+// PublishEvent delivers the event value to the subscribers of the lock.
+func (h *MemoryHandler) PublishEvent(ctx context.Context, key string, value string) error {
+	namespace := h.namespacePrefix(ctx)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subscriber := range h.subscribers {
+		if subscriber.namespace == namespace && strings.HasPrefix(key, subscriber.keyPrefix) {
+			h.deliver(subscriber.values, value)
+		}
+	}
+	return nil
+}
+
+// SubscribeEvents delivers the published event values and the keys of the locks that expired
+// for the locks with the key prefix in the namespace of ctx, until ctx is done.
+func (h *MemoryHandler) SubscribeEvents(ctx context.Context, keyPrefix string) (<-chan string, <-chan string, error) {
+	subscriber := &memorySubscriber{
+		namespace: h.namespacePrefix(ctx),
+		keyPrefix: keyPrefix,
+		values:    make(chan string, memorySubscriberBuffer),
+		expired:   make(chan string, memorySubscriberBuffer),
+	}
+	h.mu.Lock()
+	h.subscribers[subscriber] = struct{}{}
+	h.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers, subscriber)
+		close(subscriber.values)
+		close(subscriber.expired)
+	}()
+	return subscriber.values, subscriber.expired, nil
+}
+
+// GetLockShadow returns the shadow copy of the lock in the namespace of ctx, the last value the lock had
+// until shortly after it expired. It is empty if there is none.
+func (h *MemoryHandler) GetLockShadow(ctx context.Context, key string) (string, error) {
+	var value string
+	err := h.view(func(tx *memoryTx) error {
+		value, _ = tx.get(h.shadowKey(ctx, key))
+		return nil
+	})
+	return value, err
+}
+
+// ClaimEvent reports whether the event id was not claimed within ttl before.
+func (h *MemoryHandler) ClaimEvent(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	claimed := false
+	err := h.update(func(tx *memoryTx) error {
+		if _, ok := tx.get("event-claim:" + id); !ok {
+			tx.set("event-claim:"+id, "1", ttl)
+			claimed = true
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// AppendHistory adds value to the history of the lock in the namespace of ctx. The history keeps
+// at most maxEntries entries, none older than retention, and is removed retention after its last entry.
+func (h *MemoryHandler) AppendHistory(ctx context.Context, key string, value string, maxEntries int64, retention time.Duration) error {
+	err := h.update(func(tx *memoryTx) error {
+		var entries []memoryHistoryEntry
+		if _, err := tx.getJSON(h.historyKey(ctx, key), &entries); err != nil {
+			return err
+		}
+		entries = append(entries, memoryHistoryEntry{At: tx.now, Entry: value})
+		if int64(len(entries)) > maxEntries {
+			entries = entries[int64(len(entries))-maxEntries:]
+		}
+		oldest := tx.now.Add(-retention)
+		for len(entries) > 0 && entries[0].At.Before(oldest) {
+			entries = entries[1:]
+		}
+		return tx.setJSON(h.historyKey(ctx, key), entries, tx.now.Add(retention))
+	})
+	if err != nil {
+		return fmt.Errorf("MemoryHandler.AppendHistory - h.update > %w", err)
+	}
+	return nil
+}
+
+// ListHistory returns the entries of the history of the lock in the namespace of ctx that were added
+// between from and to, from oldest to newest. A zero time leaves its end of the range open.
+func (h *MemoryHandler) ListHistory(ctx context.Context, key string, from time.Time, to time.Time) ([]string, error) {
+	values := []string{}
+	err := h.view(func(tx *memoryTx) error {
+		var entries []memoryHistoryEntry
+		if _, err := tx.getJSON(h.historyKey(ctx, key), &entries); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			// like the stream IDs of Redis the range is compared in milliseconds
+			at := entry.At.UnixMilli()
+			if (!from.IsZero() && at < from.UnixMilli()) || (!to.IsZero() && at > to.UnixMilli()) {
+				continue
+			}
+			values = append(values, entry.Entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MemoryHandler.ListHistory - h.view > %w", err)
+	}
+	return values, nil
+}
+
+// CreateWebhook stores a new webhook and reports whether the id was still unused.
+func (h *MemoryHandler) CreateWebhook(ctx context.Context, id string, value string) (bool, error) {
+	created := false
+	err := h.update(func(tx *memoryTx) error {
+		if _, ok := tx.get(h.webhookKeys(id)[0]); !ok {
+			tx.set(h.webhookKeys(id)[0], value, 0)
+			created = true
+		}
+		return nil
+	})
+	return created, err
+}
+
+// GetWebhook returns the webhook, it is empty if the webhook does not exist.
+func (h *MemoryHandler) GetWebhook(ctx context.Context, id string) (string, error) {
+	var value string
+	err := h.view(func(tx *memoryTx) error {
+		value, _ = tx.get(h.webhookKeys(id)[0])
+		return nil
+	})
+	return value, err
+}
+
+// ListWebhooks returns all registered webhooks.
+func (h *MemoryHandler) ListWebhooks(ctx context.Context) ([]string, error) {
+	values := []string{}
+	err := h.view(func(tx *memoryTx) error {
+		for _, key := range tx.keys(h.webhookKeys("")[0]) {
+			value, _ := tx.get(key)
+			values = append(values, value)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// DeleteWebhook removes the webhook with its delivery log and reports whether it existed.
+func (h *MemoryHandler) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	deleted := false
+	err := h.update(func(tx *memoryTx) error {
+		deleted = tx.del(h.webhookKeys(id)[0])
+		tx.del(h.webhookKeys(id)[1])
+		return nil
+	})
+	return deleted, err
+}
+
+// LogWebhookDelivery adds the delivery to the log of the webhook, it keeps the last size deliveries.
+func (h *MemoryHandler) LogWebhookDelivery(ctx context.Context, id string, value string, size int64) error {
+	err := h.update(func(tx *memoryTx) error {
+		var deliveries []string
+		if _, err := tx.getJSON(h.webhookKeys(id)[1], &deliveries); err != nil {
+			return err
+		}
+		deliveries = append([]string{value}, deliveries...)
+		if int64(len(deliveries)) > size {
+			deliveries = deliveries[:size]
+		}
+		return tx.setJSON(h.webhookKeys(id)[1], deliveries, time.Time{})
+	})
+	if err != nil {
+		return fmt.Errorf("MemoryHandler.LogWebhookDelivery - h.update > %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the delivery log of the webhook, the latest delivery first.
+func (h *MemoryHandler) ListWebhookDeliveries(ctx context.Context, id string) ([]string, error) {
+	deliveries := []string{}
+	err := h.view(func(tx *memoryTx) error {
+		_, err := tx.getJSON(h.webhookKeys(id)[1], &deliveries)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MemoryHandler.ListWebhookDeliveries - h.view > %w", err)
+	}
+	return deliveries, nil
+}
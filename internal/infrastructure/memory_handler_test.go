@@ -0,0 +1,274 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// newTestMemoryHandler returns a MemoryHandler that is closed when the test ends.
+func newTestMemoryHandler(t *testing.T) *MemoryHandler {
+	handler := NewMemoryHandler(NewMockLogger())
+	t.Cleanup(func() { handler.Close() })
+	return handler
+}
+
+func TestMemoryAcquire(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx := context.Background()
+
+	// Act
+	first, err := handler.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+	assert.NoError(t, err)
+	conflict, conflictErr := handler.Acquire(ctx, "deploy", "other", "", testRedlockValue(t, "other"), testRedlockTTL)
+	values, getErr := handler.Get(ctx, "deploy")
+	assert.NoError(t, getErr)
+	released, releaseErr := handler.CompareAndDelete(ctx, "deploy", values[0])
+	second, secondErr := handler.Acquire(ctx, "deploy", "other", "", testRedlockValue(t, "other"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, conflictErr)
+	assert.NoError(t, releaseErr)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, int64(1), first)
+	assert.Equal(t, int64(-1), conflict)
+	assert.Contains(t, values[0], `"fencingToken":1`)
+	assert.True(t, released)
+	assert.Equal(t, int64(2), second)
+}
+
+func TestMemoryAcquireRespectsQueue(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx := context.Background()
+	position, err := handler.Enqueue(ctx, "deploy", "first", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	// Act
+	overtaken, overtakeErr := handler.Acquire(ctx, "deploy", "second", "", testRedlockValue(t, "second"), testRedlockTTL)
+	token, acquireErr := handler.Acquire(ctx, "deploy", "first", "", testRedlockValue(t, "first"), testRedlockTTL)
+	queue, queueErr := handler.Queue(ctx, "deploy")
+
+	// Assert
+	assert.NoError(t, overtakeErr)
+	assert.NoError(t, acquireErr)
+	assert.NoError(t, queueErr)
+	assert.Equal(t, 0, position)
+	assert.Equal(t, int64(0), overtaken)
+	assert.Equal(t, int64(1), token)
+	assert.Empty(t, queue)
+}
+
+func TestMemoryAcquireMultipleAllOrNone(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx := context.Background()
+	_, err := handler.Acquire(ctx, "db", "other", "", testRedlockValue(t, "other"), testRedlockTTL)
+	assert.NoError(t, err)
+	ttls := []time.Duration{testRedlockTTL, testRedlockTTL}
+	values := []string{testRedlockValue(t, "ci"), testRedlockValue(t, "ci")}
+
+	// Act
+	results, err := handler.AcquireMultiple(ctx, "ci", []string{"api", "db"}, []string{"", ""}, values, ttls)
+	api, getErr := handler.Get(ctx, "api")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, []int64{1, -1}, results)
+	assert.Nil(t, api)
+}
+
+func TestMemoryGetListsNamespace(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx := context.Background()
+	teamCtx := domain.WithNamespace(ctx, &domain.Namespace{Name: "team-a"})
+	for _, key := range []string{"deploy/api", "deploy/db"} {
+		_, err := handler.Acquire(ctx, key, "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+		assert.NoError(t, err)
+	}
+	_, err := handler.Acquire(teamCtx, "deploy/api", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+	assert.NoError(t, err)
+
+	// Act
+	values, getErr := handler.Get(ctx, "*")
+	teamValues, teamErr := handler.Get(teamCtx, "*")
+	count, countErr := handler.Count(ctx)
+
+	// Assert
+	assert.NoError(t, getErr)
+	assert.NoError(t, teamErr)
+	assert.NoError(t, countErr)
+	assert.Len(t, values, 2)
+	assert.Len(t, teamValues, 1)
+	assert.Equal(t, 2, count)
+}
+
+func TestMemoryLockExpires(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	_, expired, err := handler.SubscribeEvents(ctx, "deploy/")
+	assert.NoError(t, err)
+	changes, err := handler.Watch(ctx, "deploy/api")
+	assert.NoError(t, err)
+	_, err = handler.Acquire(ctx, "build/api", "ci", "", testRedlockValue(t, "ci"), 50*time.Millisecond)
+	assert.NoError(t, err)
+	_, err = handler.Acquire(ctx, "deploy/api", "ci", "", testRedlockValue(t, "ci"), 50*time.Millisecond)
+	assert.NoError(t, err)
+	<-changes
+
+	// Act
+	var key string
+	select {
+	case key = <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("expired lock was not reported")
+	}
+
+	// Assert
+	assert.Equal(t, "deploy/api", key)
+	select {
+	case <-changes:
+	default:
+		t.Fatal("watcher was not signaled")
+	}
+	values, err := handler.Get(ctx, "deploy/api")
+	assert.NoError(t, err)
+	assert.Nil(t, values)
+	shadow, err := handler.GetLockShadow(ctx, "deploy/api")
+	assert.NoError(t, err)
+	assert.Contains(t, shadow, `"owner":"ci"`)
+	lease, err := handler.GetLease(ctx, "deploy/api", "ci")
+	assert.NoError(t, err)
+	assert.Contains(t, lease, `"fencingToken":1`)
+}
+
+// testSessionStore is implemented by the handlers that bind locks to sessions.
+type testSessionStore interface {
+	Acquire(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (int64, error)
+	Get(ctx context.Context, key string) ([]string, error)
+	CreateSession(ctx context.Context, id string, value string, ttl time.Duration) (bool, error)
+	AcquireInSession(ctx context.Context, key string, owner string, session string, expected string, value string, ttl time.Duration) (int64, error)
+}
+
+// holdInSession creates the session s1 for ttl, it holds the lock deploy and shares the lock shared
+// with the owner other, who holds it for a minute.
+func holdInSession(t *testing.T, ctx context.Context, handler testSessionStore, ttl time.Duration) {
+	now := time.Now().UTC()
+	session, err := json.Marshal(domain.Session{ID: "s1", ExpireAt: now.Add(ttl), CreatedAt: now})
+	assert.NoError(t, err)
+	created, err := handler.CreateSession(ctx, "s1", string(session), ttl)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	holder := &domain.Lock{Key: "deploy", Owner: "job", Mode: domain.LockModeExclusive, HoldCount: 1, SessionID: "s1"}
+	value, err := json.Marshal([]*domain.Lock{holder})
+	assert.NoError(t, err)
+	_, err = handler.AcquireInSession(ctx, "deploy", "job", "s1", "", string(value), ttl)
+	assert.NoError(t, err)
+	other := &domain.Lock{Key: "shared", Owner: "other", Mode: domain.LockModeShared, HoldCount: 1, ExpireAt: now.Add(time.Minute)}
+	value, err = json.Marshal([]*domain.Lock{other})
+	assert.NoError(t, err)
+	_, err = handler.Acquire(ctx, "shared", "other", "", string(value), time.Minute)
+	assert.NoError(t, err)
+	current, err := handler.Get(ctx, "shared")
+	assert.NoError(t, err)
+	var holders []*domain.Lock
+	assert.NoError(t, json.Unmarshal([]byte(current[0]), &holders))
+	holders = append(holders, &domain.Lock{Key: "shared", Owner: "job", Mode: domain.LockModeShared, HoldCount: 1, SessionID: "s1"})
+	value, err = json.Marshal(holders)
+	assert.NoError(t, err)
+	_, err = handler.AcquireInSession(ctx, "shared", "job", "s1", current[0], string(value), ttl)
+	assert.NoError(t, err)
+}
+
+// sessionOwners returns the owners of the locks deploy and shared.
+func sessionOwners(t *testing.T, ctx context.Context, handler testSessionStore) map[string][]string {
+	owners := map[string][]string{}
+	for _, key := range []string{"deploy", "shared"} {
+		values, err := handler.Get(ctx, key)
+		assert.NoError(t, err)
+		for _, value := range values {
+			var holders []*domain.Lock
+			assert.NoError(t, json.Unmarshal([]byte(value), &holders))
+			for _, holder := range holders {
+				owners[key] = append(owners[key], holder.Owner)
+			}
+		}
+	}
+	return owners
+}
+
+func TestMemorySweepKeepsIndexAndExpiries(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx := context.Background()
+	_, err := handler.Acquire(ctx, "deploy/api", "ci", "", testRedlockValue(t, "ci"), 20*time.Millisecond)
+	assert.NoError(t, err)
+	values, err := handler.Get(ctx, "deploy/api")
+	assert.NoError(t, err)
+
+	// Act
+	time.Sleep(300 * time.Millisecond)
+	expiredValues, getErr := handler.Get(ctx, "deploy/api")
+
+	// Assert
+	assert.NoError(t, getErr)
+	assert.Len(t, values, 1)
+	assert.Nil(t, expiredValues)
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+	assert.Equal(t, []string{"fence:deploy/api"}, handler.index.withPrefix("fence:"))
+	assert.Empty(t, handler.index.withPrefix("lock:"))
+	assert.NotContains(t, handler.expiries.byKey, "fence:deploy/api")
+	assert.NotContains(t, handler.expiries.byKey, "lock:deploy/api")
+	assert.Len(t, handler.expiries.heap, len(handler.expiries.byKey))
+}
+
+func TestMemoryExpiriesDue(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	expiries := newMemoryExpiries()
+	expiries.set("a", now.Add(-3*time.Second))
+	expiries.set("b", now.Add(time.Second))
+	expiries.set("c", now.Add(-time.Second))
+	expiries.set("d", now.Add(-2*time.Second))
+	expiries.set("e", now.Add(-time.Second))
+
+	// Act
+	expiries.set("b", now.Add(-4*time.Second))
+	expiries.set("c", now.Add(time.Minute))
+	expiries.remove("d")
+	expiries.set("e", time.Time{})
+	due := expiries.due(now)
+
+	// Assert
+	assert.ElementsMatch(t, []string{"a", "b"}, due)
+	assert.True(t, expiries.hasDue(now))
+	assert.False(t, expiries.hasDue(now.Add(-5*time.Second)))
+	assert.Len(t, expiries.heap, 3)
+}
+
+func TestMemorySessionExpiryReleasesLocks(t *testing.T) {
+	// Arrange
+	handler := newTestMemoryHandler(t)
+	ctx := context.Background()
+	holdInSession(t, ctx, handler, 50*time.Millisecond)
+	assert.Equal(t, map[string][]string{"deploy": {"job"}, "shared": {"other", "job"}}, sessionOwners(t, ctx, handler))
+
+	// Act
+	time.Sleep(50 * time.Millisecond)
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(map[string][]string{"shared": {"other"}}, sessionOwners(t, ctx, handler))
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,134 @@
+package infrastructure
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memoryKeys are the keys of the entries of a MemoryHandler in lexical order, so the keys with a prefix
+// are found without visiting all entries.
+type memoryKeys []string
+
+// withPrefix returns the keys with the prefix, the result shares the memory of k.
+func (k memoryKeys) withPrefix(prefix string) []string {
+	start := sort.SearchStrings(k, prefix)
+	end := start
+	for end < len(k) && strings.HasPrefix(k[end], prefix) {
+		end++
+	}
+	return k[start:end]
+}
+
+// add inserts the key unless it is present already.
+func (k *memoryKeys) add(key string) {
+	i := sort.SearchStrings(*k, key)
+	if i < len(*k) && (*k)[i] == key {
+		return
+	}
+	*k = append(*k, "")
+	copy((*k)[i+1:], (*k)[i:])
+	(*k)[i] = key
+}
+
+// remove removes the key if it is present.
+func (k *memoryKeys) remove(key string) {
+	i := sort.SearchStrings(*k, key)
+	if i < len(*k) && (*k)[i] == key {
+		*k = append((*k)[:i], (*k)[i+1:]...)
+	}
+}
+
+// memoryExpiries are the entries of a MemoryHandler that expire in a min-heap by their expiry, so the sweeper
+// only visits the ones that are due. Entries that never expire, like the fencing counters, are not part of it.
+type memoryExpiries struct {
+	heap  memoryExpiryHeap
+	byKey map[string]*memoryExpiry
+}
+
+// memoryExpiry is the expiry of an entry at its position in the heap.
+type memoryExpiry struct {
+	key      string
+	expireAt time.Time
+	position int
+}
+
+// newMemoryExpiries returns empty memoryExpiries.
+func newMemoryExpiries() *memoryExpiries {
+	return &memoryExpiries{byKey: map[string]*memoryExpiry{}}
+}
+
+// set records that the key expires at expireAt, a zero expireAt removes it.
+func (e *memoryExpiries) set(key string, expireAt time.Time) {
+	if expireAt.IsZero() {
+		e.remove(key)
+		return
+	}
+	if expiry, ok := e.byKey[key]; ok {
+		expiry.expireAt = expireAt
+		heap.Fix(&e.heap, expiry.position)
+		return
+	}
+	expiry := &memoryExpiry{key: key, expireAt: expireAt}
+	e.byKey[key] = expiry
+	heap.Push(&e.heap, expiry)
+}
+
+// remove forgets the expiry of the key.
+func (e *memoryExpiries) remove(key string) {
+	expiry, ok := e.byKey[key]
+	if !ok {
+		return
+	}
+	delete(e.byKey, key)
+	heap.Remove(&e.heap, expiry.position)
+}
+
+// hasDue reports whether any key expired at now.
+func (e *memoryExpiries) hasDue(now time.Time) bool {
+	return len(e.heap) > 0 && !now.Before(e.heap[0].expireAt)
+}
+
+// due returns the keys that expired at now, it only visits the heap down to the first key that did not.
+func (e *memoryExpiries) due(now time.Time) []string {
+	var keys []string
+	var visit func(i int)
+	visit = func(i int) {
+		if i >= len(e.heap) || now.Before(e.heap[i].expireAt) {
+			return
+		}
+		keys = append(keys, e.heap[i].key)
+		visit(2*i + 1)
+		visit(2*i + 2)
+	}
+	visit(0)
+	return keys
+}
+
+// memoryExpiryHeap implements heap.Interface for memoryExpiries, the earliest expiry first.
+type memoryExpiryHeap []*memoryExpiry
+
+func (h memoryExpiryHeap) Len() int { return len(h) }
+
+func (h memoryExpiryHeap) Less(i, j int) bool { return h[i].expireAt.Before(h[j].expireAt) }
+
+func (h memoryExpiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].position = i
+	h[j].position = j
+}
+
+func (h *memoryExpiryHeap) Push(x interface{}) {
+	expiry := x.(*memoryExpiry)
+	expiry.position = len(*h)
+	*h = append(*h, expiry)
+}
+
+func (h *memoryExpiryHeap) Pop() interface{} {
+	old := *h
+	expiry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return expiry
+}
@@ -0,0 +1,34 @@
+package infrastructure
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMetricsRecorder mocks the MetricsRecorder interface.
+type MockMetricsRecorder struct {
+	mock.Mock
+}
+
+func (m *MockMetricsRecorder) ObserveHTTPRequest(method, path string, statusCode int, duration float64) {
+	m.Called(method, path, statusCode, duration)
+}
+
+func (m *MockMetricsRecorder) RecordUserAction(action string) {
+	m.Called(action)
+}
+
+func (m *MockMetricsRecorder) IncrementErrorCount(errorType string) {
+	m.Called(errorType)
+}
+
+func (m *MockMetricsRecorder) SetLockCount(namespace string, value float64) {
+	m.Called(namespace, value)
+}
+
+func (m *MockMetricsRecorder) IncrementExpiredLocks(namespace string) {
+	m.Called(namespace)
+}
+
+func (m *MockMetricsRecorder) IncrementRedisFailovers() {
+	m.Called()
+}
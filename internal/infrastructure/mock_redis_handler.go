@@ -1,6 +1,7 @@
 package infrastructure
 
 import (
+	"context"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -11,28 +12,79 @@ type MockRedisHandler struct {
 	mock.Mock
 }
 
-func (m *MockRedisHandler) Set(key string, value string, ttl time.Duration) error {
-	args := m.Called(key, value, ttl)
+func (m *MockRedisHandler) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	args := m.Called(ctx, key, value, ttl)
 	return args.Error(0)
 }
 
-func (m *MockRedisHandler) Get(key string) ([]string, error) {
-	args := m.Called(key)
+func (m *MockRedisHandler) Acquire(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (int64, error) {
+	args := m.Called(ctx, key, owner, expected, value, ttl)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRedisHandler) AcquireMultiple(ctx context.Context, owner string, keys []string, expected []string, values []string, ttls []time.Duration) ([]int64, error) {
+	args := m.Called(ctx, owner, keys, expected, values, ttls)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRedisHandler) Takeover(ctx context.Context, key string, expected string, value string, ttl time.Duration) (int64, error) {
+	args := m.Called(ctx, key, expected, value, ttl)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRedisHandler) Get(ctx context.Context, key string) ([]string, error) {
+	args := m.Called(ctx, key)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockRedisHandler) Del(key string) error {
-	args := m.Called(key)
+func (m *MockRedisHandler) Del(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
 	return args.Error(0)
 }
 
-func (m *MockRedisHandler) GetMultiple(keys []string) ([]string, error) {
-	args := m.Called(keys)
+func (m *MockRedisHandler) CompareAndDelete(ctx context.Context, key string, expected string) (bool, error) {
+	args := m.Called(ctx, key, expected)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRedisHandler) CompareAndSwap(ctx context.Context, key string, expected string, value string, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, key, expected, value, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRedisHandler) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(<-chan struct{}), args.Error(1)
+}
+
+func (m *MockRedisHandler) Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error) {
+	args := m.Called(ctx, key, owner, deadline)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRedisHandler) Dequeue(ctx context.Context, key string, owner string) error {
+	args := m.Called(ctx, key, owner)
+	return args.Error(0)
+}
+
+func (m *MockRedisHandler) Queue(ctx context.Context, key string) ([]string, error) {
+	args := m.Called(ctx, key)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockRedisHandler) Ping() error {
-	args := m.Called()
+func (m *MockRedisHandler) GetMultiple(ctx context.Context, keys []string) ([]string, error) {
+	args := m.Called(ctx, keys)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRedisHandler) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
 	return args.Error(0)
 }
 
@@ -41,7 +93,7 @@ func (m *MockRedisHandler) Close() error {
 	return args.Error(0)
 }
 
-func (m *MockRedisHandler) Count() (int, error) {
-	args := m.Called()
+func (m *MockRedisHandler) Count(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
 	return args.Int(0), args.Error(1)
 }
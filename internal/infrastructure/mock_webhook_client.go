@@ -0,0 +1,17 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookClient mocks the WebhookClient interface.
+type MockWebhookClient struct {
+	mock.Mock
+}
+
+func (m *MockWebhookClient) Post(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	args := m.Called(ctx, url, headers, body)
+	return args.Int(0), args.Error(1)
+}
@@ -3,72 +3,362 @@ package infrastructure
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/tyriis/go-locking-service/internal/domain"
 )
 
+// sessionSweepInterval is the interval in which the RedisHandler releases the locks of expired sessions.
+const sessionSweepInterval = time.Second
+
+// clusterRefreshInterval is the interval in which SubscribeEvents looks for masters of a cluster it is not
+// subscribed to yet, a failover or resharding promotes or adds nodes.
+const clusterRefreshInterval = 5 * time.Second
+
+// withGraces appends shadowGrace and leaseGrace in milliseconds to the arguments of a script that uses shadowLua.
+func withGraces(args ...interface{}) []interface{} {
+	return append(args, shadowGrace.Milliseconds(), leaseGrace.Milliseconds())
+}
+
 // RedisHandler implements lock storage using Redis.
 type RedisHandler struct {
-	client *redis.Client
-	ctx    context.Context
+	client redis.UniversalClient
 	logger domain.Logger
 	config domain.Config
+	// tls is the TLS configuration of the connections, nil without TLS
+	tls *tls.Config
+	// keyspace shares the subscriptions of Watch
+	keyspace *keyspaceWatcher
+	// mu guards the master the sentinels switched to last
+	mu     sync.Mutex
+	master string
+	// stop ends the session sweeper, swept is closed once it returned
+	stop     chan struct{}
+	stopOnce sync.Once
+	swept    chan struct{}
 }
 
-// NewRedisHandler creates a new RedisHandler with the given configuration and logger.
-func NewRedisHandler(config domain.Config, logger domain.Logger) *RedisHandler {
+// NewRedisHandler creates a new RedisHandler with the given configuration and logger and starts its session
+// sweeper outside of cluster mode, Close stops it. It fails if the TLS certificates of the configuration can't be loaded.
+func NewRedisHandler(config domain.Config, logger domain.Logger) (*RedisHandler, error) {
 	redisJSON, err := json.Marshal(config.Redis)
 	if err == nil {
 		const msg = "NewRedisHandler - json.Marshal > config.Redis > %s"
 		logger.Debug(fmt.Sprintf(msg, string(redisJSON)))
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr: config.Redis.Host + ":" + config.Redis.Port,
-	})
-	return &RedisHandler{
-		client: client,
-		ctx:    context.Background(),
+	tlsConfig, err := newTLSConfig(config.Redis.TLS)
+	if err != nil {
+		const msg = "NewRedisHandler - newTLSConfig > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	h := &RedisHandler{
 		logger: logger,
 		config: config,
+		tls:    tlsConfig,
+		stop:   make(chan struct{}),
+		swept:  make(chan struct{}),
+	}
+	switch {
+	case len(config.Redis.Sentinels) > 0:
+		// the failover client asks the sentinels for the current master and reconnects on failover
+		sentinels := make([]string, 0, len(config.Redis.Sentinels))
+		for _, sentinel := range config.Redis.Sentinels {
+			sentinels = append(sentinels, sentinel.Host+":"+sentinel.Port)
+		}
+		h.client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       config.Redis.Name,
+			SentinelAddrs:    sentinels,
+			SentinelUsername: config.Redis.SentinelUsername,
+			SentinelPassword: config.Redis.SentinelPassword.Value,
+			Username:         config.Redis.Username,
+			Password:         config.Redis.Password.Value,
+			DB:               config.Redis.DB,
+			TLSConfig:        tlsConfig,
+		})
+	case len(config.Redis.Cluster) > 0:
+		// the cluster client discovers all nodes from the seed nodes and follows resharding
+		seeds := make([]string, 0, len(config.Redis.Cluster))
+		for _, seed := range config.Redis.Cluster {
+			seeds = append(seeds, seed.Host+":"+seed.Port)
+		}
+		h.client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     seeds,
+			Username:  config.Redis.Username,
+			Password:  config.Redis.Password.Value,
+			TLSConfig: tlsConfig,
+		})
+	default:
+		h.client = redis.NewClient(&redis.Options{
+			Addr:      config.Redis.Host + ":" + config.Redis.Port,
+			Username:  config.Redis.Username,
+			Password:  config.Redis.Password.Value,
+			DB:        config.Redis.DB,
+			TLSConfig: tlsConfig,
+		})
+	}
+	h.keyspace = newKeyspaceWatcher(h.client, logger)
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		close(h.swept)
+	} else {
+		go h.sweepSessions()
+	}
+	return h, nil
+}
+
+// sweepSessions releases the locks of the expired sessions every sessionSweepInterval until the handler is closed.
+func (h *RedisHandler) sweepSessions() {
+	defer close(h.swept)
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			if _, err := h.expireSessions(context.Background()); err != nil {
+				h.logger.Warn("RedisHandler.sweepSessions - h.expireSessions > " + err.Error())
+			}
+		}
+	}
+}
+
+// newTLSConfig returns the TLS configuration of the Redis connections, it is nil without TLS.
+func newTLSConfig(config domain.RedisTLS) (*tls.Config, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.ServerName,
+	}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			const msg = "newTLSConfig - os.ReadFile > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			const msg = "newTLSConfig - %s contains no PEM certificate"
+			return nil, fmt.Errorf(msg, config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			const msg = "newTLSConfig - tls.LoadX509KeyPair > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// namespacePrefix returns the prefix of the lock keys in the namespace of ctx, the keys of the
+// default namespace carry no namespace so existing locks keep their keys.
+func (h *RedisHandler) namespacePrefix(ctx context.Context) string {
+	ns := domain.NamespaceFromContext(ctx)
+	if ns.Name == domain.DefaultNamespace {
+		return h.config.Redis.Prefix
+	}
+	return h.config.Redis.Prefix + ns.Name + "."
+}
+
+// tagged returns the part of the Redis keys that identifies a lock or semaphore. In cluster mode it is
+// the hash tag {key}, so all keys of the lock that scripts use together are stored in the same slot.
+// Keys that start with a common {group} share a slot as well, f.e. {deploy}/api and {deploy}/db.
+func (h *RedisHandler) tagged(key string) string {
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		return "{" + key + "}"
+	}
+	return key
+}
+
+// untagged returns the key of a lock or semaphore from the part of its Redis key returned by tagged.
+func (h *RedisHandler) untagged(key string) string {
+	if _, ok := h.client.(*redis.ClusterClient); ok && strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// lockKey returns the Redis key that stores the lock for key in the namespace of ctx.
+func (h *RedisHandler) lockKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock:" + h.tagged(key)
+}
+
+// fenceKey returns the Redis key of the fencing counter for key, it never expires
+// so tokens keep increasing across acquisitions.
+func (h *RedisHandler) fenceKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "fence:" + h.tagged(key)
+}
+
+// queueKey returns the Redis key of the wait queue for key, a sorted set of owners scored by arrival.
+func (h *RedisHandler) queueKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "queue:" + h.tagged(key)
+}
+
+// queueDeadlineKey returns the Redis key that holds the deadlines of the waiters in the queue for key.
+func (h *RedisHandler) queueDeadlineKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "queue-deadline:" + h.tagged(key)
+}
+
+// shadowKey returns the Redis key of the shadow copy of the lock for key in the namespace of ctx,
+// it outlives the lock so its last holders are known when the lock expires.
+func (h *RedisHandler) shadowKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock-shadow:" + h.tagged(key)
+}
+
+// leaseKey returns the Redis key of the hash of the last lease of every owner of the lock for key
+// in the namespace of ctx.
+func (h *RedisHandler) leaseKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock-leases:" + h.tagged(key)
+}
+
+// historyKey returns the Redis key of the stream of the audit history of the lock for key in the namespace of ctx.
+func (h *RedisHandler) historyKey(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "lock-history:" + h.tagged(key)
+}
+
+// semaphoreKeys returns the Redis keys of a semaphore, its definition, the sorted set of holders
+// scored by expiry and the hash of their permits.
+func (h *RedisHandler) semaphoreKeys(key string) []string {
+	return []string{
+		h.config.Redis.Prefix + "semaphore:" + h.tagged(key),
+		h.config.Redis.Prefix + "semaphore-holders:" + h.tagged(key),
+		h.config.Redis.Prefix + "semaphore-permits:" + h.tagged(key),
+	}
+}
+
+// errSessionsInCluster is returned in cluster mode, a session and its locks are spread across the slots
+// while the scripts binding them need all their keys in one slot.
+var errSessionsInCluster = domain.NewValidationError("LOCK_SESSION_UNSUPPORTED", "sessions are not supported in Redis cluster mode")
+
+// sessionKeys returns the Redis keys of a session, its definition, the index of the locks it holds
+// and the sorted set of the expiries of all sessions.
+func (h *RedisHandler) sessionKeys(id string) []string {
+	return []string{
+		h.config.Redis.Prefix + "session:" + id,
+		h.config.Redis.Prefix + "session-locks:" + id,
+		h.config.Redis.Prefix + "session-expiries",
 	}
 }
 
 // Set stores a lock with the given key, value, and TTL.
-func (h *RedisHandler) Set(key string, value string, ttl time.Duration) error {
-	if h.Ping() != nil {
+func (h *RedisHandler) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if h.Ping(ctx) != nil {
 		return fmt.Errorf("failed to connect to Redis")
 	}
-	return h.client.Set(h.ctx, h.config.Redis.Prefix+key, value, ttl).Err()
+	_, err := h.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, h.lockKey(ctx, key), value, ttl)
+		pipe.Set(ctx, h.shadowKey(ctx, key), value, ttl+shadowGrace)
+		return nil
+	})
+	return err
+}
+
+// Acquire atomically stores the holders of a lock if its current value still equals expected,
+// an empty expected value means the lock must not exist. It stamps new holders with the next fencing token.
+// Waiters in the queue of the key take precedence, only the first of them can acquire it.
+// It returns the fencing token, 0 if others are queued first or -1 if the current value did not match.
+func (h *RedisHandler) Acquire(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (int64, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	keys := []string{h.lockKey(ctx, key), h.fenceKey(ctx, key), h.queueKey(ctx, key), h.queueDeadlineKey(ctx, key), h.shadowKey(ctx, key), h.leaseKey(ctx, key)}
+	args := withGraces(value, ttl.Milliseconds(), time.Now().UnixMilli(), expected, owner)
+	return acquireScript.Run(ctx, h.client, keys, args...).Int64()
 }
 
-// Get retrieves a lock by key. If key is "*", returns all locks.
-func (h *RedisHandler) Get(key string) ([]string, error) {
-	if h.Ping() != nil {
+// AcquireMultiple acquires the locks of all keys for owner at once, or none of them. Every key is checked
+// like in Acquire against its expected value, value and ttl are stored for it if all checks pass.
+// It returns the fencing tokens of all keys, or if any check failed per key 1 if it passed,
+// 0 if others are queued first or -1 if the current value did not match.
+func (h *RedisHandler) AcquireMultiple(ctx context.Context, owner string, keys []string, expected []string, values []string, ttls []time.Duration) ([]int64, error) {
+	if h.Ping(ctx) != nil {
+		return nil, fmt.Errorf("failed to connect to Redis")
+	}
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		for _, key := range keys[1:] {
+			if hashTag(h.lockKey(ctx, key)) != hashTag(h.lockKey(ctx, keys[0])) {
+				const msg = "%s and %s are stored in different cluster slots, start their keys with a common {group}"
+				return nil, domain.NewValidationError("LOCK_KEYS_IN_DIFFERENT_SLOTS", fmt.Sprintf(msg, keys[0], key))
+			}
+		}
+	}
+	redisKeys := make([]string, 0, len(keys)*6)
+	args := make([]interface{}, 0, 2+len(keys)*3)
+	args = append(args, owner, time.Now().UnixMilli())
+	for i, key := range keys {
+		redisKeys = append(redisKeys, h.lockKey(ctx, key), h.fenceKey(ctx, key), h.queueKey(ctx, key), h.queueDeadlineKey(ctx, key), h.shadowKey(ctx, key), h.leaseKey(ctx, key))
+		args = append(args, expected[i], values[i], ttls[i].Milliseconds())
+	}
+	return acquireMultipleScript.Run(ctx, h.client, redisKeys, withGraces(args...)...).Int64Slice()
+}
+
+// Takeover replaces the lock with value if its current value equals expected, regardless of the wait queue.
+// It stamps new holders with the next fencing token and returns it, or -1 if the current value did not match.
+// The leases of the replaced holders end at once.
+func (h *RedisHandler) Takeover(ctx context.Context, key string, expected string, value string, ttl time.Duration) (int64, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	leases, err := leaseArgs(expected, time.Now())
+	if err != nil {
+		const msg = "RedisHandler.Takeover - leaseArgs > %w"
+		return 0, fmt.Errorf(msg, err)
+	}
+	keys := []string{h.lockKey(ctx, key), h.fenceKey(ctx, key), h.shadowKey(ctx, key), h.leaseKey(ctx, key)}
+	args := withGraces(append([]interface{}{value, ttl.Milliseconds(), expected}, leases...)...)
+	return takeoverScript.Run(ctx, h.client, keys, args...).Int64()
+}
+
+// leaseArgs returns the ended leases of the holders of the lock value as pairs of owner and lease, see end_leases.
+func leaseArgs(value string, at time.Time) ([]interface{}, error) {
+	leases, err := endedLeases(value, at)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, 2*len(leases))
+	for owner, lease := range leases {
+		args = append(args, owner, lease)
+	}
+	return args, nil
+}
+
+// Get retrieves a lock by key in the namespace of ctx. If key is "*", returns all locks of the namespace.
+func (h *RedisHandler) Get(ctx context.Context, key string) ([]string, error) {
+	if h.Ping(ctx) != nil {
 		return nil, fmt.Errorf("failed to connect to Redis")
 	}
 	if key == "*" {
-		// Get all keys with prefix
-		cmd := h.client.Keys(h.ctx, h.config.Redis.Prefix+"*")
-		if cmd.Err() != nil {
-			return nil, cmd.Err()
+		// Get all keys with the prefix of the namespace
+		keys, err := h.scanKeys(ctx, h.lockKey(ctx, "*"))
+		if err != nil {
+			return nil, err
 		}
-		keys := cmd.Val()
 		if len(keys) == 0 {
 			return []string{}, nil
 		}
 
 		// Get all values
-		values, err := h.GetMultiple(keys)
+		values, err := h.GetMultiple(ctx, keys)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get multiple keys: %w", err)
 		}
 		return values, nil
 	}
-	val, err := h.client.Get(h.ctx, h.config.Redis.Prefix+key).Result()
+	val, err := h.client.Get(ctx, h.lockKey(ctx, key)).Result()
 	if err != nil {
 		switch err {
 		case redis.Nil:
@@ -81,18 +371,691 @@ func (h *RedisHandler) Get(key string) ([]string, error) {
 }
 
 // Del removes a lock by key.
-func (h *RedisHandler) Del(key string) error {
-	if h.Ping() != nil {
+func (h *RedisHandler) Del(ctx context.Context, key string) error {
+	if h.Ping(ctx) != nil {
+		return fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.Del(ctx, h.lockKey(ctx, key)).Err()
+}
+
+// fence returns the current fencing counter of the key, 0 if no token was issued yet.
+// The RedlockHandler derives the next token of a quorum of nodes from it.
+func (h *RedisHandler) fence(ctx context.Context, key string) (int64, error) {
+	token, err := h.client.Get(ctx, h.fenceKey(ctx, key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return token, err
+}
+
+// raiseFence raises the fencing counter of the key to token unless it is higher already.
+func (h *RedisHandler) raiseFence(ctx context.Context, key string, token int64) error {
+	return raiseFenceScript.Run(ctx, h.client, []string{h.fenceKey(ctx, key)}, token).Err()
+}
+
+// CompareAndDelete atomically removes a lock if its stored value still equals expected.
+// It reports whether the lock was removed.
+func (h *RedisHandler) CompareAndDelete(ctx context.Context, key string, expected string) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	deleted, err := compareAndDeleteScript.Run(ctx, h.client, []string{h.lockKey(ctx, key)}, expected).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// CompareAndSwap atomically replaces a lock and its TTL if its stored value still equals expected.
+// It reports whether the lock was replaced.
+func (h *RedisHandler) CompareAndSwap(ctx context.Context, key string, expected string, value string, ttl time.Duration) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	keys := []string{h.lockKey(ctx, key), h.shadowKey(ctx, key), h.leaseKey(ctx, key)}
+	swapped, err := compareAndSwapScript.Run(ctx, h.client, keys, withGraces(expected, value, ttl.Milliseconds())...).Int()
+	if err != nil {
+		return false, err
+	}
+	return swapped == 1, nil
+}
+
+// CompareAndRelease atomically replaces a lock and its TTL like CompareAndSwap, or removes it if value is empty,
+// and removes the lease of owner at once. It reports whether the lock was replaced.
+func (h *RedisHandler) CompareAndRelease(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	keys := []string{h.lockKey(ctx, key), h.shadowKey(ctx, key), h.leaseKey(ctx, key)}
+	released, err := compareAndReleaseScript.Run(ctx, h.client, keys, withGraces(expected, value, ttl.Milliseconds(), owner)...).Int()
+	if err != nil {
+		return false, err
+	}
+	return released == 1, nil
+}
+
+// CompareAndRevoke atomically removes a lock if its stored value still equals expected and ends the leases
+// of its holders at once. It reports whether the lock was removed.
+func (h *RedisHandler) CompareAndRevoke(ctx context.Context, key string, expected string) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	leases, err := leaseArgs(expected, time.Now())
+	if err != nil {
+		const msg = "RedisHandler.CompareAndRevoke - leaseArgs > %w"
+		return false, fmt.Errorf(msg, err)
+	}
+	keys := []string{h.lockKey(ctx, key), h.leaseKey(ctx, key)}
+	revoked, err := compareAndRevokeScript.Run(ctx, h.client, keys, withGraces(append([]interface{}{expected}, leases...)...)...).Int()
+	if err != nil {
+		return false, err
+	}
+	return revoked == 1, nil
+}
+
+// Watch subscribes to the keyspace notifications of a lock and its wait queue, the returned channel receives
+// a signal whenever the lock is written, deleted or expires and whenever a waiter leaves the queue.
+// The subscription ends when ctx is done, all watchers of the handler share one connection to Redis.
+// Notifications need to be enabled on the server, see EnableKeyspaceNotifications.
+func (h *RedisHandler) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	const channel = "__keyspace@%d__:%s"
+	db := h.db()
+	// in cluster mode the channels carry the hash tag of the key, so the master holding it is subscribed
+	changes, err := h.keyspace.watch(ctx, fmt.Sprintf(channel, db, h.lockKey(ctx, key)), fmt.Sprintf(channel, db, h.queueKey(ctx, key)))
+	if err != nil {
+		return nil, fmt.Errorf("RedisHandler.Watch - h.keyspace.watch > %w", err)
+	}
+	return changes, nil
+}
+
+// EnableKeyspaceNotifications makes sure the server publishes the keyspace notifications Watch relies on,
+// generic commands (del), string commands (set), sorted set commands (zrem) and expired events,
+// and the expired keyevent notifications of SubscribeEvents, without dropping already enabled ones.
+// It leaves the server as it is if the configuration skips the setup.
+func (h *RedisHandler) EnableKeyspaceNotifications(ctx context.Context) error {
+	if h.config.Redis.SkipNotifySetup {
+		h.logger.Debug("RedisHandler.EnableKeyspaceNotifications - skipped by config")
+		return nil
+	}
+	if cluster, ok := h.client.(*redis.ClusterClient); ok {
+		// every node notifies about its own keys only, replicas are included so a promoted one keeps notifying
+		return cluster.ForEachShard(ctx, func(ctx context.Context, node *redis.Client) error {
+			return h.enableKeyspaceNotifications(ctx, node)
+		})
+	}
+	return h.enableKeyspaceNotifications(ctx, h.client)
+}
+
+// enableKeyspaceNotifications adds the notify-keyspace-events flags Watch and SubscribeEvents need on the node.
+func (h *RedisHandler) enableKeyspaceNotifications(ctx context.Context, node redis.UniversalClient) error {
+	config, err := node.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return fmt.Errorf("RedisHandler.EnableKeyspaceNotifications - node.ConfigGet > %w", err)
+	}
+	current := config["notify-keyspace-events"]
+	flags := current
+	for _, flag := range "KEg$zx" {
+		// A is an alias for all event classes
+		if strings.ContainsRune(flags, flag) || (flag != 'K' && flag != 'E' && strings.ContainsRune(flags, 'A')) {
+			continue
+		}
+		flags += string(flag)
+	}
+	if flags == current {
+		return nil
+	}
+	h.logger.Info(fmt.Sprintf("RedisHandler.EnableKeyspaceNotifications - notify-keyspace-events '%s' > '%s'", current, flags))
+	if err := node.ConfigSet(ctx, "notify-keyspace-events", flags).Err(); err != nil {
+		return fmt.Errorf("RedisHandler.EnableKeyspaceNotifications - node.ConfigSet > %w", err)
+	}
+	return nil
+}
+
+// Enqueue adds the owner to the wait queue of a lock until deadline, an owner that is already queued keeps its place.
+// It returns the zero based position of the owner in the queue.
+func (h *RedisHandler) Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	keys := []string{h.queueKey(ctx, key), h.queueDeadlineKey(ctx, key)}
+	return enqueueScript.Run(ctx, h.client, keys, owner, time.Now().UnixMilli(), deadline.UnixMilli()).Int()
+}
+
+// Dequeue removes the owner from the wait queue of a lock.
+func (h *RedisHandler) Dequeue(ctx context.Context, key string, owner string) error {
+	if h.Ping(ctx) != nil {
+		return fmt.Errorf("failed to connect to Redis")
+	}
+	keys := []string{h.queueKey(ctx, key), h.queueDeadlineKey(ctx, key)}
+	return dequeueScript.Run(ctx, h.client, keys, owner).Err()
+}
+
+// Queue returns the owners waiting for a lock in arrival order.
+func (h *RedisHandler) Queue(ctx context.Context, key string) ([]string, error) {
+	if h.Ping(ctx) != nil {
+		return nil, fmt.Errorf("failed to connect to Redis")
+	}
+	keys := []string{h.queueKey(ctx, key), h.queueDeadlineKey(ctx, key)}
+	return queueScript.Run(ctx, h.client, keys, time.Now().UnixMilli()).StringSlice()
+}
+
+// CreateSemaphore stores the definition of a semaphore only if the key is not used yet,
+// it reports whether the semaphore was created.
+func (h *RedisHandler) CreateSemaphore(ctx context.Context, key string, value string) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.SetNX(ctx, h.semaphoreKeys(key)[0], value, 0).Result()
+}
+
+// GetSemaphore returns the definition of a semaphore and its unexpired permits.
+// The definition is empty if the semaphore does not exist.
+func (h *RedisHandler) GetSemaphore(ctx context.Context, key string) (string, []string, error) {
+	if h.Ping(ctx) != nil {
+		return "", nil, fmt.Errorf("failed to connect to Redis")
+	}
+	result, err := getSemaphoreScript.Run(ctx, h.client, h.semaphoreKeys(key), time.Now().UnixMilli()).StringSlice()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(result) == 0 {
+		return "", nil, nil
+	}
+	return result[0], result[1:], nil
+}
+
+// ListSemaphores returns the keys of all semaphores.
+func (h *RedisHandler) ListSemaphores(ctx context.Context) ([]string, error) {
+	if h.Ping(ctx) != nil {
+		return nil, fmt.Errorf("failed to connect to Redis")
+	}
+	prefix := h.config.Redis.Prefix + "semaphore:"
+	keys, err := h.scanKeys(ctx, prefix+h.tagged("*"))
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		keys[i] = h.untagged(strings.TrimPrefix(key, prefix))
+	}
+	return keys, nil
+}
+
+// DeleteSemaphore removes a semaphore if none of its permits are held.
+// It returns 1 if it was deleted, 0 while permits are held and -1 if it does not exist.
+func (h *RedisHandler) DeleteSemaphore(ctx context.Context, key string) (int64, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	return deleteSemaphoreScript.Run(ctx, h.client, h.semaphoreKeys(key), time.Now().UnixMilli()).Int64()
+}
+
+// AcquirePermit atomically takes one permit of a semaphore for owner, expired permits are reclaimed first.
+// It returns 1 if the permit was acquired, 0 if all permits are held, -1 if the semaphore does not exist
+// and -2 if the owner holds a permit already.
+func (h *RedisHandler) AcquirePermit(ctx context.Context, key string, owner string, value string, ttl time.Duration) (int64, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	now := time.Now()
+	args := []interface{}{owner, value, now.UnixMilli(), now.Add(ttl).UnixMilli()}
+	return acquirePermitScript.Run(ctx, h.client, h.semaphoreKeys(key), args...).Int64()
+}
+
+// ReleasePermit returns the permit of owner, it reports whether owner held one.
+func (h *RedisHandler) ReleasePermit(ctx context.Context, key string, owner string) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	released, err := releasePermitScript.Run(ctx, h.client, h.semaphoreKeys(key), owner, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	return released == 1, nil
+}
+
+// CreateSession stores a new session for ttl, it reports whether the id was still unused.
+func (h *RedisHandler) CreateSession(ctx context.Context, id string, value string, ttl time.Duration) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		return false, errSessionsInCluster
+	}
+	args := []interface{}{id, value, ttl.Milliseconds(), time.Now().Add(ttl).UnixMilli()}
+	created, err := createSessionScript.Run(ctx, h.client, h.sessionKeys(id), args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return created == 1, nil
+}
+
+// GetSession returns the session and the keys of the locks it holds, the session is empty if it does not exist.
+func (h *RedisHandler) GetSession(ctx context.Context, id string) (string, []string, error) {
+	if h.Ping(ctx) != nil {
+		return "", nil, fmt.Errorf("failed to connect to Redis")
+	}
+	result, err := getSessionScript.Run(ctx, h.client, h.sessionKeys(id), id).StringSlice()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(result) == 0 {
+		return "", nil, nil
+	}
+	keys := make([]string, 0, len(result)-1)
+	for _, key := range result[1:] {
+		keys = append(keys, h.sessionLockKey(key))
+	}
+	return result[0], keys, nil
+}
+
+// sessionLockKey returns the key of a lock in the index of a session, keys of a namespace
+// other than the default one are returned as namespace/key.
+func (h *RedisHandler) sessionLockKey(redisKey string) string {
+	key := strings.TrimPrefix(redisKey, h.config.Redis.Prefix)
+	if strings.HasPrefix(key, "lock:") {
+		return strings.TrimPrefix(key, "lock:")
+	}
+	if ns, lock, ok := strings.Cut(key, ".lock:"); ok {
+		return ns + "/" + lock
+	}
+	return key
+}
+
+// RenewSession replaces the session with value for ttl, the locks it holds expire with it at expireAt.
+// It reports whether the session exists.
+func (h *RedisHandler) RenewSession(ctx context.Context, id string, value string, expireAt time.Time, ttl time.Duration) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	args := withGraces(id, value, ttl.Milliseconds(), expireAt.UTC().Format(time.RFC3339Nano), h.config.Redis.Prefix, expireAt.UnixMilli())
+	renewed, err := renewSessionScript.Run(ctx, h.client, h.sessionKeys(id), args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return renewed == 1, nil
+}
+
+// DeleteSession removes the session and releases all locks it holds at once.
+// It returns the number of released locks or -1 if the session does not exist.
+func (h *RedisHandler) DeleteSession(ctx context.Context, id string) (int64, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	return deleteSessionScript.Run(ctx, h.client, h.sessionKeys(id), withGraces(id, h.config.Redis.Prefix)...).Int64()
+}
+
+// expireSessions releases the locks of every session that expired, the locks of a session at once
+// like DeleteSession. It returns the number of released locks.
+func (h *RedisHandler) expireSessions(ctx context.Context) (int64, error) {
+	expiries := h.sessionKeys("")[2]
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	ids, err := h.client.ZRangeByScore(ctx, expiries, &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil {
+		const msg = "RedisHandler.expireSessions - h.client.ZRangeByScore > %w"
+		return 0, fmt.Errorf(msg, err)
+	}
+	var released int64
+	for _, id := range ids {
+		n, err := expireSessionScript.Run(ctx, h.client, h.sessionKeys(id), withGraces(id, h.config.Redis.Prefix)...).Int64()
+		if err != nil {
+			const msg = "RedisHandler.expireSessions(%s) - expireSessionScript.Run > %w"
+			return released, fmt.Errorf(msg, id, err)
+		}
+		if n > 0 {
+			released += n
+		}
+	}
+	return released, nil
+}
+
+// AcquireInSession acquires the lock like Acquire and binds the new holder to the session,
+// it expires with the session then. It returns -2 if the session does not exist.
+func (h *RedisHandler) AcquireInSession(ctx context.Context, key string, owner string, session string, expected string, value string, ttl time.Duration) (int64, error) {
+	if h.Ping(ctx) != nil {
+		return 0, fmt.Errorf("failed to connect to Redis")
+	}
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		return 0, errSessionsInCluster
+	}
+	keys := append([]string{h.lockKey(ctx, key), h.fenceKey(ctx, key), h.queueKey(ctx, key), h.queueDeadlineKey(ctx, key), h.shadowKey(ctx, key), h.leaseKey(ctx, key)}, h.sessionKeys(session)...)
+	args := withGraces(value, ttl.Milliseconds(), time.Now().UnixMilli(), expected, owner, session)
+	return acquireScript.Run(ctx, h.client, keys, args...).Int64()
+}
+
+// eventChannel returns the pub/sub channel of the events of a lock in the namespace of ctx.
+func (h *RedisHandler) eventChannel(ctx context.Context, key string) string {
+	return h.namespacePrefix(ctx) + "events:" + key
+}
+
+// PublishEvent publishes the event value on the event channel of the lock.
+func (h *RedisHandler) PublishEvent(ctx context.Context, key string, value string) error {
+	if h.Ping(ctx) != nil {
+		return fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.Publish(ctx, h.eventChannel(ctx, key), value).Err()
+}
+
+// SubscribeEvents subscribes to the event channels of the locks with the key prefix in the namespace of ctx
+// and to the expired keyevent notifications. It delivers the published event values and the keys of the locks
+// that expired. Notifications need to be enabled on the server, see EnableKeyspaceNotifications.
+func (h *RedisHandler) SubscribeEvents(ctx context.Context, keyPrefix string) (<-chan string, <-chan string, error) {
+	expiredChannel := fmt.Sprintf("__keyevent@%d__:expired", h.db())
+	eventPattern := h.eventChannel(ctx, globEscape(keyPrefix)) + "*"
+	messages := make(chan *redis.Message)
+	forward := func(pubsub *redis.PubSub) {
+		go func(in <-chan *redis.Message) {
+			for msg := range in {
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(pubsub.Channel())
+	}
+
+	events := h.client.PSubscribe(ctx, eventPattern)
+	// published events reach every node of a cluster, but a node only notifies about its own keys expiring
+	masters := map[string]*redis.PubSub{}
+	closeAll := func() {
+		events.Close()
+		for _, pubsub := range masters {
+			pubsub.Close()
+		}
+	}
+	// wait for the subscriptions to be confirmed, so no event is missed afterwards
+	if _, err := events.Receive(ctx); err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("RedisHandler.SubscribeEvents - pubsub.Receive > %w", err)
+	}
+	forward(events)
+	if _, err := h.subscribeMasters(ctx, expiredChannel, masters, forward); err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("RedisHandler.SubscribeEvents - h.subscribeMasters > %w", err)
+	}
+	var refresh <-chan time.Time
+	stopRefresh := func() {}
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		ticker := time.NewTicker(clusterRefreshInterval)
+		refresh, stopRefresh = ticker.C, ticker.Stop
+	}
+
+	values := make(chan string)
+	expired := make(chan string)
+	lockPrefix := h.namespacePrefix(ctx) + "lock:"
+	go func() {
+		defer stopRefresh()
+		defer closeAll()
+		defer close(values)
+		defer close(expired)
+		for {
+			var out chan string
+			var value string
+			select {
+			case <-ctx.Done():
+				return
+			case <-refresh:
+				h.refreshMasters(ctx, expiredChannel, masters, forward)
+				continue
+			case msg := <-messages:
+				switch {
+				case msg.Pattern == eventPattern:
+					out, value = values, msg.Payload
+				case strings.HasPrefix(msg.Payload, lockPrefix):
+					key := h.untagged(strings.TrimPrefix(msg.Payload, lockPrefix))
+					if !strings.HasPrefix(key, keyPrefix) {
+						continue
+					}
+					out, value = expired, key
+				default:
+					continue
+				}
+			}
+			select {
+			case out <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return values, expired, nil
+}
+
+// subscribeMasters subscribes to the channel on every master that has no subscription yet and closes the
+// subscriptions of nodes that are no master anymore, subscriptions holds them by the address of their node.
+// It waits for the new subscriptions to be confirmed and returns how many were added.
+func (h *RedisHandler) subscribeMasters(ctx context.Context, channel string, subscriptions map[string]*redis.PubSub, forward func(*redis.PubSub)) (int, error) {
+	var mu sync.Mutex
+	current := map[string]bool{}
+	added := map[string]*redis.PubSub{}
+	err := h.forEachMaster(ctx, func(ctx context.Context, node redis.UniversalClient) error {
+		addr := ""
+		if client, ok := node.(*redis.Client); ok {
+			addr = client.Options().Addr
+		}
+		mu.Lock()
+		current[addr] = true
+		_, subscribed := subscriptions[addr]
+		mu.Unlock()
+		if subscribed {
+			return nil
+		}
+		pubsub := node.Subscribe(ctx, channel)
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			return fmt.Errorf("pubsub.Receive(%s) > %w", addr, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		added[addr] = pubsub
+		return nil
+	})
+	for addr, pubsub := range added {
+		subscriptions[addr] = pubsub
+		forward(pubsub)
+	}
+	if err != nil {
+		return len(added), fmt.Errorf("RedisHandler.subscribeMasters - h.forEachMaster > %w", err)
+	}
+	for addr, pubsub := range subscriptions {
+		if !current[addr] {
+			pubsub.Close()
+			delete(subscriptions, addr)
+		}
+	}
+	return len(added), nil
+}
+
+// refreshMasters follows a failover or resharding of the cluster, it subscribes SubscribeEvents to the masters
+// it does not know yet and enables their keyspace notifications. Expiries on a new master before are missed.
+func (h *RedisHandler) refreshMasters(ctx context.Context, channel string, subscriptions map[string]*redis.PubSub, forward func(*redis.PubSub)) {
+	added, err := h.subscribeMasters(ctx, channel, subscriptions, forward)
+	if err != nil {
+		h.logger.Warn("RedisHandler.SubscribeEvents - h.subscribeMasters > " + err.Error())
+	}
+	if added == 0 {
+		return
+	}
+	h.logger.Info(fmt.Sprintf("RedisHandler.SubscribeEvents - subscribed to %d new masters", added))
+	if err := h.EnableKeyspaceNotifications(ctx); err != nil {
+		h.logger.Warn("RedisHandler.SubscribeEvents - h.EnableKeyspaceNotifications > " + err.Error())
+	}
+}
+
+// GetLockShadow returns the shadow copy of the lock in the namespace of ctx, the last value the lock had
+// until shortly after it expired. It is empty if there is none.
+func (h *RedisHandler) GetLockShadow(ctx context.Context, key string) (string, error) {
+	if h.Ping(ctx) != nil {
+		return "", fmt.Errorf("failed to connect to Redis")
+	}
+	value, err := h.client.Get(ctx, h.shadowKey(ctx, key)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+// GetLease returns the last lease of the owner of the lock in the namespace of ctx, it is recorded
+// whenever the owner acquires or renews the lock. It is empty if there is none.
+func (h *RedisHandler) GetLease(ctx context.Context, key string, owner string) (string, error) {
+	if h.Ping(ctx) != nil {
+		return "", fmt.Errorf("failed to connect to Redis")
+	}
+	value, err := h.client.HGet(ctx, h.leaseKey(ctx, key), owner).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+// AppendHistory adds value to the history stream of the lock in the namespace of ctx. The stream keeps
+// at most maxEntries entries, none older than retention, and is removed retention after its last entry.
+func (h *RedisHandler) AppendHistory(ctx context.Context, key string, value string, maxEntries int64, retention time.Duration) error {
+	if h.Ping(ctx) != nil {
 		return fmt.Errorf("failed to connect to Redis")
 	}
-	return h.client.Del(h.ctx, h.config.Redis.Prefix+key).Err()
+	stream := h.historyKey(ctx, key)
+	minID := strconv.FormatInt(time.Now().Add(-retention).UnixMilli(), 10)
+	_, err := h.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: stream, MaxLen: maxEntries, Values: map[string]interface{}{"entry": value}})
+		pipe.XTrimMinID(ctx, stream, minID)
+		pipe.PExpire(ctx, stream, retention)
+		return nil
+	})
+	return err
+}
+
+// ListHistory returns the entries of the history stream of the lock in the namespace of ctx that were added
+// between from and to, from oldest to newest. A zero time leaves its end of the range open.
+func (h *RedisHandler) ListHistory(ctx context.Context, key string, from time.Time, to time.Time) ([]string, error) {
+	if h.Ping(ctx) != nil {
+		return nil, fmt.Errorf("failed to connect to Redis")
+	}
+	start, end := "-", "+"
+	if !from.IsZero() {
+		start = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		end = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+	messages, err := h.client.XRange(ctx, h.historyKey(ctx, key), start, end).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if value, ok := message.Values["entry"].(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// ClaimEvent reports whether the event id was not claimed within ttl before.
+func (h *RedisHandler) ClaimEvent(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.SetNX(ctx, h.config.Redis.Prefix+"event-claim:"+id, 1, ttl).Result()
+}
+
+// globEscape escapes the glob special characters of s for a Redis pattern.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// webhookKeys returns the Redis keys of the webhooks, the hash of all webhooks by id
+// and the delivery log of the webhook with the id.
+func (h *RedisHandler) webhookKeys(id string) []string {
+	return []string{
+		h.config.Redis.Prefix + "webhooks",
+		h.config.Redis.Prefix + "webhook-deliveries:" + id,
+	}
+}
+
+// CreateWebhook stores a new webhook and reports whether the id was still unused.
+func (h *RedisHandler) CreateWebhook(ctx context.Context, id string, value string) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.HSetNX(ctx, h.webhookKeys(id)[0], id, value).Result()
+}
+
+// GetWebhook returns the webhook, it is empty if the webhook does not exist.
+func (h *RedisHandler) GetWebhook(ctx context.Context, id string) (string, error) {
+	if h.Ping(ctx) != nil {
+		return "", fmt.Errorf("failed to connect to Redis")
+	}
+	value, err := h.client.HGet(ctx, h.webhookKeys(id)[0], id).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+// ListWebhooks returns all registered webhooks.
+func (h *RedisHandler) ListWebhooks(ctx context.Context) ([]string, error) {
+	if h.Ping(ctx) != nil {
+		return nil, fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.HVals(ctx, h.webhookKeys("")[0]).Result()
+}
+
+// DeleteWebhook removes the webhook with its delivery log and reports whether it existed.
+func (h *RedisHandler) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	if h.Ping(ctx) != nil {
+		return false, fmt.Errorf("failed to connect to Redis")
+	}
+	keys := h.webhookKeys(id)
+	var deleted *redis.IntCmd
+	_, err := h.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, keys[0], id)
+		pipe.Del(ctx, keys[1])
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted.Val() == 1, nil
+}
+
+// LogWebhookDelivery adds the delivery to the log of the webhook, it keeps the last size deliveries.
+func (h *RedisHandler) LogWebhookDelivery(ctx context.Context, id string, value string, size int64) error {
+	if h.Ping(ctx) != nil {
+		return fmt.Errorf("failed to connect to Redis")
+	}
+	key := h.webhookKeys(id)[1]
+	_, err := h.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, value)
+		pipe.LTrim(ctx, key, 0, size-1)
+		return nil
+	})
+	return err
+}
+
+// ListWebhookDeliveries returns the delivery log of the webhook, the latest delivery first.
+func (h *RedisHandler) ListWebhookDeliveries(ctx context.Context, id string) ([]string, error) {
+	if h.Ping(ctx) != nil {
+		return nil, fmt.Errorf("failed to connect to Redis")
+	}
+	return h.client.LRange(ctx, h.webhookKeys(id)[1], 0, -1).Result()
 }
 
 // GetMultiple retrieves multiple locks by their keys.
-func (h *RedisHandler) GetMultiple(keys []string) ([]string, error) {
+func (h *RedisHandler) GetMultiple(ctx context.Context, keys []string) ([]string, error) {
 	h.logger.Debug(fmt.Sprintf("RedisHandler.GetMultiple - keys: %v", keys))
+	if _, ok := h.client.(*redis.ClusterClient); ok {
+		return h.getEach(ctx, keys)
+	}
 	// Fetch multiple keys in one call
-	results, err := h.client.MGet(h.ctx, keys...).Result()
+	results, err := h.client.MGet(ctx, keys...).Result()
 	if err != nil {
 		return nil, fmt.Errorf("RedisHandler.GetMultiple - MGet failed: %w", err)
 	}
@@ -109,34 +1072,152 @@ func (h *RedisHandler) GetMultiple(keys []string) ([]string, error) {
 	return values, nil
 }
 
+// getEach retrieves multiple locks in one pipeline, in cluster mode the keys are spread across the
+// slots while MGET only works within one.
+func (h *RedisHandler) getEach(ctx context.Context, keys []string) ([]string, error) {
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := h.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("RedisHandler.getEach - client.Pipelined > %w", err)
+	}
+	values := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		// keys that expired since they were listed are skipped
+		if cmd.Err() == nil {
+			values = append(values, cmd.Val())
+		}
+	}
+	return values, nil
+}
+
 // Ping checks if the Redis server is accessible.
-func (h *RedisHandler) Ping() error {
-	return h.client.Ping(h.ctx).Err()
+func (h *RedisHandler) Ping(ctx context.Context) error {
+	return h.client.Ping(ctx).Err()
+}
+
+// WatchFailovers listens to the failovers every sentinel announces until ctx is done, each switch
+// of the master is logged and counted with metrics once. The new master may not notify keyspace
+// events yet, so they are enabled again.
+func (h *RedisHandler) WatchFailovers(ctx context.Context, metrics domain.MetricsRecorder) {
+	for _, sentinel := range h.config.Redis.Sentinels {
+		client := redis.NewSentinelClient(&redis.Options{
+			Addr:      sentinel.Host + ":" + sentinel.Port,
+			Username:  h.config.Redis.SentinelUsername,
+			Password:  h.config.Redis.SentinelPassword.Value,
+			TLSConfig: h.tls,
+		})
+		pubsub := client.Subscribe(ctx, "+switch-master")
+		// a sentinel that is not reachable yet is subscribed to once it is
+		if _, err := pubsub.Receive(ctx); err != nil {
+			const msg = "RedisHandler.WatchFailovers - pubsub.Receive(%s:%s) > %s"
+			h.logger.Warn(fmt.Sprintf(msg, sentinel.Host, sentinel.Port, err))
+		}
+		go func() {
+			defer client.Close()
+			defer pubsub.Close()
+			messages := pubsub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case message, ok := <-messages:
+					if !ok {
+						return
+					}
+					// <master name> <old ip> <old port> <new ip> <new port>
+					parts := strings.Split(message.Payload, " ")
+					if len(parts) != 5 || parts[0] != h.config.Redis.Name {
+						continue
+					}
+					h.failedOver(net.JoinHostPort(parts[1], parts[2]), net.JoinHostPort(parts[3], parts[4]), metrics)
+				}
+			}
+		}()
+	}
+}
+
+// failedOver records the switch of the master to addr, every sentinel announces the same switch.
+func (h *RedisHandler) failedOver(previous string, addr string, metrics domain.MetricsRecorder) {
+	h.mu.Lock()
+	if h.master == addr {
+		h.mu.Unlock()
+		return
+	}
+	h.master = addr
+	h.mu.Unlock()
+	const msg = "RedisHandler.WatchFailovers - master %s failed over from %s to %s"
+	h.logger.Warn(fmt.Sprintf(msg, h.config.Redis.Name, previous, addr))
+	metrics.IncrementRedisFailovers()
+	if err := h.EnableKeyspaceNotifications(context.Background()); err != nil {
+		h.logger.Warn("RedisHandler.WatchFailovers - h.EnableKeyspaceNotifications > " + err.Error())
+	}
 }
 
+// Close stops the session sweeper, closes the keyspace subscriptions and closes the Redis client.
 func (h *RedisHandler) Close() error {
+	h.stopOnce.Do(func() { close(h.stop) })
+	<-h.swept
+	h.keyspace.close()
 	return h.client.Close()
 }
 
-// Count returns the number of locks stored in Redis.
-func (h *RedisHandler) Count() (int, error) {
-	var cursor uint64
-	var count int
+// Count returns the number of locks stored in Redis for the namespace of ctx.
+func (h *RedisHandler) Count(ctx context.Context) (int, error) {
+	keys, err := h.scanKeys(ctx, h.lockKey(ctx, "*"))
+	if err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
 
-	for {
-		var keys []string
-		var err error
-		keys, cursor, err = h.client.Scan(context.Background(), cursor, h.config.Redis.Prefix+"*", 1000).Result()
-		if err != nil {
-			return 0, err
+// scanKeys returns all keys matching the pattern, in cluster mode of every master.
+func (h *RedisHandler) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	var mu sync.Mutex
+	keys := []string{}
+	err := h.forEachMaster(ctx, func(ctx context.Context, node redis.UniversalClient) error {
+		iter := node.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
 		}
+		return iter.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// forEachMaster calls fn with the server or, in cluster mode, concurrently with every master.
+func (h *RedisHandler) forEachMaster(ctx context.Context, fn func(ctx context.Context, node redis.UniversalClient) error) error {
+	if cluster, ok := h.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return fn(ctx, node)
+		})
+	}
+	return fn(ctx, h.client)
+}
 
-		count += len(keys)
+// db returns the database index keyspace notifications are published for, a cluster only has database 0.
+func (h *RedisHandler) db() int {
+	if client, ok := h.client.(*redis.Client); ok {
+		return client.Options().DB
+	}
+	return 0
+}
 
-		if cursor == 0 {
-			break
+// hashTag returns the part of the Redis key that determines its cluster slot.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
 		}
 	}
-
-	return count, nil
+	return key
 }
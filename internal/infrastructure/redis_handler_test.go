@@ -0,0 +1,385 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+func TestWatchFailoversRecordsEachSwitchOnce(t *testing.T) {
+	// Arrange
+	sentinels := []*miniredis.Miniredis{miniredis.RunT(t), miniredis.RunT(t)}
+	var config domain.Config
+	config.Redis.Name = "redis-master"
+	for _, sentinel := range sentinels {
+		config.Redis.Sentinels = append(config.Redis.Sentinels, domain.RedisNode{Host: sentinel.Host(), Port: sentinel.Port()})
+	}
+	handler, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	mockMetrics := new(MockMetricsRecorder)
+	mockMetrics.On("IncrementRedisFailovers").Return()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// Act
+	handler.WatchFailovers(ctx, mockMetrics)
+	for _, sentinel := range sentinels {
+		sentinel.Publish("+switch-master", "redis-master 10.0.0.1 6379 10.0.0.2 6379")
+		sentinel.Publish("+switch-master", "other-master 10.0.0.3 6379 10.0.0.4 6379")
+	}
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		handler.mu.Lock()
+		defer handler.mu.Unlock()
+		return handler.master == "10.0.0.2:6379"
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	mockMetrics.AssertNumberOfCalls(t, "IncrementRedisFailovers", 1)
+}
+
+// newTestClusterHandler returns a RedisHandler in cluster mode on an in-process Redis stand-in
+// that serves all slots.
+func newTestClusterHandler(t *testing.T) (*RedisHandler, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	var config domain.Config
+	config.Redis.Prefix = "test."
+	config.Redis.Cluster = []domain.RedisNode{{Host: server.Host(), Port: server.Port()}}
+	handler, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	return handler, server
+}
+
+func TestClusterKeysShareHashTag(t *testing.T) {
+	// Arrange
+	handler, server := newTestClusterHandler(t)
+	ctx := context.Background()
+
+	// Act
+	token, err := handler.Acquire(ctx, "deploy/api", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+	values, getErr := handler.Get(ctx, "*")
+	count, countErr := handler.Count(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.NoError(t, countErr)
+	assert.Equal(t, int64(1), token)
+	assert.Len(t, values, 1)
+	assert.Equal(t, 1, count)
+	for _, key := range []string{"test.lock:{deploy/api}", "test.fence:{deploy/api}", "test.lock-shadow:{deploy/api}", "test.lock-leases:{deploy/api}"} {
+		assert.True(t, server.Exists(key), key)
+	}
+}
+
+func TestClusterAcquireMultipleNeedsCommonSlot(t *testing.T) {
+	// Arrange
+	handler, _ := newTestClusterHandler(t)
+	ctx := context.Background()
+	ttls := []time.Duration{testRedlockTTL, testRedlockTTL}
+	values := []string{testRedlockValue(t, "ci"), testRedlockValue(t, "ci")}
+
+	// Act
+	_, spreadErr := handler.AcquireMultiple(ctx, "ci", []string{"api", "db"}, []string{"", ""}, values, ttls)
+	tokens, err := handler.AcquireMultiple(ctx, "ci", []string{"{deploy}/api", "{deploy}/db"}, []string{"", ""}, values, ttls)
+
+	// Assert
+	var validationErr *domain.ValidationError
+	assert.ErrorAs(t, spreadErr, &validationErr)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 1}, tokens)
+}
+
+func TestClusterSubscribeMastersFollowsTopology(t *testing.T) {
+	// Arrange
+	handler, server := newTestClusterHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	demoted := handler.client.Subscribe(ctx)
+	subscriptions := map[string]*redis.PubSub{"10.0.0.1:6379": demoted}
+	messages := make(chan *redis.Message, 1)
+	forward := func(pubsub *redis.PubSub) {
+		go func() {
+			for msg := range pubsub.Channel() {
+				messages <- msg
+			}
+		}()
+	}
+
+	// Act
+	added, err := handler.subscribeMasters(ctx, "__keyevent@0__:expired", subscriptions, forward)
+	server.Publish("__keyevent@0__:expired", "test.lock:{deploy/api}")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, added)
+	assert.Len(t, subscriptions, 1)
+	assert.Contains(t, subscriptions, server.Addr())
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "test.lock:{deploy/api}", msg.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("expiry of the new master was not received")
+	}
+}
+
+func TestClusterSubscribeEventsReportsUntaggedKey(t *testing.T) {
+	// Arrange
+	handler, server := newTestClusterHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	_, expired, err := handler.SubscribeEvents(ctx, "deploy/")
+	assert.NoError(t, err)
+
+	// Act
+	server.Publish("__keyevent@0__:expired", "test.lock:{build/api}")
+	server.Publish("__keyevent@0__:expired", "test.lock:{deploy/api}")
+
+	// Assert
+	select {
+	case key := <-expired:
+		assert.Equal(t, "deploy/api", key)
+	case <-time.After(time.Second):
+		t.Fatal("expired lock was not reported")
+	}
+}
+
+func TestNewRedisHandlerAuthenticatesAndSelectsDB(t *testing.T) {
+	// Arrange
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("locking-service", "s3cret")
+	var config domain.Config
+	config.Redis.Host, config.Redis.Port, config.Redis.Prefix = server.Host(), server.Port(), "test."
+	config.Redis.Username = "locking-service"
+	config.Redis.Password = domain.Secret{Value: "s3cret"}
+	config.Redis.DB = 2
+	handler, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+
+	// Act
+	token, err := handler.Acquire(context.Background(), "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), token)
+	assert.True(t, server.DB(2).Exists("test.lock:deploy"))
+	assert.False(t, server.DB(0).Exists("test.lock:deploy"))
+}
+
+func TestNewRedisHandlerVerifiesServerWithCA(t *testing.T) {
+	// Arrange
+	caFile, certificate := writeTestCertificate(t, "redis.internal")
+	server, err := miniredis.RunTLS(&tls.Config{Certificates: []tls.Certificate{certificate}})
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+	var config domain.Config
+	config.Redis.Host, config.Redis.Port, config.Redis.Prefix = server.Host(), server.Port(), "test."
+	config.Redis.TLS = domain.RedisTLS{Enabled: true, CAFile: caFile, ServerName: "redis.internal"}
+	trusted, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { trusted.Close() })
+	config.Redis.TLS.ServerName = "other.internal"
+	mismatched, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { mismatched.Close() })
+
+	// Act
+	trustedErr := trusted.Ping(context.Background())
+	mismatchedErr := mismatched.Ping(context.Background())
+
+	// Assert
+	assert.NoError(t, trustedErr)
+	assert.Error(t, mismatchedErr)
+}
+
+func TestNewRedisHandlerMissingCA(t *testing.T) {
+	// Arrange
+	var config domain.Config
+	config.Redis.TLS = domain.RedisTLS{Enabled: true, CAFile: filepath.Join(t.TempDir(), "ca.crt")}
+
+	// Act
+	handler, err := NewRedisHandler(config, NewMockLogger())
+
+	// Assert
+	assert.Nil(t, handler)
+	assert.Error(t, err)
+}
+
+// writeTestCertificate creates a self-signed certificate for the host name, it returns the file
+// of the certificate to trust and the certificate with its key for the server.
+func writeTestCertificate(t *testing.T, host string) (string, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(path, certPEM, 0o600))
+	return path, certificate
+}
+
+func TestWatchSharesSubscription(t *testing.T) {
+	// Arrange
+	server := miniredis.RunT(t)
+	var config domain.Config
+	config.Redis.Prefix = "test."
+	config.Redis.Host, config.Redis.Port = server.Host(), server.Port()
+	handler, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	first, err := handler.Watch(firstCtx, "deploy")
+	assert.NoError(t, err)
+	second, err := handler.Watch(ctx, "deploy")
+	assert.NoError(t, err)
+
+	// Act
+	server.Publish("__keyspace@0__:test.lock:deploy", "del")
+
+	// Assert
+	for _, changes := range []<-chan struct{}{first, second} {
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("watcher was not signaled")
+		}
+	}
+	assert.Equal(t, map[string]int{"__keyspace@0__:test.lock:deploy": 1}, server.PubSubNumSub("__keyspace@0__:test.lock:deploy"))
+	stopFirst()
+	server.Publish("__keyspace@0__:test.queue:deploy", "zrem")
+	select {
+	case <-second:
+	case <-time.After(time.Second):
+		t.Fatal("remaining watcher was not signaled")
+	}
+	cancel()
+	assert.Eventually(t, func() bool {
+		return server.PubSubNumSub("__keyspace@0__:test.lock:deploy")["__keyspace@0__:test.lock:deploy"] == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRedisSessionExpiryReleasesLocks(t *testing.T) {
+	// Arrange
+	server := miniredis.RunT(t)
+	var config domain.Config
+	config.Redis.Prefix = "test."
+	config.Redis.Host, config.Redis.Port = server.Host(), server.Port()
+	handler, err := NewRedisHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	ctx := context.Background()
+	holdInSession(t, ctx, handler, 100*time.Millisecond)
+	server.FastForward(100 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	// Act
+	released, err := handler.expireSessions(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), released)
+	assert.Equal(t, map[string][]string{"shared": {"other"}}, sessionOwners(t, ctx, handler))
+	assert.False(t, server.Exists("test.session-locks:s1"))
+}
+
+func TestRedisLeasesEndWithTheLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		end    func(ctx context.Context, handler *RedisHandler, current string) (bool, error)
+		forget bool
+	}{
+		{
+			name: "Takeover",
+			end: func(ctx context.Context, handler *RedisHandler, current string) (bool, error) {
+				token, err := handler.Takeover(ctx, "deploy", current, testRedlockValue(t, "admin"), time.Minute)
+				return token > 0, err
+			},
+		},
+		{
+			name: "Revoke",
+			end: func(ctx context.Context, handler *RedisHandler, current string) (bool, error) {
+				return handler.CompareAndRevoke(ctx, "deploy", current)
+			},
+		},
+		{
+			name: "Release",
+			end: func(ctx context.Context, handler *RedisHandler, current string) (bool, error) {
+				return handler.CompareAndRelease(ctx, "deploy", "ci", current, "", 0)
+			},
+			forget: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			server := miniredis.RunT(t)
+			var config domain.Config
+			config.Redis.Prefix = "test."
+			config.Redis.Host, config.Redis.Port = server.Host(), server.Port()
+			handler, err := NewRedisHandler(config, NewMockLogger())
+			assert.NoError(t, err)
+			t.Cleanup(func() { handler.Close() })
+			ctx := context.Background()
+			value, err := json.Marshal([]*domain.Lock{{Key: "deploy", Owner: "ci", Mode: domain.LockModeExclusive, HoldCount: 1, ExpireAt: time.Now().Add(time.Minute)}})
+			assert.NoError(t, err)
+			_, err = handler.Acquire(ctx, "deploy", "ci", "", string(value), time.Minute)
+			assert.NoError(t, err)
+			current, err := handler.Get(ctx, "deploy")
+			assert.NoError(t, err)
+
+			// Act
+			done, err := tt.end(ctx, handler, current[0])
+			lease, leaseErr := handler.GetLease(ctx, "deploy", "ci")
+
+			// Assert
+			assert.NoError(t, err)
+			assert.NoError(t, leaseErr)
+			assert.True(t, done)
+			if tt.forget {
+				assert.Empty(t, lease)
+				return
+			}
+			var ended domain.Lock
+			assert.NoError(t, json.Unmarshal([]byte(lease), &ended))
+			assert.False(t, ended.ExpireAt.After(time.Now()))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// keyspaceWatcher shares the subscriptions to keyspace notifications between all watchers of a RedisHandler,
+// so waiting requests don't need a connection each. A notification is fanned out to every watcher of its channel.
+// Outside of cluster mode all channels share one subscription. In cluster mode a node only publishes the
+// notifications of its own keys, the channels of a hash tag share a subscription to the node that holds it.
+type keyspaceWatcher struct {
+	client redis.UniversalClient
+	logger domain.Logger
+	mu     sync.Mutex
+	shards map[string]*keyspaceShard
+}
+
+// keyspaceShard is a subscription of the keyspaceWatcher and the watchers of its channels.
+type keyspaceShard struct {
+	pubsub *redis.PubSub
+	// watchers are signaled on every notification of their channel
+	watchers map[string]map[chan struct{}]struct{}
+	// confirmations are closed once the subscription to their channel is confirmed
+	confirmations map[string][]chan struct{}
+}
+
+// newKeyspaceWatcher returns a keyspaceWatcher of the client, subscriptions are made on the first watch.
+func newKeyspaceWatcher(client redis.UniversalClient, logger domain.Logger) *keyspaceWatcher {
+	return &keyspaceWatcher{
+		client: client,
+		logger: logger,
+		shards: map[string]*keyspaceShard{},
+	}
+}
+
+// watch signals on the returned channel whenever one of the channels receives a notification, until ctx is done.
+// It returns once all channels are subscribed, so no notification published afterwards is missed.
+// The channels need to share a hash tag in cluster mode.
+func (w *keyspaceWatcher) watch(ctx context.Context, channels ...string) (<-chan struct{}, error) {
+	id := ""
+	if _, ok := w.client.(*redis.ClusterClient); ok {
+		id = hashTag(channels[0])
+	}
+	changes := make(chan struct{}, 1)
+
+	w.mu.Lock()
+	shard, ok := w.shards[id]
+	if !ok {
+		shard = &keyspaceShard{
+			pubsub:        w.client.Subscribe(context.WithoutCancel(ctx)),
+			watchers:      map[string]map[chan struct{}]struct{}{},
+			confirmations: map[string][]chan struct{}{},
+		}
+		w.shards[id] = shard
+	}
+	var subscribe []string
+	var confirmations []chan struct{}
+	for _, channel := range channels {
+		if _, ok := shard.watchers[channel]; !ok {
+			shard.watchers[channel] = map[chan struct{}]struct{}{}
+			shard.confirmations[channel] = nil
+			subscribe = append(subscribe, channel)
+		}
+		shard.watchers[channel][changes] = struct{}{}
+		// wait for the subscription of the channel unless it is confirmed already
+		if pending, ok := shard.confirmations[channel]; ok {
+			confirmation := make(chan struct{})
+			shard.confirmations[channel] = append(pending, confirmation)
+			confirmations = append(confirmations, confirmation)
+		}
+	}
+	var err error
+	if len(subscribe) > 0 {
+		// the first subscribe connects the subscription, in cluster mode to the node of the channels
+		err = shard.pubsub.Subscribe(context.WithoutCancel(ctx), subscribe...)
+	}
+	if !ok && err == nil {
+		go w.receive(shard)
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		w.unwatch(id, changes, channels)
+		return nil, fmt.Errorf("keyspaceWatcher.watch - pubsub.Subscribe > %w", err)
+	}
+	for _, confirmation := range confirmations {
+		select {
+		case <-confirmation:
+		case <-ctx.Done():
+			w.unwatch(id, changes, channels)
+			return nil, fmt.Errorf("keyspaceWatcher.watch - subscription not confirmed > %w", ctx.Err())
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		w.unwatch(id, changes, channels)
+	}()
+	return changes, nil
+}
+
+// unwatch removes the watcher of the channels, channels without watchers are unsubscribed
+// and a subscription without channels is closed.
+func (w *keyspaceWatcher) unwatch(id string, changes chan struct{}, channels []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	shard, ok := w.shards[id]
+	if !ok {
+		return
+	}
+	var unsubscribe []string
+	for _, channel := range channels {
+		watchers, ok := shard.watchers[channel]
+		if !ok {
+			continue
+		}
+		delete(watchers, changes)
+		if len(watchers) == 0 {
+			delete(shard.watchers, channel)
+			delete(shard.confirmations, channel)
+			unsubscribe = append(unsubscribe, channel)
+		}
+	}
+	if len(shard.watchers) == 0 {
+		delete(w.shards, id)
+		if err := shard.pubsub.Close(); err != nil {
+			w.logger.Warn("keyspaceWatcher.unwatch - pubsub.Close > " + err.Error())
+		}
+		return
+	}
+	if len(unsubscribe) > 0 {
+		if err := shard.pubsub.Unsubscribe(context.Background(), unsubscribe...); err != nil {
+			w.logger.Warn("keyspaceWatcher.unwatch - pubsub.Unsubscribe > " + err.Error())
+		}
+	}
+}
+
+// receive fans the notifications of the subscription out to the watchers of their channel until it is closed.
+// A channel that is subscribed again after a reconnect signals its watchers as well, notifications may have
+// been missed in between.
+func (w *keyspaceWatcher) receive(shard *keyspaceShard) {
+	for message := range shard.pubsub.ChannelWithSubscriptions() {
+		w.mu.Lock()
+		switch message := message.(type) {
+		case *redis.Subscription:
+			if message.Kind != "subscribe" {
+				break
+			}
+			confirmations, pending := shard.confirmations[message.Channel]
+			if !pending {
+				shard.signal(message.Channel)
+				break
+			}
+			for _, confirmation := range confirmations {
+				close(confirmation)
+			}
+			delete(shard.confirmations, message.Channel)
+		case *redis.Message:
+			shard.signal(message.Channel)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// signal signals the watchers of the channel.
+func (s *keyspaceShard) signal(channel string) {
+	for changes := range s.watchers[channel] {
+		// coalesce notifications, the receiver only needs to know that something changed
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close closes all subscriptions.
+func (w *keyspaceWatcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, shard := range w.shards {
+		shard.pubsub.Close()
+		delete(w.shards, id)
+	}
+}
@@ -0,0 +1,468 @@
+package infrastructure
+
+import "github.com/redis/go-redis/v9"
+
+// pruneQueueLua defines prune_queue(queue, deadlines, now), it removes all waiters
+// whose deadline passed from the wait queue of a lock.
+const pruneQueueLua = `
+local function prune_queue(queue, deadlines, now)
+	local expired = redis.call("ZRANGEBYSCORE", deadlines, "-inf", now)
+	if #expired > 0 then
+		redis.call("ZREM", queue, unpack(expired))
+		redis.call("ZREM", deadlines, unpack(expired))
+	end
+end
+`
+
+// shadowLua defines set_shadow(shadow, leases, value, ttl), it stores the copy of a lock value that outlives
+// the lock with a TTL of ttl milliseconds by shadowGrace, so the last holders are still known when the lock expires.
+// It also records the lease of every holder by owner in the lease hash leases, which outlives the lock
+// by leaseGrace, so an owner that lost its lease learns when it ended.
+// It defines end_leases(leases, first, last) as well, it stores the ended leases of the owners given as pairs
+// of owner and lease in ARGV[first] to ARGV[last], so they learn at once that they lost the lock.
+// And it defines lock_keys(key, prefix), it returns the shadow and the lease key of the lock key in any
+// namespace of the key prefix. Only the session scripts use it, they don't run in cluster mode.
+// Scripts that use shadowLua get shadowGrace and leaseGrace in milliseconds as their last two ARGV, see withGraces.
+const shadowLua = `
+local shadow_grace, lease_grace = tonumber(ARGV[#ARGV - 1]), tonumber(ARGV[#ARGV])
+local function set_shadow(shadow, leases, value, ttl)
+	redis.call("SET", shadow, value, "PX", ttl + shadow_grace)
+	for _, holder in ipairs(cjson.decode(value)) do
+		redis.call("HSET", leases, holder["owner"], cjson.encode(holder))
+	end
+	redis.call("PEXPIRE", leases, ttl + lease_grace)
+end
+local function end_leases(leases, first, last)
+	for i = first, last, 2 do
+		redis.call("HSET", leases, ARGV[i], ARGV[i + 1])
+	end
+	if redis.call("PTTL", leases) < lease_grace then
+		redis.call("PEXPIRE", leases, lease_grace)
+	end
+end
+local function lock_keys(key, prefix)
+	local at = string.find(key, "lock:", #prefix + 1, true)
+	local head, tail = string.sub(key, 1, at - 1), string.sub(key, at + 5)
+	return head .. "lock-shadow:" .. tail, head .. "lock-leases:" .. tail
+end
+`
+
+// acquireScript stores the holders ARGV[1] in KEYS[1] with a TTL of ARGV[2] milliseconds if the current value
+// of KEYS[1] equals ARGV[4] (an empty ARGV[4] means KEYS[1] must not exist) and the wait queue KEYS[3]
+// (deadlines in KEYS[4]) is empty or starts with the acquiring owner ARGV[5].
+// On success it removes the owner from the queue, increments the fencing counter KEYS[2], records the
+// new value as fencingToken of every holder without one, updates the shadow copy KEYS[5] with the leases
+// KEYS[6] and returns it.
+// Holders that all have a fencing token already are stored as given.
+// It returns 0 if other owners are queued first and -1 if the current value did not match.
+// ARGV[3] is the current time in milliseconds.
+// With the session KEYS[7] and its lock index KEYS[8] the new holder is bound to the session ARGV[6]:
+// it returns -2 if the session does not exist, otherwise the holder expires with the session
+// and KEYS[1] is added to the index. The lock and the index outlive the session by shadowGrace,
+// so the locks of an expired session are released at once by expireSessionScript.
+var acquireScript = redis.NewScript(pruneQueueLua + shadowLua + `
+prune_queue(KEYS[3], KEYS[4], ARGV[3])
+local session = nil
+if #KEYS > 6 then
+	session = redis.call("GET", KEYS[7])
+	if not session then
+		return -2
+	end
+	session = cjson.decode(session)
+end
+local current = redis.call("GET", KEYS[1])
+if (current or "") ~= ARGV[4] then
+	return -1
+end
+local head = redis.call("ZRANGE", KEYS[3], 0, 0)
+if #head > 0 and head[1] ~= ARGV[5] then
+	return 0
+end
+redis.call("ZREM", KEYS[3], ARGV[5])
+redis.call("ZREM", KEYS[4], ARGV[5])
+local token = redis.call("INCR", KEYS[2])
+local ttl = tonumber(ARGV[2])
+local holders, value = cjson.decode(ARGV[1]), ARGV[1]
+for _, holder in ipairs(holders) do
+	if holder["fencingToken"] == 0 then
+		holder["fencingToken"] = token
+		value = nil
+		if session and holder["sessionId"] == ARGV[6] then
+			holder["expireAt"] = session["expireAt"]
+		end
+	end
+end
+if session then
+	local remaining = redis.call("PTTL", KEYS[7]) + shadow_grace
+	ttl = math.max(ttl, remaining)
+	redis.call("SADD", KEYS[8], KEYS[1])
+	redis.call("PEXPIRE", KEYS[8], remaining)
+end
+value = value or cjson.encode(holders)
+redis.call("SET", KEYS[1], value, "PX", ttl)
+set_shadow(KEYS[5], KEYS[6], value, ttl)
+return token
+`)
+
+// acquireMultipleScript acquires several locks at once, all of them or none. Every lock uses six keys
+// in KEYS: the lock, its fencing counter, its wait queue, the queue deadlines, its shadow copy and its leases. Every lock uses three
+// ARGV after the owner ARGV[1] and the current time ARGV[2] in milliseconds: the expected value, the
+// holders and the TTL in milliseconds. Each lock is checked like in acquireScript first.
+// If all of them pass, it stores all locks like acquireScript and returns their fencing tokens in order.
+// Otherwise nothing is stored and it returns per lock 1 if it passed, 0 if other owners
+// are queued first or -1 if the current value did not match.
+var acquireMultipleScript = redis.NewScript(pruneQueueLua + shadowLua + `
+local owner, now = ARGV[1], ARGV[2]
+local results, failed = {}, false
+for i = 1, #KEYS / 6 do
+	local k, a = (i - 1) * 6, 2 + (i - 1) * 3
+	prune_queue(KEYS[k + 3], KEYS[k + 4], now)
+	local current = redis.call("GET", KEYS[k + 1])
+	local head = redis.call("ZRANGE", KEYS[k + 3], 0, 0)
+	if (current or "") ~= ARGV[a + 1] then
+		results[i], failed = -1, true
+	elseif #head > 0 and head[1] ~= owner then
+		results[i], failed = 0, true
+	else
+		results[i] = 1
+	end
+end
+if failed then
+	return results
+end
+for i = 1, #KEYS / 6 do
+	local k, a = (i - 1) * 6, 2 + (i - 1) * 3
+	redis.call("ZREM", KEYS[k + 3], owner)
+	redis.call("ZREM", KEYS[k + 4], owner)
+	local token = redis.call("INCR", KEYS[k + 2])
+	local holders, value = cjson.decode(ARGV[a + 2]), ARGV[a + 2]
+	for _, holder in ipairs(holders) do
+		if holder["fencingToken"] == 0 then
+			holder["fencingToken"] = token
+			value = nil
+		end
+	end
+	value = value or cjson.encode(holders)
+	redis.call("SET", KEYS[k + 1], value, "PX", ARGV[a + 3])
+	set_shadow(KEYS[k + 5], KEYS[k + 6], value, tonumber(ARGV[a + 3]))
+	results[i] = token
+end
+return results
+`)
+
+// takeoverScript replaces the holders of KEYS[1] with ARGV[1] and a TTL of ARGV[2] milliseconds if its
+// current value equals ARGV[3], the wait queue is bypassed. It increments the fencing counter KEYS[2],
+// records the new value as fencingToken of every holder without one (holders that all have one are
+// stored as given), updates the shadow copy KEYS[3] with the leases KEYS[4] and returns it. The ended leases
+// of the replaced holders follow in ARGV as pairs of owner and lease, see end_leases.
+// It returns -1 if the current value did not match.
+var takeoverScript = redis.NewScript(shadowLua + `
+if (redis.call("GET", KEYS[1]) or "") ~= ARGV[3] then
+	return -1
+end
+end_leases(KEYS[4], 4, #ARGV - 2)
+local token = redis.call("INCR", KEYS[2])
+local holders, value = cjson.decode(ARGV[1]), ARGV[1]
+for _, holder in ipairs(holders) do
+	if holder["fencingToken"] == 0 then
+		holder["fencingToken"] = token
+		value = nil
+	end
+end
+value = value or cjson.encode(holders)
+redis.call("SET", KEYS[1], value, "PX", ARGV[2])
+set_shadow(KEYS[3], KEYS[4], value, tonumber(ARGV[2]))
+return token
+`)
+
+// raiseFenceScript raises the fencing counter KEYS[1] to ARGV[1] unless it is higher already.
+var raiseFenceScript = redis.NewScript(`
+if tonumber(redis.call("GET", KEYS[1]) or "0") < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// compareAndDeleteScript deletes KEYS[1] only if its value equals ARGV[1].
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// compareAndSwapScript replaces KEYS[1] with ARGV[2] and a TTL of ARGV[3] milliseconds
+// only if its value equals ARGV[1], the shadow copy KEYS[2] and the leases KEYS[3] are updated with it.
+var compareAndSwapScript = redis.NewScript(shadowLua + `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	set_shadow(KEYS[2], KEYS[3], ARGV[2], tonumber(ARGV[3]))
+	return 1
+end
+return 0
+`)
+
+// compareAndReleaseScript replaces KEYS[1] like compareAndSwapScript, or deletes it if ARGV[2] is empty,
+// and removes the lease of the owner ARGV[4] from the leases KEYS[3]. It returns 1 if KEYS[1] was replaced.
+var compareAndReleaseScript = redis.NewScript(shadowLua + `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	set_shadow(KEYS[2], KEYS[3], ARGV[2], tonumber(ARGV[3]))
+end
+redis.call("HDEL", KEYS[3], ARGV[4])
+return 1
+`)
+
+// compareAndRevokeScript deletes KEYS[1] only if its value equals ARGV[1] and stores the ended leases
+// of its holders in the leases KEYS[2], they follow in ARGV as pairs of owner and lease, see end_leases.
+// It returns 1 if KEYS[1] was deleted.
+var compareAndRevokeScript = redis.NewScript(shadowLua + `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+end_leases(KEYS[2], 2, #ARGV - 2)
+return 1
+`)
+
+// enqueueScript appends the owner ARGV[1] to the wait queue KEYS[1], a waiter that is already queued keeps
+// its position. The deadline ARGV[3] (milliseconds) is stored in KEYS[2], both keys expire with the last deadline.
+// ARGV[2] is the current time in milliseconds. It returns the zero based position of the owner.
+var enqueueScript = redis.NewScript(pruneQueueLua + `
+prune_queue(KEYS[1], KEYS[2], ARGV[2])
+redis.call("ZADD", KEYS[1], "NX", ARGV[2], ARGV[1])
+redis.call("ZADD", KEYS[2], "GT", ARGV[3], ARGV[1])
+local last = redis.call("ZRANGE", KEYS[2], -1, -1, "WITHSCORES")
+redis.call("PEXPIREAT", KEYS[1], last[2])
+redis.call("PEXPIREAT", KEYS[2], last[2])
+return redis.call("ZRANK", KEYS[1], ARGV[1])
+`)
+
+// dequeueScript removes the owner ARGV[1] from the wait queue KEYS[1] and its deadlines KEYS[2].
+var dequeueScript = redis.NewScript(`
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+return 0
+`)
+
+// queueScript returns the owners of the wait queue KEYS[1] (deadlines in KEYS[2]) in arrival order.
+// ARGV[1] is the current time in milliseconds.
+var queueScript = redis.NewScript(pruneQueueLua + `
+prune_queue(KEYS[1], KEYS[2], ARGV[1])
+return redis.call("ZRANGE", KEYS[1], 0, -1)
+`)
+
+// reclaimPermitsLua defines reclaim_permits(holders, permits, now), it removes all permits
+// whose TTL passed from the holders of a semaphore.
+const reclaimPermitsLua = `
+local function reclaim_permits(holders, permits, now)
+	local expired = redis.call("ZRANGEBYSCORE", holders, "-inf", now)
+	if #expired > 0 then
+		redis.call("ZREM", holders, unpack(expired))
+		redis.call("HDEL", permits, unpack(expired))
+	end
+end
+`
+
+// getSemaphoreScript returns the definition KEYS[1] of a semaphore followed by its unexpired permits,
+// holders are kept in the sorted set KEYS[2] scored by expiry and their permits in the hash KEYS[3].
+// It returns an empty list if the semaphore does not exist. ARGV[1] is the current time in milliseconds.
+var getSemaphoreScript = redis.NewScript(reclaimPermitsLua + `
+local definition = redis.call("GET", KEYS[1])
+if not definition then
+	return {}
+end
+reclaim_permits(KEYS[2], KEYS[3], ARGV[1])
+local result = {definition}
+for _, permit in ipairs(redis.call("HVALS", KEYS[3])) do
+	table.insert(result, permit)
+end
+return result
+`)
+
+// deleteSemaphoreScript removes the semaphore KEYS[1] if none of its permits are held.
+// It returns 1 if it was deleted, 0 while permits are held and -1 if it does not exist.
+// ARGV[1] is the current time in milliseconds.
+var deleteSemaphoreScript = redis.NewScript(reclaimPermitsLua + `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+reclaim_permits(KEYS[2], KEYS[3], ARGV[1])
+if redis.call("ZCARD", KEYS[2]) > 0 then
+	return 0
+end
+redis.call("DEL", KEYS[1], KEYS[2], KEYS[3])
+return 1
+`)
+
+// acquirePermitScript takes one permit of the semaphore KEYS[1] for the owner ARGV[1] until ARGV[4]
+// (milliseconds) and stores the permit ARGV[2], expired permits are reclaimed first.
+// It returns 1 if the permit was acquired, 0 if all permits are held, -1 if the semaphore does not exist
+// and -2 if the owner holds a permit already. ARGV[3] is the current time in milliseconds.
+var acquirePermitScript = redis.NewScript(reclaimPermitsLua + `
+local definition = redis.call("GET", KEYS[1])
+if not definition then
+	return -1
+end
+reclaim_permits(KEYS[2], KEYS[3], ARGV[3])
+if redis.call("ZSCORE", KEYS[2], ARGV[1]) then
+	return -2
+end
+if redis.call("ZCARD", KEYS[2]) >= cjson.decode(definition)["permits"] then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[4], ARGV[1])
+redis.call("HSET", KEYS[3], ARGV[1], ARGV[2])
+return 1
+`)
+
+// releasePermitScript returns the permit of the owner ARGV[1], expired permits are reclaimed first.
+// It returns 1 if the owner held a permit, otherwise 0. ARGV[2] is the current time in milliseconds.
+var releasePermitScript = redis.NewScript(reclaimPermitsLua + `
+reclaim_permits(KEYS[2], KEYS[3], ARGV[2])
+redis.call("HDEL", KEYS[3], ARGV[1])
+return redis.call("ZREM", KEYS[2], ARGV[1])
+`)
+
+// sessionHoldsLua defines session_holds(value, id, update), it reports whether the stored lock value
+// has a holder of the session id and calls update for every such holder.
+const sessionHoldsLua = `
+local function session_holds(value, id, update)
+	if not value then
+		return false
+	end
+	local holders = cjson.decode(value)
+	local held = false
+	for _, holder in ipairs(holders) do
+		if holder["sessionId"] == id then
+			held = true
+			if update then
+				update(holder)
+			end
+		end
+	end
+	return held, holders
+end
+`
+
+// releaseSessionLua defines release_session(index, id, prefix), it releases all locks of the index
+// held by the session id, a lock is deleted with its last holder. prefix is the key prefix of the locks,
+// see lock_keys. It returns the number of released locks.
+const releaseSessionLua = sessionHoldsLua + shadowLua + `
+local function release_session(index, id, prefix)
+	local released = 0
+	for _, key in ipairs(redis.call("SMEMBERS", index)) do
+		local held, holders = session_holds(redis.call("GET", key), id)
+		if held then
+			local remaining = {}
+			for _, holder in ipairs(holders) do
+				if holder["sessionId"] ~= id then
+					table.insert(remaining, holder)
+				end
+			end
+			if #remaining == 0 then
+				redis.call("DEL", key)
+			else
+				local value = cjson.encode(remaining)
+				redis.call("SET", key, value, "KEEPTTL")
+				local shadow, leases = lock_keys(key, prefix)
+				set_shadow(shadow, leases, value, redis.call("PTTL", key))
+			end
+			released = released + 1
+		end
+	end
+	return released
+end
+`
+
+// createSessionScript stores the session ARGV[2] in KEYS[1] with a TTL of ARGV[3] milliseconds
+// unless it exists and records its expiry ARGV[4] (milliseconds) for the id ARGV[1] in the sorted set
+// of session expiries KEYS[3]. It returns 1 if the session was created.
+var createSessionScript = redis.NewScript(`
+if not redis.call("SET", KEYS[1], ARGV[2], "NX", "PX", ARGV[3]) then
+	return 0
+end
+redis.call("ZADD", KEYS[3], ARGV[4], ARGV[1])
+return 1
+`)
+
+// getSessionScript returns the session KEYS[1] followed by the locks of its index KEYS[2]
+// that are still held by the session ARGV[1]. It returns an empty list if the session does not exist.
+var getSessionScript = redis.NewScript(sessionHoldsLua + `
+local session = redis.call("GET", KEYS[1])
+if not session then
+	return {}
+end
+local result = {session}
+for _, key in ipairs(redis.call("SMEMBERS", KEYS[2])) do
+	if session_holds(redis.call("GET", key), ARGV[1]) then
+		table.insert(result, key)
+	end
+end
+return result
+`)
+
+// renewSessionScript replaces the session KEYS[1] with ARGV[2] and a TTL of ARGV[3] milliseconds if it exists.
+// The locks in its index KEYS[2] held by the session ARGV[1] expire with it at ARGV[4] then,
+// locks the session does not hold anymore are dropped from the index. The locks and the index outlive
+// the session by shadowGrace and its expiry ARGV[6] (milliseconds) is recorded in KEYS[3],
+// see expireSessionScript. ARGV[5] is the key prefix of the locks, see lock_keys.
+// It returns 1 if the session exists.
+var renewSessionScript = redis.NewScript(sessionHoldsLua + shadowLua + `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local ttl = tonumber(ARGV[3])
+redis.call("SET", KEYS[1], ARGV[2], "PX", ttl)
+redis.call("ZADD", KEYS[3], ARGV[6], ARGV[1])
+local function extend(holder)
+	holder["expireAt"] = ARGV[4]
+end
+local lifetime = ttl + shadow_grace
+for _, key in ipairs(redis.call("SMEMBERS", KEYS[2])) do
+	local held, holders = session_holds(redis.call("GET", key), ARGV[1], extend)
+	if held then
+		local value, expiry = cjson.encode(holders), math.max(lifetime, redis.call("PTTL", key))
+		redis.call("SET", key, value, "PX", expiry)
+		local shadow, leases = lock_keys(key, ARGV[5])
+		set_shadow(shadow, leases, value, expiry)
+	else
+		redis.call("SREM", KEYS[2], key)
+	end
+end
+redis.call("PEXPIRE", KEYS[2], lifetime)
+return 1
+`)
+
+// deleteSessionScript removes the session KEYS[1] and releases all locks of its index KEYS[2]
+// held by the session ARGV[1] like release_session, its expiry is dropped from KEYS[3].
+// ARGV[2] is the key prefix of the locks, see lock_keys.
+// It returns the number of released locks or -1 if the session does not exist.
+var deleteSessionScript = redis.NewScript(releaseSessionLua + `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+local released = release_session(KEYS[2], ARGV[1], ARGV[2])
+redis.call("DEL", KEYS[1], KEYS[2])
+redis.call("ZREM", KEYS[3], ARGV[1])
+return released
+`)
+
+// expireSessionScript releases all locks of the index KEYS[2] held by the session ARGV[1] at once
+// like deleteSessionScript, once the session KEYS[1] expired. Its expiry is dropped from KEYS[3].
+// ARGV[2] is the key prefix of the locks, see lock_keys.
+// It returns the number of released locks or -1 if the session still exists.
+var expireSessionScript = redis.NewScript(releaseSessionLua + `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return -1
+end
+local released = release_session(KEYS[2], ARGV[1], ARGV[2])
+redis.call("DEL", KEYS[2])
+redis.call("ZREM", KEYS[3], ARGV[1])
+return released
+`)
@@ -0,0 +1,613 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+const (
+	// redlockClockDriftFactor is the share of the TTL reserved for the clock drift between the nodes
+	redlockClockDriftFactor = 0.01
+	// redlockClockDriftMin is reserved for the clock drift on top of the share of the TTL
+	redlockClockDriftMin = 2 * time.Millisecond
+	// redlockNodeTimeout limits every call to a single node unless the config sets redis.nodeTimeout,
+	// so an unavailable node can't use up the validity time
+	redlockNodeTimeout = 100 * time.Millisecond
+)
+
+// RedlockHandler keeps the locks on several independent Redis nodes following the Redlock algorithm.
+// A lock only counts as acquired if a majority of the nodes stored it within its validity time, which
+// is its TTL minus the time it took to acquire and the clock drift between the nodes. The nodes keep a lock
+// for its TTL plus the clock drift, so a lock whose expiry is reduced by the drift (see ClockDrift) is valid
+// until then. The fencing token is agreed on before the lock is written, so all nodes store the same value.
+// Only the locks, their fencing counters, wait queues and leases are kept on all nodes. Everything else,
+// f.e. semaphores, events, sessions and the history, is kept on the first node only, so that node remains
+// a single point of failure for them. Locks can't be bound to sessions.
+type RedlockHandler struct {
+	nodes  []*RedisHandler
+	quorum int
+	// timeout limits every call to a single node
+	timeout time.Duration
+	logger  domain.Logger
+}
+
+// NewRedlockHandler creates a RedlockHandler for the Redis nodes of the config.
+// It fails if the node timeout of the config is not a positive timestring.
+func NewRedlockHandler(config domain.Config, logger domain.Logger) (*RedlockHandler, error) {
+	timeout := redlockNodeTimeout
+	if config.Redis.NodeTimeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(config.Redis.NodeTimeout)
+		if err != nil || timeout <= 0 {
+			const msg = "NewRedlockHandler - invalid node timeout %q"
+			return nil, fmt.Errorf(msg, config.Redis.NodeTimeout)
+		}
+	}
+	nodes := make([]*RedisHandler, 0, len(config.Redis.Nodes))
+	for _, node := range config.Redis.Nodes {
+		nodeConfig := config
+		nodeConfig.Redis.Host, nodeConfig.Redis.Port = node.Host, node.Port
+		nodeConfig.Redis.Sentinels, nodeConfig.Redis.Cluster = nil, nil
+		handler, err := NewRedisHandler(nodeConfig, logger)
+		if err != nil {
+			const msg = "NewRedlockHandler - NewRedisHandler > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		nodes = append(nodes, handler)
+	}
+	return &RedlockHandler{nodes: nodes, quorum: len(nodes)/2 + 1, timeout: timeout, logger: logger}, nil
+}
+
+// Node returns the handler of the i-th node.
+func (h *RedlockHandler) Node(i int) *RedisHandler {
+	return h.nodes[i]
+}
+
+// EnableKeyspaceNotifications enables the keyspace notifications on every node.
+func (h *RedlockHandler) EnableKeyspaceNotifications(ctx context.Context) error {
+	for _, node := range h.nodes {
+		if err := node.EnableKeyspaceNotifications(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *RedlockHandler) Close() error {
+	var result error
+	for _, node := range h.nodes {
+		if err := node.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// each calls op for all nodes concurrently, each call is limited to the node timeout.
+// It returns the errors of the nodes in order.
+func (h *RedlockHandler) each(ctx context.Context, op func(ctx context.Context, i int, node *RedisHandler) error) []error {
+	errs := make([]error, len(h.nodes))
+	var wg sync.WaitGroup
+	for i, node := range h.nodes {
+		wg.Add(1)
+		go func(i int, node *RedisHandler) {
+			defer wg.Done()
+			nodeCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+			errs[i] = op(nodeCtx, i, node)
+		}(i, node)
+	}
+	wg.Wait()
+	return errs
+}
+
+// quorumError returns an error if less than a quorum of the nodes answered.
+func (h *RedlockHandler) quorumError(method string, errs []error) error {
+	const msg = "RedlockHandler.%s - %d of %d nodes failed > %w"
+	failed := 0
+	var first error
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if len(h.nodes)-failed < h.quorum {
+		return fmt.Errorf(msg, method, failed, len(h.nodes), first)
+	}
+	if first != nil {
+		const msg = "RedlockHandler.%s - %d of %d nodes failed > %s"
+		h.logger.Warn(fmt.Sprintf(msg, method, failed, len(h.nodes), first))
+	}
+	return nil
+}
+
+// ClockDrift returns the time reserved for the clock drift between the nodes of a lock with the TTL.
+// The nodes keep the lock for its TTL plus the drift, so it is valid for its TTL once acquired.
+func (h *RedlockHandler) ClockDrift(ttl time.Duration) time.Duration {
+	return time.Duration(float64(ttl)*redlockClockDriftFactor) + redlockClockDriftMin
+}
+
+// valid reports whether a lock that the nodes keep for ttl and that was written since start is still valid
+// after deducting the clock drift between the nodes.
+func (h *RedlockHandler) valid(start time.Time, ttl time.Duration) bool {
+	return ttl-time.Since(start)-h.ClockDrift(ttl) > 0
+}
+
+// fence returns the next fencing token of the key, one above the highest counter of a quorum of nodes.
+// The counters of a quorum are raised right below it, so the acquiring nodes increment them to the token
+// and any later quorum returns a higher one.
+func (h *RedlockHandler) fence(ctx context.Context, key string) (int64, error) {
+	counters := make([]int64, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		counters[i], err = node.fence(ctx, key)
+		return err
+	})
+	if err := h.quorumError("fence", errs); err != nil {
+		return 0, err
+	}
+	var highest int64
+	for _, counter := range counters {
+		if counter > highest {
+			highest = counter
+		}
+	}
+	errs = h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		if counters[i] == highest {
+			return nil
+		}
+		return node.raiseFence(ctx, key, highest)
+	})
+	if err := h.quorumError("fence", errs); err != nil {
+		return 0, err
+	}
+	return highest + 1, nil
+}
+
+// withFencingToken assigns the token to every holder of the value without a fencing token.
+func withFencingToken(value string, token int64) (string, error) {
+	var holders []*domain.Lock
+	if err := json.Unmarshal([]byte(value), &holders); err != nil {
+		return "", fmt.Errorf("RedlockHandler.withFencingToken - json.Unmarshal > %w", err)
+	}
+	for _, holder := range holders {
+		if holder.FencingToken == 0 {
+			holder.FencingToken = token
+		}
+	}
+	result, err := json.Marshal(holders)
+	if err != nil {
+		return "", fmt.Errorf("RedlockHandler.withFencingToken - json.Marshal > %w", err)
+	}
+	return string(result), nil
+}
+
+// rollback restores expected on the nodes that stored value, an empty expected value deletes the key.
+func (h *RedlockHandler) rollback(ctx context.Context, stored []bool, key string, expected string, value string, ttl time.Duration) {
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		if !stored[i] {
+			return nil
+		}
+		var err error
+		if expected == "" {
+			_, err = node.CompareAndDelete(ctx, key, value)
+		} else {
+			_, err = node.CompareAndSwap(ctx, key, value, expected, ttl)
+		}
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			const msg = "RedlockHandler.rollback - node %d keeps %s until it expires > %s"
+			h.logger.Warn(fmt.Sprintf(msg, i, key, err))
+		}
+	}
+}
+
+// refusal returns the most common result in counts, -1 if there is none.
+func refusal(counts map[int64]int) int64 {
+	refused, most := int64(-1), 0
+	for result, count := range counts {
+		if count > most || (count == most && result < refused) {
+			refused, most = result, count
+		}
+	}
+	return refused
+}
+
+func (h *RedlockHandler) Acquire(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (int64, error) {
+	return h.acquire(ctx, "Acquire", key, expected, value, ttl, func(ctx context.Context, node *RedisHandler, value string, ttl time.Duration) (int64, error) {
+		return node.Acquire(ctx, key, owner, expected, value, ttl)
+	})
+}
+
+func (h *RedlockHandler) Takeover(ctx context.Context, key string, expected string, value string, ttl time.Duration) (int64, error) {
+	return h.acquire(ctx, "Takeover", key, expected, value, ttl, func(ctx context.Context, node *RedisHandler, value string, ttl time.Duration) (int64, error) {
+		return node.Takeover(ctx, key, expected, value, ttl)
+	})
+}
+
+// acquire writes the value with the next fencing token via op on all nodes, they keep it for ttl plus
+// the clock drift. It returns the token if a quorum of nodes stored it within its validity time, otherwise
+// it is rolled back on all nodes and the most common refusal of the nodes is returned.
+func (h *RedlockHandler) acquire(ctx context.Context, method string, key string, expected string, value string, ttl time.Duration, op func(ctx context.Context, node *RedisHandler, value string, ttl time.Duration) (int64, error)) (int64, error) {
+	start := time.Now()
+	ttl += h.ClockDrift(ttl)
+	token, err := h.fence(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	value, err = withFencingToken(value, token)
+	if err != nil {
+		return 0, err
+	}
+	results := make([]int64, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		results[i], err = op(ctx, node, value, ttl)
+		return err
+	})
+	stored := make([]bool, len(h.nodes))
+	acquired, refused := 0, map[int64]int{}
+	for i, result := range results {
+		switch {
+		case errs[i] != nil:
+		case result > 0:
+			stored[i] = true
+			acquired++
+		default:
+			refused[result]++
+		}
+	}
+	if acquired >= h.quorum && h.valid(start, ttl) {
+		return token, nil
+	}
+	h.rollback(ctx, stored, key, expected, value, ttl)
+	if err := h.quorumError(method, errs); err != nil {
+		return 0, err
+	}
+	return refusal(refused), nil
+}
+
+func (h *RedlockHandler) AcquireMultiple(ctx context.Context, owner string, keys []string, expected []string, values []string, ttls []time.Duration) ([]int64, error) {
+	start := time.Now()
+	kept := make([]time.Duration, len(ttls))
+	for k, ttl := range ttls {
+		kept[k] = ttl + h.ClockDrift(ttl)
+	}
+	ttls = kept
+	tokens := make([]int64, len(keys))
+	fenced := make([]string, len(keys))
+	for k, key := range keys {
+		token, err := h.fence(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if fenced[k], err = withFencingToken(values[k], token); err != nil {
+			return nil, err
+		}
+		tokens[k] = token
+	}
+	results := make([][]int64, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		results[i], err = node.AcquireMultiple(ctx, owner, keys, expected, fenced, ttls)
+		return err
+	})
+	stored := make([]bool, len(h.nodes))
+	acquired := 0
+	for i := range h.nodes {
+		if errs[i] != nil {
+			continue
+		}
+		stored[i] = true
+		for _, result := range results[i] {
+			if result <= 0 {
+				stored[i] = false
+			}
+		}
+		if stored[i] {
+			acquired++
+		}
+	}
+	shortest := ttls[0]
+	for _, ttl := range ttls {
+		if ttl < shortest {
+			shortest = ttl
+		}
+	}
+	if acquired >= h.quorum && h.valid(start, shortest) {
+		return tokens, nil
+	}
+	for k, key := range keys {
+		h.rollback(ctx, stored, key, expected[k], fenced[k], ttls[k])
+	}
+	if err := h.quorumError("AcquireMultiple", errs); err != nil {
+		return nil, err
+	}
+	// report per key the most common result of the nodes that refused the locks
+	refused := make([]int64, len(keys))
+	for k := range keys {
+		counts := map[int64]int{}
+		for i := range h.nodes {
+			if errs[i] == nil && !stored[i] {
+				counts[results[i][k]]++
+			}
+		}
+		refused[k] = refusal(counts)
+	}
+	return refused, nil
+}
+
+func (h *RedlockHandler) CompareAndDelete(ctx context.Context, key string, expected string) (bool, error) {
+	deleted := make([]bool, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		deleted[i], err = node.CompareAndDelete(ctx, key, expected)
+		return err
+	})
+	if err := h.quorumError("CompareAndDelete", errs); err != nil {
+		return false, err
+	}
+	count := 0
+	for _, ok := range deleted {
+		if ok {
+			count++
+		}
+	}
+	return count >= h.quorum, nil
+}
+
+// CompareAndSwap replaces the lock on all nodes like Acquire, they keep it for ttl plus the clock drift.
+func (h *RedlockHandler) CompareAndSwap(ctx context.Context, key string, expected string, value string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ttl += h.ClockDrift(ttl)
+	swapped := make([]bool, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		swapped[i], err = node.CompareAndSwap(ctx, key, expected, value, ttl)
+		return err
+	})
+	count := 0
+	for _, ok := range swapped {
+		if ok {
+			count++
+		}
+	}
+	if count >= h.quorum && h.valid(start, ttl) {
+		return true, nil
+	}
+	h.rollback(ctx, swapped, key, expected, value, ttl)
+	if err := h.quorumError("CompareAndSwap", errs); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// GetLease returns the lease of the owner with the earliest expiry a quorum of nodes agree on, as the nodes
+// end the leases of a revoked lock at slightly different times. It is empty if less than a quorum of nodes
+// know the lease.
+func (h *RedlockHandler) GetLease(ctx context.Context, key string, owner string) (string, error) {
+	values := make([]string, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		values[i], err = node.GetLease(ctx, key, owner)
+		return err
+	})
+	if err := h.quorumError("GetLease", errs); err != nil {
+		return "", err
+	}
+	type lease struct {
+		value    string
+		expireAt time.Time
+	}
+	var leases []lease
+	for i, value := range values {
+		var holder domain.Lock
+		if errs[i] != nil || value == "" || json.Unmarshal([]byte(value), &holder) != nil {
+			continue
+		}
+		leases = append(leases, lease{value: value, expireAt: holder.ExpireAt})
+	}
+	if len(leases) < h.quorum {
+		return "", nil
+	}
+	sort.Slice(leases, func(a, b int) bool { return leases[a].expireAt.Before(leases[b].expireAt) })
+	return leases[h.quorum-1].value, nil
+}
+
+// CompareAndRelease replaces the lock on all nodes like CompareAndSwap, or removes it like CompareAndDelete
+// if value is empty, and removes the lease of owner on every node at once.
+func (h *RedlockHandler) CompareAndRelease(ctx context.Context, key string, owner string, expected string, value string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	if value != "" {
+		ttl += h.ClockDrift(ttl)
+	}
+	released := make([]bool, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		released[i], err = node.CompareAndRelease(ctx, key, owner, expected, value, ttl)
+		return err
+	})
+	count := 0
+	for _, ok := range released {
+		if ok {
+			count++
+		}
+	}
+	if count >= h.quorum && (value == "" || h.valid(start, ttl)) {
+		return true, nil
+	}
+	if value != "" {
+		h.rollback(ctx, released, key, expected, value, ttl)
+	}
+	if err := h.quorumError("CompareAndRelease", errs); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// CompareAndRevoke removes the lock on all nodes like CompareAndDelete and ends the leases of its holders
+// on every node at once.
+func (h *RedlockHandler) CompareAndRevoke(ctx context.Context, key string, expected string) (bool, error) {
+	revoked := make([]bool, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		revoked[i], err = node.CompareAndRevoke(ctx, key, expected)
+		return err
+	})
+	if err := h.quorumError("CompareAndRevoke", errs); err != nil {
+		return false, err
+	}
+	count := 0
+	for _, ok := range revoked {
+		if ok {
+			count++
+		}
+	}
+	return count >= h.quorum, nil
+}
+
+// Get returns the values a quorum of nodes agree on, a lock that is only stored on a minority
+// of the nodes is not held.
+func (h *RedlockHandler) Get(ctx context.Context, key string) ([]string, error) {
+	values := make([][]string, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		values[i], err = node.Get(ctx, key)
+		return err
+	})
+	if err := h.quorumError("Get", errs); err != nil {
+		return nil, err
+	}
+	var agreed []string
+	votes := map[string]int{}
+	for i := range h.nodes {
+		for _, value := range values[i] {
+			votes[value]++
+			if votes[value] == h.quorum {
+				agreed = append(agreed, value)
+			}
+		}
+	}
+	if key == "*" && agreed == nil {
+		return []string{}, nil
+	}
+	return agreed, nil
+}
+
+func (h *RedlockHandler) Del(ctx context.Context, key string) error {
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		return node.Del(ctx, key)
+	})
+	return h.quorumError("Del", errs)
+}
+
+func (h *RedlockHandler) Count(ctx context.Context) (int, error) {
+	values, err := h.Get(ctx, "*")
+	if err != nil {
+		return 0, err
+	}
+	return len(values), nil
+}
+
+// Watch signals whenever any node signals a change of the key.
+func (h *RedlockHandler) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	changes := make(chan struct{}, 1)
+	var errs []error
+	for _, node := range h.nodes {
+		nodeChanges, err := node.Watch(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-nodeChanges:
+					select {
+					case changes <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+	if len(errs) == len(h.nodes) {
+		return nil, fmt.Errorf("RedlockHandler.Watch - no node can be watched > %w", errs[0])
+	}
+	return changes, nil
+}
+
+// Enqueue queues the owner on all nodes and returns its last position.
+func (h *RedlockHandler) Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error) {
+	positions := make([]int, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		positions[i], err = node.Enqueue(ctx, key, owner, deadline)
+		return err
+	})
+	if err := h.quorumError("Enqueue", errs); err != nil {
+		return 0, err
+	}
+	position := 0
+	for i, p := range positions {
+		if errs[i] == nil && p > position {
+			position = p
+		}
+	}
+	return position, nil
+}
+
+func (h *RedlockHandler) Dequeue(ctx context.Context, key string, owner string) error {
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		return node.Dequeue(ctx, key, owner)
+	})
+	return h.quorumError("Dequeue", errs)
+}
+
+// Queue returns the wait queue most nodes agree on.
+func (h *RedlockHandler) Queue(ctx context.Context, key string) ([]string, error) {
+	queues := make([][]string, len(h.nodes))
+	errs := h.each(ctx, func(ctx context.Context, i int, node *RedisHandler) error {
+		var err error
+		queues[i], err = node.Queue(ctx, key)
+		return err
+	})
+	if err := h.quorumError("Queue", errs); err != nil {
+		return nil, err
+	}
+	votes := map[string]int{}
+	for i, queue := range queues {
+		if errs[i] == nil {
+			votes[strings.Join(queue, "\n")]++
+		}
+	}
+	candidates := make([]string, 0, len(votes))
+	for queue := range votes {
+		candidates = append(candidates, queue)
+	}
+	// prefer the longest queue on a tie, so no waiter is hidden
+	sort.Slice(candidates, func(a, b int) bool {
+		if votes[candidates[a]] != votes[candidates[b]] {
+			return votes[candidates[a]] > votes[candidates[b]]
+		}
+		return len(candidates[a]) > len(candidates[b])
+	})
+	if candidates[0] == "" {
+		return []string{}, nil
+	}
+	return strings.Split(candidates[0], "\n"), nil
+}
@@ -0,0 +1,196 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+const testRedlockTTL = 10 * time.Second
+
+// newTestRedlockHandler starts n in-process Redis stand-ins and returns a RedlockHandler across them.
+func newTestRedlockHandler(t *testing.T, n int) (*RedlockHandler, []*miniredis.Miniredis) {
+	var config domain.Config
+	config.Redis.Prefix = "test."
+	servers := make([]*miniredis.Miniredis, n)
+	for i := range servers {
+		servers[i] = miniredis.RunT(t)
+		config.Redis.Nodes = append(config.Redis.Nodes, domain.RedisNode{Host: servers[i].Host(), Port: servers[i].Port()})
+	}
+	handler, err := NewRedlockHandler(config, NewMockLogger())
+	assert.NoError(t, err)
+	t.Cleanup(func() { handler.Close() })
+	return handler, servers
+}
+
+func testRedlockValue(t *testing.T, owner string) string {
+	value, err := json.Marshal([]*domain.Lock{{Key: "deploy", Owner: owner, Mode: domain.LockModeExclusive, HoldCount: 1}})
+	assert.NoError(t, err)
+	return string(value)
+}
+
+func TestRedlockAcquire(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+
+	// Act
+	token, err := handler.Acquire(context.Background(), "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), token)
+	values, err := handler.Get(context.Background(), "deploy")
+	assert.NoError(t, err)
+	assert.Len(t, values, 1)
+	assert.Contains(t, values[0], `"fencingToken":1`)
+	for _, server := range servers {
+		stored, err := server.Get("test.lock:deploy")
+		assert.NoError(t, err)
+		assert.Equal(t, values[0], stored)
+	}
+}
+
+func TestRedlockAcquireWithNodeDown(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+	servers[2].Close()
+
+	// Act
+	token, err := handler.Acquire(context.Background(), "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), token)
+	assert.True(t, servers[0].Exists("test.lock:deploy"))
+	assert.True(t, servers[1].Exists("test.lock:deploy"))
+}
+
+func TestRedlockAcquireWithoutQuorum(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+	servers[1].Close()
+	servers[2].Close()
+
+	// Act
+	_, err := handler.Acquire(context.Background(), "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, servers[0].Exists("test.lock:deploy"))
+}
+
+func TestRedlockAcquireRollsBackMinority(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+	held := testRedlockValue(t, "other")
+	servers[0].Set("test.lock:deploy", held)
+	servers[1].Set("test.lock:deploy", held)
+
+	// Act
+	token, err := handler.Acquire(context.Background(), "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), token)
+	assert.False(t, servers[2].Exists("test.lock:deploy"))
+}
+
+func TestRedlockFencingTokensIncrease(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+	servers[1].Set("test.fence:deploy", "41")
+	ctx := context.Background()
+
+	// Act
+	first, err := handler.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+	assert.NoError(t, err)
+	values, err := handler.Get(ctx, "deploy")
+	assert.NoError(t, err)
+	released, err := handler.CompareAndDelete(ctx, "deploy", values[0])
+	assert.NoError(t, err)
+	second, err := handler.Acquire(ctx, "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, released)
+	assert.Equal(t, int64(42), first)
+	assert.Equal(t, int64(43), second)
+	for _, server := range servers {
+		fence, err := server.Get("test.fence:deploy")
+		assert.NoError(t, err)
+		assert.Equal(t, "43", fence)
+	}
+}
+
+func TestRedlockGetIgnoresMinority(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+	servers[0].Set("test.lock:deploy", testRedlockValue(t, "other"))
+
+	// Act
+	values, err := handler.Get(context.Background(), "deploy")
+	count, countErr := handler.Count(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, countErr)
+	assert.Nil(t, values)
+	assert.Equal(t, 0, count)
+}
+
+func TestRedlockKeepsLockForClockDrift(t *testing.T) {
+	// Arrange
+	handler, servers := newTestRedlockHandler(t, 3)
+
+	// Act
+	_, err := handler.Acquire(context.Background(), "deploy", "ci", "", testRedlockValue(t, "ci"), testRedlockTTL)
+
+	// Assert
+	assert.NoError(t, err)
+	for _, server := range servers {
+		assert.Equal(t, testRedlockTTL+handler.ClockDrift(testRedlockTTL), server.TTL("test.lock:deploy"))
+	}
+}
+
+func TestRedlockRevokeEndsLeases(t *testing.T) {
+	// Arrange
+	handler, _ := newTestRedlockHandler(t, 3)
+	ctx := context.Background()
+	value, err := json.Marshal([]*domain.Lock{{Key: "deploy", Owner: "ci", Mode: domain.LockModeExclusive, HoldCount: 1, ExpireAt: time.Now().Add(time.Minute)}})
+	assert.NoError(t, err)
+	_, err = handler.Acquire(ctx, "deploy", "ci", "", string(value), time.Minute)
+	assert.NoError(t, err)
+	current, err := handler.Get(ctx, "deploy")
+	assert.NoError(t, err)
+
+	// Act
+	revoked, err := handler.CompareAndRevoke(ctx, "deploy", current[0])
+	lease, leaseErr := handler.GetLease(ctx, "deploy", "ci")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, leaseErr)
+	assert.True(t, revoked)
+	var ended domain.Lock
+	assert.NoError(t, json.Unmarshal([]byte(lease), &ended))
+	assert.False(t, ended.ExpireAt.After(time.Now()))
+}
+
+func TestNewRedlockHandlerInvalidNodeTimeout(t *testing.T) {
+	// Arrange
+	var config domain.Config
+	config.Redis.NodeTimeout = "soon"
+
+	// Act
+	handler, err := NewRedlockHandler(config, NewMockLogger())
+
+	// Assert
+	assert.Nil(t, handler)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// webhookTimeout is the time a receiver has to answer a delivery.
+const webhookTimeout = 10 * time.Second
+
+// HTTPWebhookClient sends webhook deliveries over HTTP.
+type HTTPWebhookClient struct {
+	client *http.Client
+}
+
+var _ domain.WebhookClient = (*HTTPWebhookClient)(nil)
+
+// NewHTTPWebhookClient creates a new HTTPWebhookClient, it does not follow redirects.
+func NewHTTPWebhookClient() *HTTPWebhookClient {
+	return &HTTPWebhookClient{
+		client: &http.Client{
+			Timeout: webhookTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Post sends body as JSON to url with the given headers and returns the response status.
+func (c *HTTPWebhookClient) Post(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("HTTPWebhookClient.Post - http.NewRequestWithContext > %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("HTTPWebhookClient.Post - client.Do > %w", err)
+	}
+	defer res.Body.Close()
+	// drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	return res.StatusCode, nil
+}
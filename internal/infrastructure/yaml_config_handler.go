@@ -3,6 +3,7 @@ package infrastructure
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/tyriis/go-locking-service/internal/domain"
 	"gopkg.in/yaml.v3"
@@ -57,5 +58,35 @@ func (h *YAMLConfigHandler) Load() (*domain.Config, error) {
 		return nil, err
 	}
 
+	if err := h.resolveSecrets(config); err != nil {
+		const msg = "YAMLConfigHandler.Load - h.resolveSecrets > %w"
+		h.logger.Error(fmt.Errorf(msg, err).Error())
+		return nil, err
+	}
+
 	return config, nil
 }
+
+// resolveSecrets reads the secrets of the config that are given by env var or file into their values.
+func (h *YAMLConfigHandler) resolveSecrets(config *domain.Config) error {
+	for _, secret := range config.Secrets() {
+		switch {
+		case secret.FromEnv != "":
+			value, ok := os.LookupEnv(secret.FromEnv)
+			if !ok {
+				const msg = "YAMLConfigHandler.resolveSecrets - env var %s is not set"
+				return fmt.Errorf(msg, secret.FromEnv)
+			}
+			secret.Value = value
+		case secret.FromFile != "":
+			data, err := os.ReadFile(secret.FromFile)
+			if err != nil {
+				const msg = "YAMLConfigHandler.resolveSecrets - os.ReadFile > %w"
+				return fmt.Errorf(msg, err)
+			}
+			// secret files usually end with a line break that is not part of the secret
+			secret.Value = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package infrastructure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfigSchema = "assets/schemas/config.json"
+
+// writeTestConfig writes the YAML config to a temporary file and returns its path.
+func writeTestConfig(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	return path
+}
+
+func TestLoadResolvesSecrets(t *testing.T) {
+	// Arrange
+	t.Setenv("TEST_REDIS_PASSWORD", "from-env")
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+	logger := NewLogger()
+	validator := NewJSONSchemaValidator(testConfigSchema, logger)
+	fromEnv := writeTestConfig(t, `
+api: {host: localhost, port: 3000}
+redis:
+  host: localhost
+  port: 6379
+  keyPrefix: test.
+  username: locking-service
+  password: {fromEnv: TEST_REDIS_PASSWORD}
+  db: 2
+`)
+	fromFile := writeTestConfig(t, `
+api: {host: localhost, port: 3000}
+redis:
+  host: localhost
+  port: 6379
+  keyPrefix: test.
+  password: {fromFile: `+passwordFile+`}
+`)
+
+	// Act
+	envConfig, envErr := NewYAMLConfigHandler(fromEnv, validator, logger).Load()
+	fileConfig, fileErr := NewYAMLConfigHandler(fromFile, validator, logger).Load()
+
+	// Assert
+	assert.NoError(t, envErr)
+	assert.NoError(t, fileErr)
+	assert.Equal(t, "locking-service", envConfig.Redis.Username)
+	assert.Equal(t, "from-env", envConfig.Redis.Password.Value)
+	assert.Equal(t, 2, envConfig.Redis.DB)
+	assert.Equal(t, "from-file", fileConfig.Redis.Password.Value)
+}
+
+func TestLoadResolvesAdminAndWebhookSecrets(t *testing.T) {
+	// Arrange
+	t.Setenv("TEST_ADMIN_TOKEN", "admin-from-env")
+	t.Setenv("TEST_SENTINEL_PASSWORD", "sentinel-from-env")
+	secretFile := filepath.Join(t.TempDir(), "webhook-secret")
+	assert.NoError(t, os.WriteFile(secretFile, []byte("webhook-from-file\n"), 0o600))
+	logger := NewLogger()
+	validator := NewJSONSchemaValidator(testConfigSchema, logger)
+	path := writeTestConfig(t, `
+api: {host: localhost, port: 3000}
+redis:
+  sentinels: [{host: localhost, port: 26379}]
+  name: redis-master
+  keyPrefix: test.
+  sentinelUsername: sentinel
+  sentinelPassword: {fromEnv: TEST_SENTINEL_PASSWORD}
+admin:
+  token: plain-token
+  tokens:
+    - name: ops
+      token: {fromEnv: TEST_ADMIN_TOKEN}
+webhooks:
+  - name: chatops
+    url: https://chatops.example.com/locks
+    keyPattern: deploy/*
+    secret: {fromFile: `+secretFile+`}
+`)
+
+	// Act
+	config, err := NewYAMLConfigHandler(path, validator, logger).Load()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "sentinel", config.Redis.SentinelUsername)
+	assert.Equal(t, "sentinel-from-env", config.Redis.SentinelPassword.Value)
+	assert.Equal(t, "plain-token", config.Admin.Token.Value)
+	assert.Equal(t, "admin-from-env", config.Admin.Tokens[0].Token.Value)
+	assert.Equal(t, "webhook-from-file", config.Webhooks[0].Secret.Value)
+}
+
+func TestLoadRejectsInvalidRedisConfig(t *testing.T) {
+	// Arrange
+	logger := NewLogger()
+	validator := NewJSONSchemaValidator(testConfigSchema, logger)
+	configs := map[string]string{
+		"client certificate without key": `
+api: {host: localhost, port: 3000}
+redis:
+  host: localhost
+  port: 6379
+  keyPrefix: test.
+  tls: {enabled: true, certFile: /etc/redis/client.crt}
+`,
+		"database in cluster": `
+api: {host: localhost, port: 3000}
+redis:
+  keyPrefix: test.
+  cluster: [{host: localhost, port: 6379}]
+  db: 1
+`,
+		"unset env var": `
+api: {host: localhost, port: 3000}
+redis:
+  host: localhost
+  port: 6379
+  keyPrefix: test.
+  password: {fromEnv: TEST_REDIS_PASSWORD_UNSET}
+`,
+	}
+
+	for name, config := range configs {
+		// Act
+		_, err := NewYAMLConfigHandler(writeTestConfig(t, config), validator, logger).Load()
+
+		// Assert
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLoadMemoryBackendWithoutRedis(t *testing.T) {
+	// Arrange
+	logger := NewLogger()
+	validator := NewJSONSchemaValidator(testConfigSchema, logger)
+	memory := writeTestConfig(t, `
+backend: memory
+api: {host: localhost, port: 3000}
+`)
+	redis := writeTestConfig(t, `
+backend: redis
+api: {host: localhost, port: 3000}
+`)
+
+	// Act
+	config, err := NewYAMLConfigHandler(memory, validator, logger).Load()
+	_, redisErr := NewYAMLConfigHandler(redis, validator, logger).Load()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "memory", config.Backend)
+	assert.Error(t, redisErr)
+}
+
+func TestLoadFileBackendNeedsDataDir(t *testing.T) {
+	// Arrange
+	logger := NewLogger()
+	validator := NewJSONSchemaValidator(testConfigSchema, logger)
+	withDataDir := writeTestConfig(t, `
+backend: file
+dataDir: /var/lib/locking-service
+api: {host: localhost, port: 3000}
+`)
+	withoutDataDir := writeTestConfig(t, `
+backend: file
+api: {host: localhost, port: 3000}
+`)
+
+	// Act
+	config, err := NewYAMLConfigHandler(withDataDir, validator, logger).Load()
+	_, missingErr := NewYAMLConfigHandler(withoutDataDir, validator, logger).Load()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/lib/locking-service", config.DataDir)
+	assert.Error(t, missingErr)
+}
@@ -40,3 +40,15 @@ func (crw *customResponseWriter) WriteHeader(code int) {
 	crw.statusCode = code
 	crw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap returns the wrapped writer, so a http.ResponseController reaches it.
+func (crw *customResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
+
+// Flush passes flushes on to the wrapped writer, so streaming responses keep working.
+func (crw *customResponseWriter) Flush() {
+	if flusher, ok := crw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
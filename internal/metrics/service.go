@@ -10,10 +10,13 @@ import (
 
 // Add this function at the top level
 func normalizePath(path string) string {
-	// Replace lock key pattern with a placeholder
-	re := regexp.MustCompile(`/api/v\d+/locks/[^/]+`)
+	// Replace the namespace of namespaced routes with a placeholder, unknown namespaces must not add label values
+	ns := regexp.MustCompile(`/api/v\d+/namespaces/[^/]+`)
+	path = ns.ReplaceAllString(path, "/api/v1/namespaces/:namespace")
+	// Replace lock, semaphore and session key patterns with a placeholder, keep sub resources like /queue
+	re := regexp.MustCompile(`/(locks|semaphores|sessions|webhooks)/[^/]+`)
 	if re.MatchString(path) {
-		return "/api/v1/locks/:key"
+		return re.ReplaceAllString(path, "/$1/:key")
 	}
 	return path
 }
@@ -23,7 +26,9 @@ type PrometheusMetricsService struct {
 	httpRequestCounter  *prometheus.CounterVec
 	userActionCounter   *prometheus.CounterVec
 	errorCounter        *prometheus.CounterVec
-	locksCounter        prometheus.Gauge
+	locksCounter        *prometheus.GaugeVec
+	expiredLocksCounter *prometheus.CounterVec
+	failoversCounter    prometheus.Counter
 }
 
 func NewPrometheusMetricsService() *PrometheusMetricsService {
@@ -39,9 +44,19 @@ func NewPrometheusMetricsService() *PrometheusMetricsService {
 			Help: "Total number of HTTP requests",
 		}, []string{"method", "path", "status"}),
 
-		locksCounter: promauto.NewGauge(prometheus.GaugeOpts{
+		locksCounter: promauto.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "locks_total",
 			Help: "The total number of active locks",
+		}, []string{"namespace"}),
+
+		expiredLocksCounter: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "locks_expired_total",
+			Help: "Total number of locks that expired without being released",
+		}, []string{"namespace"}),
+
+		failoversCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "redis_failovers_total",
+			Help: "Total number of Redis master failovers observed through the sentinels",
 		}),
 	}
 }
@@ -61,6 +76,14 @@ func (m *PrometheusMetricsService) IncrementErrorCount(errorType string) {
 	m.errorCounter.WithLabelValues(errorType).Inc()
 }
 
-func (m *PrometheusMetricsService) SetLockCount(value float64) {
-	m.locksCounter.Set(value)
+func (m *PrometheusMetricsService) SetLockCount(namespace string, value float64) {
+	m.locksCounter.WithLabelValues(namespace).Set(value)
+}
+
+func (m *PrometheusMetricsService) IncrementExpiredLocks(namespace string) {
+	m.expiredLocksCounter.WithLabelValues(namespace).Inc()
+}
+
+func (m *PrometheusMetricsService) IncrementRedisFailovers() {
+	m.failoversCounter.Inc()
 }
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 type MetricsUpdater struct {
 	lockRepo       *repositories.LockRepository
 	metricsService *PrometheusMetricsService
+	namespaces     []*domain.Namespace
 	logger         domain.Logger
 	quit           chan struct{}
 	updateInterval time.Duration
@@ -19,11 +21,13 @@ type MetricsUpdater struct {
 func NewMetricsUpdater(
 	lockRepo *repositories.LockRepository,
 	metricsService *PrometheusMetricsService,
+	namespaces []*domain.Namespace,
 	logger domain.Logger,
 ) *MetricsUpdater {
 	return &MetricsUpdater{
 		lockRepo:       lockRepo,
 		metricsService: metricsService,
+		namespaces:     namespaces,
 		logger:         logger,
 		quit:           make(chan struct{}),
 		updateInterval: 10 * time.Second,
@@ -38,13 +42,7 @@ func (m *MetricsUpdater) Start() {
 		for {
 			select {
 			case <-ticker.C:
-				count, err := m.lockRepo.Count()
-				if err != nil {
-					m.logger.Error("MetricsUpdater.Start > Failed to get locks count for metrics")
-					continue
-				}
-				m.metricsService.SetLockCount(float64(count))
-				m.logger.Debug("MetricsUpdater.Start > set lock count to " + fmt.Sprintf("%d", count))
+				m.update()
 			case <-m.quit:
 				return
 			}
@@ -52,6 +50,19 @@ func (m *MetricsUpdater) Start() {
 	}()
 }
 
+// update sets the lock gauge of every namespace.
+func (m *MetricsUpdater) update() {
+	for _, ns := range m.namespaces {
+		count, err := m.lockRepo.Count(domain.WithNamespace(context.Background(), ns))
+		if err != nil {
+			m.logger.Error("MetricsUpdater.Start > Failed to get locks count for metrics of namespace " + ns.Name)
+			continue
+		}
+		m.metricsService.SetLockCount(ns.Name, float64(count))
+		m.logger.Debug("MetricsUpdater.Start > set lock count of namespace " + ns.Name + " to " + fmt.Sprintf("%d", count))
+	}
+}
+
 func (m *MetricsUpdater) Stop() {
 	close(m.quit)
 }
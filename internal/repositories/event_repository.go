@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// EventStoreHandler is implemented by stores that can publish lock events.
+type EventStoreHandler interface {
+	// PublishEvent publishes the event value to the observers of the lock.
+	PublishEvent(ctx context.Context, key string, value string) error
+	// SubscribeEvents delivers the published event values and the keys of the expired locks
+	// with the key prefix until ctx is done, both channels are closed then.
+	SubscribeEvents(ctx context.Context, keyPrefix string) (<-chan string, <-chan string, error)
+	// Get returns the value of the lock, it is nil if the lock is not held.
+	Get(ctx context.Context, key string) ([]string, error)
+	// GetLockShadow returns the last value of the lock until shortly after it expired, it is empty if there is none.
+	GetLockShadow(ctx context.Context, key string) (string, error)
+	// ClaimEvent reports whether the event id was not claimed within ttl before.
+	ClaimEvent(ctx context.Context, id string, ttl time.Duration) (bool, error)
+}
+
+type EventRepository struct {
+	handler EventStoreHandler
+	logger  domain.Logger
+}
+
+func NewEventRepository(handler EventStoreHandler, logger domain.Logger) *EventRepository {
+	return &EventRepository{
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// Publish sends the event to the observers of its lock.
+func (repo *EventRepository) Publish(ctx context.Context, event *domain.LockEvent) error {
+	repo.logger.Debug(fmt.Sprintf("EventRepository.Publish(%s) - START", event.Key))
+	value, err := json.Marshal(event)
+	if err != nil {
+		const msg = "EventRepository.Publish - json.Marshal > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if err := repo.handler.PublishEvent(ctx, event.Key, string(value)); err != nil {
+		const msg = "EventRepository.Publish - repo.handler.PublishEvent > %w"
+		return fmt.Errorf(msg, err)
+	}
+	repo.logger.Debug(fmt.Sprintf("EventRepository.Publish(%s) - END", event.Key))
+	return nil
+}
+
+// Subscribe streams the published events of the locks with the key prefix together with
+// their expiry, as expiry is noticed by the store it reaches the subscribers of every replica.
+// The expired events name the last holders of the lock as previous owners.
+func (repo *EventRepository) Subscribe(ctx context.Context, keyPrefix string) (<-chan *domain.LockEvent, error) {
+	repo.logger.Debug(fmt.Sprintf("EventRepository.Subscribe(%s) - START", keyPrefix))
+	values, expired, err := repo.handler.SubscribeEvents(ctx, keyPrefix)
+	if err != nil {
+		const msg = "EventRepository.Subscribe - repo.handler.SubscribeEvents > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+
+	events := make(chan *domain.LockEvent)
+	go func() {
+		defer close(events)
+		for values != nil || expired != nil {
+			var event *domain.LockEvent
+			select {
+			case value, ok := <-values:
+				if !ok {
+					values = nil
+					continue
+				}
+				event = &domain.LockEvent{}
+				if err := json.Unmarshal([]byte(value), event); err != nil {
+					const msg = "EventRepository.Subscribe - json.Unmarshal > %s"
+					repo.logger.Warn(fmt.Sprintf(msg, err.Error()))
+					continue
+				}
+			case key, ok := <-expired:
+				if !ok {
+					expired = nil
+					continue
+				}
+				event = repo.expired(ctx, key)
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	repo.logger.Debug(fmt.Sprintf("EventRepository.Subscribe(%s) - END", keyPrefix))
+	return events, nil
+}
+
+// expired returns the expired event of the lock with its last holders from the shadow copy of the lock.
+// The shadow is only used if none of its holders holds the lock now, otherwise the lock was acquired again
+// before the expiry was noticed and the shadow is the copy of the new lock.
+func (repo *EventRepository) expired(ctx context.Context, key string) *domain.LockEvent {
+	event := &domain.LockEvent{Type: domain.LockEventExpired, Key: key, At: time.Now().UTC()}
+	value, err := repo.handler.GetLockShadow(ctx, key)
+	if err != nil {
+		const msg = "EventRepository.expired - repo.handler.GetLockShadow > %s"
+		repo.logger.Warn(fmt.Sprintf(msg, err.Error()))
+		return event
+	}
+	if value == "" {
+		return event
+	}
+	holders, err := decodeHolders(value)
+	if err != nil {
+		const msg = "EventRepository.expired - decodeHolders > %s"
+		repo.logger.Warn(fmt.Sprintf(msg, err.Error()))
+		return event
+	}
+	current, err := repo.handler.Get(ctx, key)
+	if err != nil {
+		const msg = "EventRepository.expired - repo.handler.Get > %s"
+		repo.logger.Warn(fmt.Sprintf(msg, err.Error()))
+		return event
+	}
+	if len(current) > 0 && sharesFencingToken(holders, current[0]) {
+		return event
+	}
+	for _, holder := range holders {
+		event.PreviousOwners = append(event.PreviousOwners, holder.Owner)
+		event.FencingToken = max(event.FencingToken, holder.FencingToken)
+	}
+	return event
+}
+
+// sharesFencingToken reports whether one of the holders holds the lock of the value.
+func sharesFencingToken(holders []*domain.Lock, value string) bool {
+	current, err := decodeHolders(value)
+	if err != nil {
+		return false
+	}
+	for _, holder := range holders {
+		for _, other := range current {
+			if holder.FencingToken == other.FencingToken {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Claim reports whether the caller is the first to claim the handling of the event with the id until ttl passed.
+func (repo *EventRepository) Claim(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	claimed, err := repo.handler.ClaimEvent(ctx, id, ttl)
+	if err != nil {
+		const msg = "EventRepository.Claim - repo.handler.ClaimEvent > %w"
+		return false, fmt.Errorf(msg, err)
+	}
+	return claimed, nil
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+)
+
+func TestEventRepositoryExpiredMatchesShadow(t *testing.T) {
+	tests := []struct {
+		name      string
+		reacquire bool
+		owners    []string
+		token     int64
+	}{
+		{
+			name:   "Expired",
+			owners: []string{"ci"},
+			token:  1,
+		},
+		{
+			name:      "AcquiredAgain",
+			reacquire: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			locks, handler := newTestLockRepository(t)
+			events := NewEventRepository(handler, infrastructure.NewMockLogger())
+			_, err := setTestLock(t, locks, testLock("deploy", "ci", domain.LockModeExclusive, false, 20*time.Millisecond), 20*time.Millisecond)
+			assert.NoError(t, err)
+			time.Sleep(40 * time.Millisecond)
+			if tt.reacquire {
+				_, err := setTestLock(t, locks, testLock("deploy", "cd", domain.LockModeExclusive, false, time.Minute), time.Minute)
+				assert.NoError(t, err)
+			}
+
+			// Act
+			event := events.expired(context.Background(), "deploy")
+
+			// Assert
+			assert.Equal(t, domain.LockEventExpired, event.Type)
+			assert.Equal(t, tt.owners, event.PreviousOwners)
+			assert.Equal(t, tt.token, event.FencingToken)
+		})
+	}
+}
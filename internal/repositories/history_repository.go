@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// HistoryStoreHandler is implemented by stores that can keep the audit history of locks.
+type HistoryStoreHandler interface {
+	// AppendHistory adds the entry to the history of the lock, it keeps at most maxEntries entries
+	// and none older than retention.
+	AppendHistory(ctx context.Context, key string, value string, maxEntries int64, retention time.Duration) error
+	// ListHistory returns the entries of the history of the lock added between from and to, the oldest first.
+	// A zero time leaves its end of the range open.
+	ListHistory(ctx context.Context, key string, from time.Time, to time.Time) ([]string, error)
+}
+
+type HistoryRepository struct {
+	handler HistoryStoreHandler
+	policy  *domain.HistoryPolicy
+	logger  domain.Logger
+}
+
+func NewHistoryRepository(handler HistoryStoreHandler, policy *domain.HistoryPolicy, logger domain.Logger) *HistoryRepository {
+	return &HistoryRepository{
+		handler: handler,
+		policy:  policy,
+		logger:  logger,
+	}
+}
+
+// Append adds the entry to the history of its lock, the oldest entries are dropped beyond the policy.
+func (repo *HistoryRepository) Append(ctx context.Context, entry *domain.LockHistoryEntry) error {
+	repo.logger.Debug(fmt.Sprintf("HistoryRepository.Append(%s) - START", entry.Key))
+	value, err := json.Marshal(entry)
+	if err != nil {
+		const msg = "HistoryRepository.Append - json.Marshal > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if err := repo.handler.AppendHistory(ctx, entry.Key, string(value), repo.policy.MaxEntries, repo.policy.Retention); err != nil {
+		const msg = "HistoryRepository.Append - repo.handler.AppendHistory > %w"
+		return fmt.Errorf(msg, err)
+	}
+	repo.logger.Debug(fmt.Sprintf("HistoryRepository.Append(%s) - END", entry.Key))
+	return nil
+}
+
+// List returns the entries of the history of the lock between from and to, the oldest first.
+// Entries beyond the retention are left out even if the store did not drop them yet.
+func (repo *HistoryRepository) List(ctx context.Context, key string, from time.Time, to time.Time) ([]*domain.LockHistoryEntry, error) {
+	repo.logger.Debug(fmt.Sprintf("HistoryRepository.List(%s) - START", key))
+	if oldest := time.Now().Add(-repo.policy.Retention); from.Before(oldest) {
+		from = oldest
+	}
+	values, err := repo.handler.ListHistory(ctx, key, from, to)
+	if err != nil {
+		const msg = "HistoryRepository.List - repo.handler.ListHistory > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	entries := make([]*domain.LockHistoryEntry, 0, len(values))
+	for _, value := range values {
+		var entry domain.LockHistoryEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			const msg = "HistoryRepository.List - json.Unmarshal(%s) > %s"
+			repo.logger.Warn(fmt.Sprintf(msg, value, err.Error()))
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	repo.logger.Debug(fmt.Sprintf("HistoryRepository.List(%s) - END", key))
+	return entries, nil
+}
@@ -1,20 +1,76 @@
 package repositories
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/tyriis/go-locking-service/internal/domain"
 )
 
 type KVStoreHandler interface {
-	Get(key string) ([]string, error)
-	Set(key string, value string, expiration time.Duration) error
-	Del(key string) error
-	Count() (int, error)
+	Get(ctx context.Context, key string) ([]string, error)
+	// Acquire replaces the value of the key only if its current value equals expected, an empty expected value
+	// means the key must not exist. Only the first owner in the wait queue of the key, if any, may acquire it.
+	// The next fencing token of the key is assigned to every lock in value without one.
+	// It returns the fencing token, 0 if others are queued first or -1 if the value did not match.
+	Acquire(ctx context.Context, key string, owner string, expected string, value string, expiration time.Duration) (int64, error)
+	// AcquireMultiple acquires all keys at once like Acquire or none of them. It returns the fencing tokens,
+	// or if any key can't be acquired per key 1 if it could, 0 if others are queued first or -1 if it changed.
+	AcquireMultiple(ctx context.Context, owner string, keys []string, expected []string, values []string, ttls []time.Duration) ([]int64, error)
+	// Takeover replaces the value of the key only if its current value equals expected, regardless of the wait queue.
+	// The next fencing token of the key is assigned to every lock in value without one. The leases of the replaced
+	// holders end at once, so they learn that they lost the lock. It returns the fencing token or -1 if the value
+	// did not match.
+	Takeover(ctx context.Context, key string, expected string, value string, expiration time.Duration) (int64, error)
+	// CompareAndDelete removes the key only if its value still equals expected and reports whether it was removed.
+	CompareAndDelete(ctx context.Context, key string, expected string) (bool, error)
+	// CompareAndSwap replaces the value and expiration of the key only if its value still equals expected
+	// and reports whether it was replaced.
+	CompareAndSwap(ctx context.Context, key string, expected string, value string, expiration time.Duration) (bool, error)
+	// Watch signals on the returned channel whenever the key is written, deleted or expires
+	// and whenever a waiter leaves its queue until ctx is done.
+	Watch(ctx context.Context, key string) (<-chan struct{}, error)
+	// Enqueue adds the owner to the wait queue of the key until deadline and returns its zero based position.
+	Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error)
+	// Dequeue removes the owner from the wait queue of the key.
+	Dequeue(ctx context.Context, key string, owner string) error
+	// Queue returns the owners waiting for the key in arrival order.
+	Queue(ctx context.Context, key string) ([]string, error)
+	Del(ctx context.Context, key string) error
+	Count(ctx context.Context) (int, error)
 }
 
+// LeaseStoreHandler is implemented by stores that keep the last lease of every owner of a lock
+// beyond the lock, so owners can be told that they lost the lock.
+type LeaseStoreHandler interface {
+	// GetLease returns the last lease of the owner of the lock, it is empty if there is none.
+	GetLease(ctx context.Context, key string, owner string) (string, error)
+	// CompareAndRelease replaces the value and expiration of the key like CompareAndSwap, or removes the key
+	// like CompareAndDelete if value is empty, and removes the lease of owner at once, so it is not mistaken
+	// for a lost one after it would have ended. It reports whether the key was replaced.
+	CompareAndRelease(ctx context.Context, key string, owner string, expected string, value string, expiration time.Duration) (bool, error)
+	// CompareAndRevoke removes the key like CompareAndDelete and ends the leases of its holders at once,
+	// so they learn right away that they lost the lock. It reports whether the key was removed.
+	CompareAndRevoke(ctx context.Context, key string, expected string) (bool, error)
+}
+
+// ClockDriftStoreHandler is implemented by stores whose locks are only valid for their TTL minus the clock
+// drift between their nodes.
+type ClockDriftStoreHandler interface {
+	// ClockDrift returns the time deducted from the validity of a lock with the TTL.
+	ClockDrift(ttl time.Duration) time.Duration
+}
+
+// errSessionsUnsupported is returned for locks with a session if the store does not support sessions.
+var errSessionsUnsupported = domain.NewValidationError("LOCK_SESSION_UNSUPPORTED", "the store does not support sessions")
+
+// maxCompareAttempts limits how often a compare-and-set style operation is retried
+// when the stored lock changed between reading and writing it.
+const maxCompareAttempts = 3
+
 type LockRepository struct {
 	handler KVStoreHandler
 	logger  domain.Logger
@@ -27,9 +83,11 @@ func NewLockRepository(handler KVStoreHandler, logger domain.Logger) *LockReposi
 	}
 }
 
-func (repo *LockRepository) Get(key string) ([]*domain.Lock, error) {
+// Get returns every current holder of the lock, or of all locks if key is "*".
+// It returns nil if the lock is not held.
+func (repo *LockRepository) Get(ctx context.Context, key string) ([]*domain.Lock, error) {
 	repo.logger.Debug(fmt.Sprintf("LockRepository.Get(%s) - START", key))
-	result, err := repo.handler.Get(key)
+	result, err := repo.handler.Get(ctx, key)
 	if err != nil {
 		const msg = "LockRepository.Get - repo.handler.Get > %w"
 		return nil, fmt.Errorf(msg, err)
@@ -45,43 +103,226 @@ func (repo *LockRepository) Get(key string) ([]*domain.Lock, error) {
 		return nil, fmt.Errorf(msg, len(result))
 	}
 
-	// itterate over the result and unmarshal the locks
+	// itterate over the result and unmarshal the holders of every lock
 	var locks []*domain.Lock = make([]*domain.Lock, 0)
+	now := time.Now()
 	for _, r := range result {
-		var lock domain.Lock
-		if err := json.Unmarshal([]byte(r), &lock); err != nil {
-			const msg = "LockRepository.Get - json.Unmarshal(%s) > %s"
+		holders, err := decodeHolders(r)
+		if err != nil {
+			const msg = "LockRepository.Get - decodeHolders(%s) > %s"
 			repo.logger.Error(fmt.Sprintf(msg, r, err.Error()))
 			// TODO: would be good to know what lock is invalid, so we can prompt do delete it or even auto fix it
 			repo.logger.Warn("LockRepository.Get > skipping invalid lock, store contains corrupt data")
 			continue
 		}
-		locks = append(locks, &lock)
+		locks = append(locks, activeHolders(holders, now)...)
 	}
 
 	// TODO: check if lock content is valid, warn or delete if not
 
+	if len(locks) == 0 && key != "*" {
+		return nil, nil
+	}
+
 	repo.logger.Debug(fmt.Sprintf("LockRepository.Get(%s) - END", key))
 	return locks, nil
 }
 
-func (repo *LockRepository) Set(key string, value string, duration time.Duration) (*domain.Lock, error) {
+// Set atomically acquires the lock and assigns it a new fencing token.
+// A shared lock joins the current holders if all of them hold it shared as well.
+// An owner that holds the key reentrant in the same mode already increments its hold count
+// and keeps the fencing token instead. A lock with a session expires with the session, it returns
+// a *domain.NotFoundError if the session does not exist and a *domain.ValidationError if the store
+// does not support sessions. The expiry of the lock is reduced by the clock drift of the store.
+// It returns a *domain.LockConflictError if the key is held incompatibly or other owners are queued for it first.
+func (repo *LockRepository) Set(ctx context.Context, key string, value string, duration time.Duration) (*domain.Lock, error) {
 	repo.logger.Debug(fmt.Sprintf("LockRepository.Set(%s) - START", key))
-	if err := repo.handler.Set(key, value, duration); err != nil {
-		const msg = "LockRepository.Set - repo.handler.Set > %w"
+	var lock domain.Lock
+	if err := json.Unmarshal([]byte(value), &lock); err != nil {
+		const msg = "LockRepository.Set - json.Unmarshal > %w"
 		return nil, fmt.Errorf(msg, err)
 	}
-	locks, err := repo.Get(key)
+	if lock.Mode == "" {
+		lock.Mode = domain.LockModeExclusive
+	}
+	if lock.SessionID != "" {
+		if err := repo.bindSession(ctx, &lock); err != nil {
+			return nil, err
+		}
+	}
+	lock.ExpireAt = repo.validUntil(lock.ExpireAt, duration)
+
+	var result *domain.Lock
+	err := repo.retryCompare(ctx, "Set", key, func(raw string, holders []*domain.Lock) (bool, error) {
+		// whether the lock can be acquired again is decided by how the owner holds it, not by this request
+		if i := indexOfOwner(holders, lock.Owner); i >= 0 && holders[i].Reentrant && holders[i].Mode == lock.Mode {
+			held := holders[i]
+			held.HoldCount++
+			if lock.ExpireAt.After(held.ExpireAt) {
+				held.Duration = lock.Duration
+				held.ExpireAt = lock.ExpireAt
+			}
+			swapped, err := repo.replace(ctx, key, raw, holders)
+			if err != nil {
+				const msg = "LockRepository.Set - repo.replace > %w"
+				return false, fmt.Errorf(msg, err)
+			}
+			result = held
+			return swapped, nil
+		}
+		if !compatible(holders, &lock) {
+			const msg = "LockRepository.Set(%s) >"
+			return false, &domain.LockConflictError{Message: fmt.Sprintf(msg, key)}
+		}
+		holders = append(holders, &lock)
+		newValue, err := json.Marshal(holders)
+		if err != nil {
+			const msg = "LockRepository.Set - json.Marshal > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		token, err := repo.acquire(ctx, &lock, raw, string(newValue), expiration(holders, time.Now()))
+		if err != nil {
+			const msg = "LockRepository.Set - repo.acquire > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		if token == SessionMissing {
+			const msg = "LockRepository.Set(%s) > session %s"
+			return false, &domain.NotFoundError{Message: fmt.Sprintf(msg, key, lock.SessionID)}
+		}
+		if token == 0 {
+			const msg = "LockRepository.Set(%s) > queued owners come first"
+			return false, &domain.LockConflictError{Message: fmt.Sprintf(msg, key)}
+		}
+		if token < 0 {
+			return false, nil
+		}
+		lock.FencingToken = token
+		result = &lock
+		return true, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 	repo.logger.Debug(fmt.Sprintf("LockRepository.Set(%s) - END", key))
-	return locks[0], nil
+	return result, nil
+}
+
+// acquire stores value through the handler, a lock with a session is bound to it.
+func (repo *LockRepository) acquire(ctx context.Context, lock *domain.Lock, expected string, value string, ttl time.Duration) (int64, error) {
+	if lock.SessionID == "" {
+		return repo.handler.Acquire(ctx, lock.Key, lock.Owner, expected, value, ttl)
+	}
+	sessions, ok := repo.handler.(SessionStoreHandler)
+	if !ok {
+		return 0, errSessionsUnsupported
+	}
+	return sessions.AcquireInSession(ctx, lock.Key, lock.Owner, lock.SessionID, expected, value, ttl)
+}
+
+// bindSession lets the lock expire with its session, it returns a *domain.NotFoundError if the session does not exist.
+func (repo *LockRepository) bindSession(ctx context.Context, lock *domain.Lock) error {
+	sessions, ok := repo.handler.(SessionStoreHandler)
+	if !ok {
+		return errSessionsUnsupported
+	}
+	value, _, err := sessions.GetSession(ctx, lock.SessionID)
+	if err != nil {
+		const msg = "LockRepository.bindSession - sessions.GetSession > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if value == "" {
+		const msg = "LockRepository.bindSession(%s) > session %s"
+		return &domain.NotFoundError{Message: fmt.Sprintf(msg, lock.Key, lock.SessionID)}
+	}
+	var session domain.Session
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		const msg = "LockRepository.bindSession - json.Unmarshal(%s) > %w"
+		return fmt.Errorf(msg, value, err)
+	}
+	lock.Duration = session.TTL
+	lock.ExpireAt = session.ExpireAt
+	return nil
+}
+
+// SetMultiple atomically acquires all locks for their (common) owner, or none of them.
+// Each lock is checked like in Set and stored until its ExpireAt, a *domain.LockConflictError names all keys
+// that can't be acquired.
+func (repo *LockRepository) SetMultiple(ctx context.Context, locks []*domain.Lock) ([]*domain.Lock, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.SetMultiple(%d) - START", len(locks)))
+	if len(locks) == 0 {
+		return []*domain.Lock{}, nil
+	}
+	owner := locks[0].Owner
+	keys := make([]string, len(locks))
+	for i, lock := range locks {
+		if lock.Mode == "" {
+			lock.Mode = domain.LockModeExclusive
+		}
+		lock.ExpireAt = repo.validUntil(lock.ExpireAt, time.Until(lock.ExpireAt))
+		keys[i] = lock.Key
+	}
+
+	for attempt := 0; attempt < maxCompareAttempts; attempt++ {
+		expected := make([]string, len(locks))
+		values := make([]string, len(locks))
+		ttls := make([]time.Duration, len(locks))
+		held := []string{}
+		now := time.Now()
+		for i, lock := range locks {
+			raw, holders, err := repo.getRaw(ctx, lock.Key)
+			if err != nil {
+				return nil, err
+			}
+			if !compatible(holders, lock) {
+				held = append(held, lock.Key)
+				continue
+			}
+			holders = append(holders, lock)
+			value, err := json.Marshal(holders)
+			if err != nil {
+				const msg = "LockRepository.SetMultiple - json.Marshal > %w"
+				return nil, fmt.Errorf(msg, err)
+			}
+			expected[i], values[i], ttls[i] = raw, string(value), expiration(holders, now)
+		}
+		if len(held) > 0 {
+			const msg = "LockRepository.SetMultiple(%s) >"
+			return nil, &domain.LockConflictError{Message: fmt.Sprintf(msg, strings.Join(held, ", ")), Keys: held}
+		}
+
+		results, err := repo.handler.AcquireMultiple(ctx, owner, keys, expected, values, ttls)
+		if err != nil {
+			const msg = "LockRepository.SetMultiple - repo.handler.AcquireMultiple > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		changed, queued := false, []string{}
+		for i, result := range results {
+			if result == 0 {
+				queued = append(queued, keys[i])
+			} else if result < 0 {
+				changed = true
+			}
+		}
+		if len(queued) > 0 {
+			const msg = "LockRepository.SetMultiple(%s) > queued owners come first"
+			return nil, &domain.LockConflictError{Message: fmt.Sprintf(msg, strings.Join(queued, ", ")), Keys: queued}
+		}
+		if !changed {
+			for i, lock := range locks {
+				lock.FencingToken = results[i]
+			}
+			repo.logger.Debug(fmt.Sprintf("LockRepository.SetMultiple(%d) - END", len(locks)))
+			return locks, nil
+		}
+		// some locks changed since we read them, evaluate all of them again
+	}
+	const msg = "LockRepository.SetMultiple(%s) - locks changed concurrently %d times"
+	return nil, fmt.Errorf(msg, strings.Join(keys, ", "), maxCompareAttempts)
 }
 
-func (repo *LockRepository) Del(key string) error {
+func (repo *LockRepository) Del(ctx context.Context, key string) error {
 	repo.logger.Debug(fmt.Sprintf("LockRepository.Del(%s) - START", key))
-	if err := repo.handler.Del(key); err != nil {
+	if err := repo.handler.Del(ctx, key); err != nil {
 		const msg = "LockRepository.Del - repo.handler.Del > %w"
 		return fmt.Errorf(msg, err)
 	}
@@ -89,9 +330,438 @@ func (repo *LockRepository) Del(key string) error {
 	return nil
 }
 
-func (repo *LockRepository) Count() (int, error) {
+// Release atomically removes owner from the holders of the lock, the lock is deleted with its last holder.
+// A reentrant holder acquired more than once only has its hold count decremented.
+// It returns a *domain.LockLostError if the lease of owner ended before, a *domain.LockNotHeldError
+// if the lock does not exist (anymore) and a *domain.LockOwnerMismatchError if it is held by somebody else.
+func (repo *LockRepository) Release(ctx context.Context, key string, owner string) error {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Release(%s) - START", key))
+	err := repo.retryCompare(ctx, "Release", key, func(raw string, holders []*domain.Lock) (bool, error) {
+		i, err := repo.holderOf(ctx, "Release", key, owner, raw, holders)
+		if err != nil {
+			return false, err
+		}
+		var released bool
+		if holders[i].HoldCount > 1 {
+			holders[i].HoldCount--
+			released, err = repo.replace(ctx, key, raw, holders)
+		} else {
+			released, err = repo.release(ctx, key, owner, raw, append(holders[:i:i], holders[i+1:]...))
+		}
+		if err != nil {
+			const msg = "LockRepository.Release - repo.release > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		return released, nil
+	})
+	if err != nil {
+		return err
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Release(%s) - END", key))
+	return nil
+}
+
+// Takeover atomically replaces all holders of the lock with lock, it gets a new fencing token so
+// the previous holders are fenced off. It returns the new lock and the previous holders,
+// or a *domain.LockNotHeldError if the lock does not exist (anymore).
+func (repo *LockRepository) Takeover(ctx context.Context, lock *domain.Lock, ttl time.Duration) (*domain.Lock, []*domain.Lock, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Takeover(%s) - START", lock.Key))
+	if lock.Mode == "" {
+		lock.Mode = domain.LockModeExclusive
+	}
+	lock.ExpireAt = repo.validUntil(lock.ExpireAt, ttl)
+	value, err := json.Marshal([]*domain.Lock{lock})
+	if err != nil {
+		const msg = "LockRepository.Takeover - json.Marshal > %w"
+		return nil, nil, fmt.Errorf(msg, err)
+	}
+	var previous []*domain.Lock
+	err = repo.retryCompare(ctx, "Takeover", lock.Key, func(raw string, holders []*domain.Lock) (bool, error) {
+		if len(holders) == 0 {
+			const msg = "LockRepository.Takeover(%s) >"
+			return false, &domain.LockNotHeldError{Message: fmt.Sprintf(msg, lock.Key)}
+		}
+		token, err := repo.handler.Takeover(ctx, lock.Key, raw, string(value), ttl)
+		if err != nil {
+			const msg = "LockRepository.Takeover - repo.handler.Takeover > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		if token <= 0 {
+			return false, nil
+		}
+		lock.FencingToken = token
+		previous = holders
+		return true, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Takeover(%s) - END", lock.Key))
+	return lock, previous, nil
+}
+
+// ForceRelease atomically deletes the lock regardless of its holders and returns them, the leases
+// of the holders end at once if the store records them. It returns a *domain.LockNotHeldError if the lock does not exist (anymore).
+func (repo *LockRepository) ForceRelease(ctx context.Context, key string) ([]*domain.Lock, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.ForceRelease(%s) - START", key))
+	var previous []*domain.Lock
+	err := repo.retryCompare(ctx, "ForceRelease", key, func(raw string, holders []*domain.Lock) (bool, error) {
+		if len(holders) == 0 {
+			const msg = "LockRepository.ForceRelease(%s) >"
+			return false, &domain.LockNotHeldError{Message: fmt.Sprintf(msg, key)}
+		}
+		var released bool
+		var err error
+		if leases, ok := repo.handler.(LeaseStoreHandler); ok {
+			released, err = leases.CompareAndRevoke(ctx, key, raw)
+		} else {
+			released, err = repo.handler.CompareAndDelete(ctx, key, raw)
+		}
+		if err != nil {
+			const msg = "LockRepository.ForceRelease - repo.handler.CompareAndDelete > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		previous = holders
+		return released, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.ForceRelease(%s) - END", key))
+	return previous, nil
+}
+
+// Handoff atomically passes the lock held by owner on to newOwner, it keeps its remaining TTL, hold count
+// and fencing token. A lock of a session is detached from it, as newOwner is not part of the session.
+// It returns the same errors as Release if the lock is not held by owner and a *domain.LockConflictError
+// if newOwner holds the lock already.
+func (repo *LockRepository) Handoff(ctx context.Context, key string, owner string, newOwner string) (*domain.Lock, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Handoff(%s) - START", key))
+	var lock *domain.Lock
+	err := repo.retryCompare(ctx, "Handoff", key, func(raw string, holders []*domain.Lock) (bool, error) {
+		i, err := repo.holderOf(ctx, "Handoff", key, owner, raw, holders)
+		if err != nil {
+			return false, err
+		}
+		if indexOfOwner(holders, newOwner) >= 0 {
+			const msg = "LockRepository.Handoff(%s) > %s holds the lock already"
+			return false, &domain.LockConflictError{Message: fmt.Sprintf(msg, key, newOwner)}
+		}
+		lock = holders[i]
+		lock.Owner = newOwner
+		lock.SessionID = ""
+		swapped, err := repo.release(ctx, key, owner, raw, holders)
+		if err != nil {
+			const msg = "LockRepository.Handoff - repo.release > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		return swapped, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Handoff(%s) - END", key))
+	return lock, nil
+}
+
+// Renew atomically extends the lock held by owner, the lock expires ttl from now and keeps its fencing token.
+// It returns the same errors as Release if the lock is not held by owner.
+func (repo *LockRepository) Renew(ctx context.Context, key string, owner string, ttl time.Duration) (*domain.Lock, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Renew(%s) - START", key))
+	var lock *domain.Lock
+	err := repo.retryCompare(ctx, "Renew", key, func(raw string, holders []*domain.Lock) (bool, error) {
+		i, err := repo.holderOf(ctx, "Renew", key, owner, raw, holders)
+		if err != nil {
+			return false, err
+		}
+		lock = holders[i]
+		lock.Duration = int64(ttl.Seconds())
+		lock.ExpireAt = repo.validUntil(time.Now().UTC().Add(ttl), ttl)
+		swapped, err := repo.replace(ctx, key, raw, holders)
+		if err != nil {
+			const msg = "LockRepository.Renew - repo.replace > %w"
+			return false, fmt.Errorf(msg, err)
+		}
+		return swapped, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Renew(%s) - END", key))
+	return lock, nil
+}
+
+// retryCompare reads the lock and passes its stored value and active holders to attempt until attempt is done.
+// An attempt that is not done lost a race against a concurrent change of the lock, so the lock is read and
+// evaluated again, at most maxCompareAttempts times.
+func (repo *LockRepository) retryCompare(ctx context.Context, operation string, key string, attempt func(raw string, holders []*domain.Lock) (bool, error)) error {
+	for i := 0; i < maxCompareAttempts; i++ {
+		raw, holders, err := repo.getRaw(ctx, key)
+		if err != nil {
+			return err
+		}
+		done, err := attempt(raw, holders)
+		if err != nil || done {
+			return err
+		}
+	}
+	const msg = "LockRepository.%s(%s) - lock changed concurrently %d times"
+	return fmt.Errorf(msg, operation, key, maxCompareAttempts)
+}
+
+// validUntil returns the expiry of a lock with the TTL reduced by the clock drift of the store, if it has one.
+// The expiry is set before the lock is written, so the time it takes to acquire it is deducted already.
+func (repo *LockRepository) validUntil(expireAt time.Time, ttl time.Duration) time.Time {
+	if drifting, ok := repo.handler.(ClockDriftStoreHandler); ok {
+		return expireAt.Add(-drifting.ClockDrift(ttl))
+	}
+	return expireAt
+}
+
+// replace atomically stores holders as the new holders of the lock if its stored value is still expected,
+// without holders the lock is deleted. It reports whether the lock was replaced.
+func (repo *LockRepository) replace(ctx context.Context, key string, expected string, holders []*domain.Lock) (bool, error) {
+	if len(holders) == 0 {
+		return repo.handler.CompareAndDelete(ctx, key, expected)
+	}
+	value, err := json.Marshal(holders)
+	if err != nil {
+		return false, err
+	}
+	return repo.handler.CompareAndSwap(ctx, key, expected, string(value), expiration(holders, time.Now()))
+}
+
+// release atomically stores holders like replace once owner gave up the lock, the lease of owner is removed
+// with it if the store records leases, so it is not mistaken for a lost one after it would have ended.
+func (repo *LockRepository) release(ctx context.Context, key string, owner string, expected string, holders []*domain.Lock) (bool, error) {
+	leases, ok := repo.handler.(LeaseStoreHandler)
+	if !ok {
+		return repo.replace(ctx, key, expected, holders)
+	}
+	if len(holders) == 0 {
+		return leases.CompareAndRelease(ctx, key, owner, expected, "", 0)
+	}
+	value, err := json.Marshal(holders)
+	if err != nil {
+		return false, err
+	}
+	return leases.CompareAndRelease(ctx, key, owner, expected, string(value), expiration(holders, time.Now()))
+}
+
+// holderOf returns the index of owner among the active holders of a lock with the stored value raw.
+// It returns a *domain.LockLostError if the lease of owner ended before, a *domain.LockNotHeldError
+// if the lock does not exist (anymore) and a *domain.LockOwnerMismatchError if it is held by somebody else.
+func (repo *LockRepository) holderOf(ctx context.Context, operation string, key string, owner string, raw string, holders []*domain.Lock) (int, error) {
+	i := indexOfOwner(holders, owner)
+	if i < 0 {
+		if lostErr := repo.lost(ctx, operation, key, owner, raw, holders); lostErr != nil {
+			return -1, lostErr
+		}
+	}
+	if len(holders) == 0 {
+		const msg = "LockRepository.%s(%s) >"
+		return -1, &domain.LockNotHeldError{Message: fmt.Sprintf(msg, operation, key)}
+	}
+	if i < 0 {
+		const msg = "LockRepository.%s(%s) >"
+		return -1, &domain.LockOwnerMismatchError{Message: fmt.Sprintf(msg, operation, key)}
+	}
+	return i, nil
+}
+
+// lost returns a *domain.LockLostError naming the current holders if the lease of owner ended without
+// being released, f.e. because the owner did not renew it in time. The lease is found among the stored
+// holders if the lock is still held shared by others, or else in the store if it records leases.
+func (repo *LockRepository) lost(ctx context.Context, operation string, key string, owner string, raw string, holders []*domain.Lock) error {
+	var lease *domain.Lock
+	if raw != "" {
+		if stored, err := decodeHolders(raw); err == nil {
+			if i := indexOfOwner(stored, owner); i >= 0 {
+				lease = stored[i]
+			}
+		}
+	}
+	if lease == nil {
+		leases, ok := repo.handler.(LeaseStoreHandler)
+		if !ok {
+			return nil
+		}
+		value, err := leases.GetLease(ctx, key, owner)
+		if err != nil {
+			const msg = "LockRepository.lost - leases.GetLease > %s"
+			repo.logger.Warn(fmt.Sprintf(msg, err.Error()))
+			return nil
+		}
+		if value == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), &lease); err != nil {
+			const msg = "LockRepository.lost - json.Unmarshal > %s"
+			repo.logger.Warn(fmt.Sprintf(msg, err.Error()))
+			return nil
+		}
+	}
+	if lease.ExpireAt.After(time.Now()) {
+		return nil
+	}
+	owners := make([]string, 0, len(holders))
+	for _, holder := range holders {
+		owners = append(owners, holder.Owner)
+	}
+	const msg = "LockRepository.%s(%s) >"
+	return &domain.LockLostError{Message: fmt.Sprintf(msg, operation, key), Owners: owners, ExpiredAt: lease.ExpireAt}
+}
+
+// getRaw returns the stored value of a single lock together with its active holders.
+// Both are empty if the lock does not exist.
+func (repo *LockRepository) getRaw(ctx context.Context, key string) (string, []*domain.Lock, error) {
+	result, err := repo.handler.Get(ctx, key)
+	if err != nil {
+		const msg = "LockRepository.getRaw - repo.handler.Get > %w"
+		return "", nil, fmt.Errorf(msg, err)
+	}
+	if len(result) == 0 {
+		return "", nil, nil
+	}
+	holders, err := decodeHolders(result[0])
+	if err != nil {
+		const msg = "LockRepository.getRaw - decodeHolders(%s) > %w"
+		return "", nil, fmt.Errorf(msg, result[0], err)
+	}
+	return result[0], activeHolders(holders, time.Now()), nil
+}
+
+// decodeHolders decodes a stored lock value into its holders.
+// Values stored before shared locks existed contain a single exclusive lock instead of a list,
+// values stored before reentrant locks existed are held once.
+func decodeHolders(value string) ([]*domain.Lock, error) {
+	var holders []*domain.Lock
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "{") {
+		var lock domain.Lock
+		if err := json.Unmarshal([]byte(trimmed), &lock); err != nil {
+			return nil, err
+		}
+		holders = []*domain.Lock{&lock}
+	} else if err := json.Unmarshal([]byte(value), &holders); err != nil {
+		return nil, err
+	}
+	for _, holder := range holders {
+		if holder.Mode == "" {
+			holder.Mode = domain.LockModeExclusive
+		}
+		if holder.HoldCount < 1 {
+			holder.HoldCount = 1
+		}
+	}
+	return holders, nil
+}
+
+// activeHolders drops the holders whose lease ended, shared holders expire individually
+// while the stored lock only expires with its longest lease.
+func activeHolders(holders []*domain.Lock, now time.Time) []*domain.Lock {
+	active := make([]*domain.Lock, 0, len(holders))
+	for _, holder := range holders {
+		if holder.ExpireAt.After(now) {
+			active = append(active, holder)
+		}
+	}
+	return active
+}
+
+// expiration returns the TTL of a stored lock, it lives as long as its longest lease.
+func expiration(holders []*domain.Lock, now time.Time) time.Duration {
+	var ttl time.Duration
+	for _, holder := range holders {
+		if d := holder.ExpireAt.Sub(now); d > ttl {
+			ttl = d
+		}
+	}
+	// a lock is never stored without an expiration, even if its clock already ran out
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return ttl
+}
+
+// compatible reports whether lock can be acquired next to the current holders.
+// Any number of owners can hold a lock shared, an exclusive lock can't have other holders.
+func compatible(holders []*domain.Lock, lock *domain.Lock) bool {
+	if len(holders) == 0 {
+		return true
+	}
+	if lock.Mode != domain.LockModeShared || indexOfOwner(holders, lock.Owner) >= 0 {
+		return false
+	}
+	for _, holder := range holders {
+		if holder.Mode != domain.LockModeShared {
+			return false
+		}
+	}
+	return true
+}
+
+// indexOfOwner returns the index of the holder with the given owner or -1.
+func indexOfOwner(holders []*domain.Lock, owner string) int {
+	for i, holder := range holders {
+		if holder.Owner == owner {
+			return i
+		}
+	}
+	return -1
+}
+
+// Watch signals on the returned channel whenever the lock is written, released or expires until ctx is done.
+func (repo *LockRepository) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	changes, err := repo.handler.Watch(ctx, key)
+	if err != nil {
+		const msg = "LockRepository.Watch - repo.handler.Watch > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	return changes, nil
+}
+
+// Enqueue adds the owner to the wait queue of the lock until deadline and returns its position, starting at 1.
+func (repo *LockRepository) Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Enqueue(%s) - START", key))
+	position, err := repo.handler.Enqueue(ctx, key, owner, deadline)
+	if err != nil {
+		const msg = "LockRepository.Enqueue - repo.handler.Enqueue > %w"
+		return 0, fmt.Errorf(msg, err)
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Enqueue(%s) - END", key))
+	return position + 1, nil
+}
+
+// Dequeue removes the owner from the wait queue of the lock.
+func (repo *LockRepository) Dequeue(ctx context.Context, key string, owner string) error {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Dequeue(%s) - START", key))
+	if err := repo.handler.Dequeue(ctx, key, owner); err != nil {
+		const msg = "LockRepository.Dequeue - repo.handler.Dequeue > %w"
+		return fmt.Errorf(msg, err)
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Dequeue(%s) - END", key))
+	return nil
+}
+
+// Queue returns the owners waiting for the lock in the order they will be granted it.
+func (repo *LockRepository) Queue(ctx context.Context, key string) ([]*domain.LockQueueEntry, error) {
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Queue(%s) - START", key))
+	owners, err := repo.handler.Queue(ctx, key)
+	if err != nil {
+		const msg = "LockRepository.Queue - repo.handler.Queue > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	entries := make([]*domain.LockQueueEntry, 0, len(owners))
+	for i, owner := range owners {
+		entries = append(entries, &domain.LockQueueEntry{Key: key, Owner: owner, Position: i + 1})
+	}
+	repo.logger.Debug(fmt.Sprintf("LockRepository.Queue(%s) - END", key))
+	return entries, nil
+}
+
+func (repo *LockRepository) Count(ctx context.Context) (int, error) {
 	repo.logger.Debug("LockRepository.Count - START")
-	count, err := repo.handler.Count()
+	count, err := repo.handler.Count(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,437 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+)
+
+// newTestLockRepository returns a LockRepository on a MemoryHandler that is closed when the test ends.
+func newTestLockRepository(t *testing.T) (*LockRepository, *infrastructure.MemoryHandler) {
+	handler := infrastructure.NewMemoryHandler(infrastructure.NewMockLogger())
+	t.Cleanup(func() { handler.Close() })
+	return NewLockRepository(handler, infrastructure.NewMockLogger()), handler
+}
+
+// testLock returns a lock of owner on key for ttl.
+func testLock(key string, owner string, mode string, reentrant bool, ttl time.Duration) *domain.Lock {
+	now := time.Now().UTC()
+	return &domain.Lock{
+		Key:       key,
+		Owner:     owner,
+		Mode:      mode,
+		Duration:  int64(ttl.Seconds()),
+		CreatedAt: now,
+		ExpireAt:  now.Add(ttl),
+		Reentrant: reentrant,
+		HoldCount: 1,
+	}
+}
+
+// setTestLock acquires the lock through the repository.
+func setTestLock(t *testing.T, repo *LockRepository, lock *domain.Lock, ttl time.Duration) (*domain.Lock, error) {
+	value, err := json.Marshal(lock)
+	assert.NoError(t, err)
+	return repo.Set(context.Background(), lock.Key, string(value), ttl)
+}
+
+func TestLockRepositorySet(t *testing.T) {
+	tests := []struct {
+		name      string
+		held      []*domain.Lock
+		lock      *domain.Lock
+		err       error
+		holders   []string
+		holdCount int
+		token     int64
+	}{
+		{
+			name:      "FreeKey",
+			lock:      testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute),
+			holders:   []string{"ci"},
+			holdCount: 1,
+			token:     1,
+		},
+		{
+			name: "HeldByOther",
+			held: []*domain.Lock{testLock("deploy", "other", domain.LockModeExclusive, false, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name:      "SharedJoinsShared",
+			held:      []*domain.Lock{testLock("deploy", "reader", domain.LockModeShared, false, time.Minute)},
+			lock:      testLock("deploy", "ci", domain.LockModeShared, false, time.Minute),
+			holders:   []string{"reader", "ci"},
+			holdCount: 1,
+			token:     2,
+		},
+		{
+			name: "ExclusiveConflictsWithShared",
+			held: []*domain.Lock{testLock("deploy", "reader", domain.LockModeShared, false, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name: "SharedConflictsWithExclusive",
+			held: []*domain.Lock{testLock("deploy", "writer", domain.LockModeExclusive, false, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeShared, false, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name: "SharedHolderCanNotJoinTwice",
+			held: []*domain.Lock{testLock("deploy", "ci", domain.LockModeShared, false, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeShared, false, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name:      "ReentrantOwnerHoldsAgain",
+			held:      []*domain.Lock{testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute)},
+			lock:      testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute),
+			holders:   []string{"ci"},
+			holdCount: 2,
+			token:     1,
+		},
+		{
+			name: "ReentrantOwnerInOtherMode",
+			held: []*domain.Lock{testLock("deploy", "ci", domain.LockModeShared, true, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name:      "HeldReentrantDecides",
+			held:      []*domain.Lock{testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute)},
+			lock:      testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute),
+			holders:   []string{"ci"},
+			holdCount: 2,
+			token:     1,
+		},
+		{
+			name: "HeldNotReentrantDecides",
+			held: []*domain.Lock{testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name: "NotReentrantOwner",
+			held: []*domain.Lock{testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute)},
+			lock: testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute),
+			err:  &domain.LockConflictError{},
+		},
+		{
+			name:      "ExpiredHolderIsDropped",
+			held:      []*domain.Lock{testLock("deploy", "writer", domain.LockModeExclusive, false, -time.Second)},
+			lock:      testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute),
+			holders:   []string{"ci"},
+			holdCount: 1,
+			token:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo, _ := newTestLockRepository(t)
+			for _, held := range tt.held {
+				_, err := setTestLock(t, repo, held, time.Minute)
+				assert.NoError(t, err)
+			}
+
+			// Act
+			lock, err := setTestLock(t, repo, tt.lock, time.Minute)
+
+			// Assert
+			if tt.err != nil {
+				assert.IsType(t, tt.err, err)
+				assert.Nil(t, lock)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.holdCount, lock.HoldCount)
+			assert.Equal(t, tt.token, lock.FencingToken)
+			holders, err := repo.Get(context.Background(), "deploy")
+			assert.NoError(t, err)
+			owners := []string{}
+			for _, holder := range holders {
+				owners = append(owners, holder.Owner)
+			}
+			assert.Equal(t, tt.holders, owners)
+		})
+	}
+}
+
+func TestLockRepositoryRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		held      []*domain.Lock
+		owner     string
+		wait      time.Duration
+		err       error
+		holders   []string
+		holdCount int
+	}{
+		{
+			name:    "LastHolder",
+			held:    []*domain.Lock{testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute)},
+			owner:   "ci",
+			holders: []string{},
+		},
+		{
+			name: "SharedHolder",
+			held: []*domain.Lock{
+				testLock("deploy", "reader", domain.LockModeShared, false, time.Minute),
+				testLock("deploy", "ci", domain.LockModeShared, false, time.Minute),
+			},
+			owner:     "ci",
+			holders:   []string{"reader"},
+			holdCount: 1,
+		},
+		{
+			name: "ReentrantHolder",
+			held: []*domain.Lock{
+				testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute),
+				testLock("deploy", "ci", domain.LockModeExclusive, true, time.Minute),
+			},
+			owner:     "ci",
+			holders:   []string{"ci"},
+			holdCount: 1,
+		},
+		{
+			name:  "NotHeld",
+			owner: "ci",
+			err:   &domain.LockNotHeldError{},
+		},
+		{
+			name:  "OtherOwner",
+			held:  []*domain.Lock{testLock("deploy", "other", domain.LockModeExclusive, false, time.Minute)},
+			owner: "ci",
+			err:   &domain.LockOwnerMismatchError{},
+		},
+		{
+			name:  "LostLock",
+			held:  []*domain.Lock{testLock("deploy", "ci", domain.LockModeExclusive, false, 20*time.Millisecond)},
+			owner: "ci",
+			wait:  50 * time.Millisecond,
+			err:   &domain.LockLostError{},
+		},
+		{
+			name: "LostSharedHold",
+			held: []*domain.Lock{
+				testLock("deploy", "reader", domain.LockModeShared, false, time.Minute),
+				testLock("deploy", "ci", domain.LockModeShared, false, 20*time.Millisecond),
+			},
+			owner: "ci",
+			wait:  50 * time.Millisecond,
+			err:   &domain.LockLostError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo, _ := newTestLockRepository(t)
+			for _, held := range tt.held {
+				_, err := setTestLock(t, repo, held, time.Until(held.ExpireAt))
+				assert.NoError(t, err)
+			}
+			time.Sleep(tt.wait)
+
+			// Act
+			err := repo.Release(context.Background(), "deploy", tt.owner)
+
+			// Assert
+			if tt.err != nil {
+				assert.IsType(t, tt.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			holders, err := repo.Get(context.Background(), "deploy")
+			assert.NoError(t, err)
+			owners := []string{}
+			for _, holder := range holders {
+				owners = append(owners, holder.Owner)
+				if holder.Owner == tt.owner {
+					assert.Equal(t, tt.holdCount, holder.HoldCount)
+				}
+			}
+			assert.Equal(t, tt.holders, owners)
+		})
+	}
+}
+
+func TestLockRepositoryLostLockNamesCurrentHolders(t *testing.T) {
+	// Arrange
+	repo, _ := newTestLockRepository(t)
+	ctx := context.Background()
+	lost := testLock("deploy", "ci", domain.LockModeExclusive, false, 20*time.Millisecond)
+	_, err := setTestLock(t, repo, lost, 20*time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = setTestLock(t, repo, testLock("deploy", "other", domain.LockModeExclusive, false, time.Minute), time.Minute)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = repo.Renew(ctx, "deploy", "ci", time.Minute)
+
+	// Assert
+	var lostErr *domain.LockLostError
+	assert.ErrorAs(t, err, &lostErr)
+	assert.Equal(t, []string{"other"}, lostErr.Owners)
+	assert.WithinDuration(t, lost.ExpireAt, lostErr.ExpiredAt, time.Millisecond)
+}
+
+func TestLockRepositoryReleasedLockIsNotLost(t *testing.T) {
+	// Arrange
+	repo, _ := newTestLockRepository(t)
+	ctx := context.Background()
+	_, err := setTestLock(t, repo, testLock("deploy", "ci", domain.LockModeExclusive, false, 20*time.Millisecond), 20*time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Release(ctx, "deploy", "ci"))
+	time.Sleep(50 * time.Millisecond)
+
+	// Act
+	err = repo.Release(ctx, "deploy", "ci")
+
+	// Assert
+	assert.IsType(t, &domain.LockNotHeldError{}, err)
+}
+
+func TestLockRepositoryRevokedLockIsLostAtOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(ctx context.Context, repo *LockRepository) error
+		owners []string
+	}{
+		{
+			name: "Takeover",
+			revoke: func(ctx context.Context, repo *LockRepository) error {
+				_, _, err := repo.Takeover(ctx, testLock("deploy", "admin", domain.LockModeExclusive, false, time.Minute), time.Minute)
+				return err
+			},
+			owners: []string{"admin"},
+		},
+		{
+			name: "ForceRelease",
+			revoke: func(ctx context.Context, repo *LockRepository) error {
+				_, err := repo.ForceRelease(ctx, "deploy")
+				return err
+			},
+			owners: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo, _ := newTestLockRepository(t)
+			ctx := context.Background()
+			_, err := setTestLock(t, repo, testLock("deploy", "ci", domain.LockModeExclusive, false, time.Minute), time.Minute)
+			assert.NoError(t, err)
+			assert.NoError(t, tt.revoke(ctx, repo))
+
+			// Act
+			_, renewErr := repo.Renew(ctx, "deploy", "ci", time.Minute)
+			releaseErr := repo.Release(ctx, "deploy", "ci")
+
+			// Assert
+			for _, err := range []error{renewErr, releaseErr} {
+				var lostErr *domain.LockLostError
+				assert.ErrorAs(t, err, &lostErr)
+				assert.Equal(t, tt.owners, lostErr.Owners)
+				assert.WithinDuration(t, time.Now(), lostErr.ExpiredAt, time.Second)
+			}
+		})
+	}
+}
+
+func TestLockRepositorySetInSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		session     string
+		unsupported bool
+		err         error
+	}{
+		{name: "ExistingSession", session: "session-1"},
+		{name: "MissingSession", session: "missing", err: &domain.NotFoundError{}},
+		{name: "StoreWithoutSessions", session: "session-1", unsupported: true, err: &domain.ValidationError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo, handler := newTestLockRepository(t)
+			if tt.unsupported {
+				// hide everything of the handler but the lock storage
+				repo = NewLockRepository(struct{ KVStoreHandler }{handler}, infrastructure.NewMockLogger())
+			}
+			ctx := context.Background()
+			session := domain.Session{ID: "session-1", TTL: 30, ExpireAt: time.Now().UTC().Add(30 * time.Second)}
+			value, err := json.Marshal(session)
+			assert.NoError(t, err)
+			created, err := handler.CreateSession(ctx, session.ID, string(value), 30*time.Second)
+			assert.NoError(t, err)
+			assert.True(t, created)
+			lock := testLock("deploy", "ci", domain.LockModeExclusive, false, 0)
+			lock.SessionID = tt.session
+
+			// Act
+			result, err := setTestLock(t, repo, lock, 0)
+
+			// Assert
+			if tt.err != nil {
+				assert.IsType(t, tt.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(30), result.Duration)
+			assert.True(t, result.ExpireAt.Equal(session.ExpireAt))
+			_, keys, err := handler.GetSession(ctx, session.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"deploy"}, keys)
+		})
+	}
+}
+
+func TestDecodeHolders(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		holders []*domain.Lock
+		err     bool
+	}{
+		{
+			name:    "SingleLock",
+			value:   `{"key":"deploy","owner":"ci"}`,
+			holders: []*domain.Lock{{Key: "deploy", Owner: "ci", Mode: domain.LockModeExclusive, HoldCount: 1}},
+		},
+		{
+			name:  "Holders",
+			value: `[{"key":"deploy","owner":"reader","mode":"shared","holdCount":1},{"key":"deploy","owner":"ci","mode":"shared","holdCount":3}]`,
+			holders: []*domain.Lock{
+				{Key: "deploy", Owner: "reader", Mode: domain.LockModeShared, HoldCount: 1},
+				{Key: "deploy", Owner: "ci", Mode: domain.LockModeShared, HoldCount: 3},
+			},
+		},
+		{
+			name:  "Invalid",
+			value: `{"key":`,
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			holders, err := decodeHolders(tt.value)
+
+			// Assert
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.holders, holders)
+		})
+	}
+}
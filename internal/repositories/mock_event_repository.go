@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// MockEventRepository mocks the EventRepository interface.
+type MockEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockEventRepository) Publish(ctx context.Context, event *domain.LockEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockEventRepository) Subscribe(ctx context.Context, keyPrefix string) (<-chan *domain.LockEvent, error) {
+	args := m.Called(ctx, keyPrefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(<-chan *domain.LockEvent), args.Error(1)
+}
+
+func (m *MockEventRepository) Claim(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, id, ttl)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// MockHistoryRepository mocks the HistoryRepository interface.
+type MockHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockHistoryRepository) Append(ctx context.Context, entry *domain.LockHistoryEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockHistoryRepository) List(ctx context.Context, key string, from time.Time, to time.Time) ([]*domain.LockHistoryEntry, error) {
+	args := m.Called(ctx, key, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.LockHistoryEntry), args.Error(1)
+}
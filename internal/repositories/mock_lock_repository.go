@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -12,25 +13,99 @@ type MockLockRepository struct {
 	mock.Mock
 }
 
-func (m *MockLockRepository) Get(key string) ([]*domain.Lock, error) {
-	args := m.Called(key)
+func (m *MockLockRepository) Get(ctx context.Context, key string) ([]*domain.Lock, error) {
+	args := m.Called(ctx, key)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*domain.Lock), args.Error(1)
 }
 
-func (m *MockLockRepository) Set(key string, value string, duration time.Duration) (*domain.Lock, error) {
-	args := m.Called(key, value, duration)
+func (m *MockLockRepository) Set(ctx context.Context, key string, value string, duration time.Duration) (*domain.Lock, error) {
+	args := m.Called(ctx, key, value, duration)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Lock), args.Error(1)
+}
+
+func (m *MockLockRepository) SetMultiple(ctx context.Context, locks []*domain.Lock) ([]*domain.Lock, error) {
+	args := m.Called(ctx, locks)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Lock), args.Error(1)
+}
+
+func (m *MockLockRepository) Takeover(ctx context.Context, lock *domain.Lock, duration time.Duration) (*domain.Lock, []*domain.Lock, error) {
+	args := m.Called(ctx, lock, duration)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*domain.Lock), args.Get(1).([]*domain.Lock), args.Error(2)
+}
+
+func (m *MockLockRepository) ForceRelease(ctx context.Context, key string) ([]*domain.Lock, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Lock), args.Error(1)
+}
+
+func (m *MockLockRepository) Handoff(ctx context.Context, key string, owner string, newOwner string) (*domain.Lock, error) {
+	args := m.Called(ctx, key, owner, newOwner)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*domain.Lock), args.Error(1)
 }
 
-func (m *MockLockRepository) Del(key string) error {
-	args := m.Called(key)
+func (m *MockLockRepository) Del(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
 	return args.Error(0)
 }
 
-func (m *MockLockRepository) Count() (int, error) {
-	args := m.Called()
+func (m *MockLockRepository) Release(ctx context.Context, key string, owner string) error {
+	args := m.Called(ctx, key, owner)
+	return args.Error(0)
+}
+
+func (m *MockLockRepository) Renew(ctx context.Context, key string, owner string, ttl time.Duration) (*domain.Lock, error) {
+	args := m.Called(ctx, key, owner, ttl)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Lock), args.Error(1)
+}
+
+func (m *MockLockRepository) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(<-chan struct{}), args.Error(1)
+}
+
+func (m *MockLockRepository) Enqueue(ctx context.Context, key string, owner string, deadline time.Time) (int, error) {
+	args := m.Called(ctx, key, owner, deadline)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLockRepository) Dequeue(ctx context.Context, key string, owner string) error {
+	args := m.Called(ctx, key, owner)
+	return args.Error(0)
+}
+
+func (m *MockLockRepository) Queue(ctx context.Context, key string) ([]*domain.LockQueueEntry, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.LockQueueEntry), args.Error(1)
+}
+
+func (m *MockLockRepository) Count(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
 	return args.Int(0), args.Error(1)
 }
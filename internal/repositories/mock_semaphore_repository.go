@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// MockSemaphoreRepository mocks the SemaphoreRepository interface.
+type MockSemaphoreRepository struct {
+	mock.Mock
+}
+
+func (m *MockSemaphoreRepository) Create(ctx context.Context, semaphore *domain.Semaphore) error {
+	args := m.Called(ctx, semaphore)
+	return args.Error(0)
+}
+
+func (m *MockSemaphoreRepository) Get(ctx context.Context, key string) (*domain.Semaphore, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Semaphore), args.Error(1)
+}
+
+func (m *MockSemaphoreRepository) List(ctx context.Context) ([]*domain.Semaphore, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Semaphore), args.Error(1)
+}
+
+func (m *MockSemaphoreRepository) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockSemaphoreRepository) Acquire(ctx context.Context, permit *domain.SemaphorePermit, ttl time.Duration) (*domain.SemaphorePermit, error) {
+	args := m.Called(ctx, permit, ttl)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SemaphorePermit), args.Error(1)
+}
+
+func (m *MockSemaphoreRepository) Release(ctx context.Context, key string, owner string) error {
+	args := m.Called(ctx, key, owner)
+	return args.Error(0)
+}
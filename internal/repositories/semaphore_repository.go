@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// Results of SemaphoreStoreHandler.AcquirePermit.
+const (
+	PermitAcquired    int64 = 1
+	PermitsExhausted  int64 = 0
+	PermitNoSemaphore int64 = -1
+	PermitAlreadyHeld int64 = -2
+)
+
+// Results of SemaphoreStoreHandler.DeleteSemaphore.
+const (
+	SemaphoreDeleted  int64 = 1
+	SemaphoreInUse    int64 = 0
+	SemaphoreNotFound int64 = -1
+)
+
+type SemaphoreStoreHandler interface {
+	// CreateSemaphore stores the semaphore definition only if the key does not exist yet and reports whether it was stored.
+	CreateSemaphore(ctx context.Context, key string, value string) (bool, error)
+	// GetSemaphore returns the semaphore definition and its unexpired permits, the definition is empty if the key does not exist.
+	GetSemaphore(ctx context.Context, key string) (string, []string, error)
+	// ListSemaphores returns the keys of all semaphores.
+	ListSemaphores(ctx context.Context) ([]string, error)
+	// DeleteSemaphore removes a semaphore without held permits.
+	// It returns SemaphoreDeleted, SemaphoreInUse or SemaphoreNotFound.
+	DeleteSemaphore(ctx context.Context, key string) (int64, error)
+	// AcquirePermit takes one permit of the semaphore for owner, expired permits are reclaimed first.
+	// It returns PermitAcquired, PermitsExhausted, PermitNoSemaphore or PermitAlreadyHeld.
+	AcquirePermit(ctx context.Context, key string, owner string, value string, expiration time.Duration) (int64, error)
+	// ReleasePermit returns the permit of owner and reports whether owner held one.
+	ReleasePermit(ctx context.Context, key string, owner string) (bool, error)
+}
+
+type SemaphoreRepository struct {
+	handler SemaphoreStoreHandler
+	logger  domain.Logger
+}
+
+func NewSemaphoreRepository(handler SemaphoreStoreHandler, logger domain.Logger) *SemaphoreRepository {
+	return &SemaphoreRepository{
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// Create stores a new semaphore, it returns a *domain.LockConflictError if the key is already used.
+func (repo *SemaphoreRepository) Create(ctx context.Context, semaphore *domain.Semaphore) error {
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Create(%s) - START", semaphore.Key))
+	// only the definition is stored, holders are kept by the handler
+	definition := *semaphore
+	definition.Holders = nil
+	value, err := json.Marshal(definition)
+	if err != nil {
+		const msg = "SemaphoreRepository.Create - json.Marshal > %w"
+		return fmt.Errorf(msg, err)
+	}
+	created, err := repo.handler.CreateSemaphore(ctx, semaphore.Key, string(value))
+	if err != nil {
+		const msg = "SemaphoreRepository.Create - repo.handler.CreateSemaphore > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if !created {
+		const msg = "SemaphoreRepository.Create(%s) >"
+		return &domain.LockConflictError{Message: fmt.Sprintf(msg, semaphore.Key)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Create(%s) - END", semaphore.Key))
+	return nil
+}
+
+// Get returns the semaphore with its current holders or nil if it does not exist.
+func (repo *SemaphoreRepository) Get(ctx context.Context, key string) (*domain.Semaphore, error) {
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Get(%s) - START", key))
+	definition, permits, err := repo.handler.GetSemaphore(ctx, key)
+	if err != nil {
+		const msg = "SemaphoreRepository.Get - repo.handler.GetSemaphore > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	if definition == "" {
+		return nil, nil
+	}
+
+	var semaphore domain.Semaphore
+	if err := json.Unmarshal([]byte(definition), &semaphore); err != nil {
+		const msg = "SemaphoreRepository.Get - json.Unmarshal(%s) > %w"
+		return nil, fmt.Errorf(msg, definition, err)
+	}
+	semaphore.Holders = make([]*domain.SemaphorePermit, 0, len(permits))
+	for _, p := range permits {
+		var permit domain.SemaphorePermit
+		if err := json.Unmarshal([]byte(p), &permit); err != nil {
+			const msg = "SemaphoreRepository.Get - json.Unmarshal(%s) > %s"
+			repo.logger.Error(fmt.Sprintf(msg, p, err.Error()))
+			repo.logger.Warn("SemaphoreRepository.Get > skipping invalid permit, store contains corrupt data")
+			continue
+		}
+		semaphore.Holders = append(semaphore.Holders, &permit)
+	}
+	sort.Slice(semaphore.Holders, func(i, j int) bool {
+		return semaphore.Holders[i].CreatedAt.Before(semaphore.Holders[j].CreatedAt)
+	})
+	semaphore.Available = semaphore.Permits - len(permits)
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Get(%s) - END", key))
+	return &semaphore, nil
+}
+
+// List returns all semaphores with their current holders.
+func (repo *SemaphoreRepository) List(ctx context.Context) ([]*domain.Semaphore, error) {
+	repo.logger.Debug("SemaphoreRepository.List - START")
+	keys, err := repo.handler.ListSemaphores(ctx)
+	if err != nil {
+		const msg = "SemaphoreRepository.List - repo.handler.ListSemaphores > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	semaphores := make([]*domain.Semaphore, 0, len(keys))
+	for _, key := range keys {
+		semaphore, err := repo.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		// deleted in the meantime
+		if semaphore == nil {
+			continue
+		}
+		semaphores = append(semaphores, semaphore)
+	}
+	repo.logger.Debug("SemaphoreRepository.List - END")
+	return semaphores, nil
+}
+
+// Delete removes a semaphore, it returns a *domain.NotFoundError if it does not exist
+// and a *domain.LockConflictError while permits are held.
+func (repo *SemaphoreRepository) Delete(ctx context.Context, key string) error {
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Delete(%s) - START", key))
+	result, err := repo.handler.DeleteSemaphore(ctx, key)
+	if err != nil {
+		const msg = "SemaphoreRepository.Delete - repo.handler.DeleteSemaphore > %w"
+		return fmt.Errorf(msg, err)
+	}
+	switch result {
+	case SemaphoreNotFound:
+		const msg = "SemaphoreRepository.Delete(%s) >"
+		return &domain.NotFoundError{Message: fmt.Sprintf(msg, key)}
+	case SemaphoreInUse:
+		const msg = "SemaphoreRepository.Delete(%s) > permits are held"
+		return &domain.LockConflictError{Message: fmt.Sprintf(msg, key)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Delete(%s) - END", key))
+	return nil
+}
+
+// Acquire atomically takes one permit of the semaphore for the owner of permit.
+// It returns a *domain.NotFoundError if the semaphore does not exist, a *domain.SemaphoreExhaustedError
+// if all permits are held and a *domain.LockConflictError if the owner already holds a permit.
+func (repo *SemaphoreRepository) Acquire(ctx context.Context, permit *domain.SemaphorePermit, ttl time.Duration) (*domain.SemaphorePermit, error) {
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Acquire(%s) - START", permit.Key))
+	value, err := json.Marshal(permit)
+	if err != nil {
+		const msg = "SemaphoreRepository.Acquire - json.Marshal > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	result, err := repo.handler.AcquirePermit(ctx, permit.Key, permit.Owner, string(value), ttl)
+	if err != nil {
+		const msg = "SemaphoreRepository.Acquire - repo.handler.AcquirePermit > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	switch result {
+	case PermitNoSemaphore:
+		const msg = "SemaphoreRepository.Acquire(%s) >"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, permit.Key)}
+	case PermitsExhausted:
+		const msg = "SemaphoreRepository.Acquire(%s) >"
+		return nil, &domain.SemaphoreExhaustedError{Message: fmt.Sprintf(msg, permit.Key)}
+	case PermitAlreadyHeld:
+		const msg = "SemaphoreRepository.Acquire(%s) > %s holds a permit already"
+		return nil, &domain.LockConflictError{Message: fmt.Sprintf(msg, permit.Key, permit.Owner)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Acquire(%s) - END", permit.Key))
+	return permit, nil
+}
+
+// Release returns the permit of owner, it returns a *domain.LockNotHeldError if owner holds none (anymore).
+func (repo *SemaphoreRepository) Release(ctx context.Context, key string, owner string) error {
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Release(%s) - START", key))
+	released, err := repo.handler.ReleasePermit(ctx, key, owner)
+	if err != nil {
+		const msg = "SemaphoreRepository.Release - repo.handler.ReleasePermit > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if !released {
+		const msg = "SemaphoreRepository.Release(%s) >"
+		return &domain.LockNotHeldError{Message: fmt.Sprintf(msg, key)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SemaphoreRepository.Release(%s) - END", key))
+	return nil
+}
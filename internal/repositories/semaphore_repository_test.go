@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+)
+
+// stubSemaphoreStore answers the calls of a SemaphoreRepository with its fields.
+type stubSemaphoreStore struct {
+	definition string
+	permits    []string
+	result     int64
+	released   bool
+}
+
+func (s *stubSemaphoreStore) CreateSemaphore(ctx context.Context, key string, value string) (bool, error) {
+	return s.result == 1, nil
+}
+
+func (s *stubSemaphoreStore) GetSemaphore(ctx context.Context, key string) (string, []string, error) {
+	return s.definition, s.permits, nil
+}
+
+func (s *stubSemaphoreStore) ListSemaphores(ctx context.Context) ([]string, error) {
+	return []string{"builds"}, nil
+}
+
+func (s *stubSemaphoreStore) DeleteSemaphore(ctx context.Context, key string) (int64, error) {
+	return s.result, nil
+}
+
+func (s *stubSemaphoreStore) AcquirePermit(ctx context.Context, key string, owner string, value string, expiration time.Duration) (int64, error) {
+	return s.result, nil
+}
+
+func (s *stubSemaphoreStore) ReleasePermit(ctx context.Context, key string, owner string) (bool, error) {
+	return s.released, nil
+}
+
+// testPermit returns the stored form of a permit of owner created at createdAt.
+func testPermit(t *testing.T, owner string, createdAt time.Time) string {
+	value, err := json.Marshal(domain.SemaphorePermit{Key: "builds", Owner: owner, Duration: 60, CreatedAt: createdAt, ExpireAt: createdAt.Add(time.Minute)})
+	assert.NoError(t, err)
+	return string(value)
+}
+
+func TestSemaphoreRepositoryGet(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name       string
+		definition string
+		permits    []string
+		found      bool
+		owners     []string
+		available  int
+	}{
+		{
+			name: "NotFound",
+		},
+		{
+			name:       "NoPermits",
+			definition: `{"key":"builds","permits":3}`,
+			found:      true,
+			owners:     []string{},
+			available:  3,
+		},
+		{
+			name:       "HoldersInOrder",
+			definition: `{"key":"builds","permits":3}`,
+			permits:    []string{testPermit(t, "late", now), testPermit(t, "early", now.Add(-time.Minute))},
+			found:      true,
+			owners:     []string{"early", "late"},
+			available:  1,
+		},
+		{
+			name:       "CorruptPermitIsSkippedButCounted",
+			definition: `{"key":"builds","permits":3}`,
+			permits:    []string{testPermit(t, "ci", now), `{"owner":`},
+			found:      true,
+			owners:     []string{"ci"},
+			available:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			store := &stubSemaphoreStore{definition: tt.definition, permits: tt.permits}
+			repo := NewSemaphoreRepository(store, infrastructure.NewMockLogger())
+
+			// Act
+			semaphore, err := repo.Get(context.Background(), "builds")
+
+			// Assert
+			assert.NoError(t, err)
+			if !tt.found {
+				assert.Nil(t, semaphore)
+				return
+			}
+			owners := []string{}
+			for _, holder := range semaphore.Holders {
+				owners = append(owners, holder.Owner)
+			}
+			assert.Equal(t, tt.owners, owners)
+			assert.Equal(t, tt.available, semaphore.Available)
+		})
+	}
+}
+
+func TestSemaphoreRepositoryAcquire(t *testing.T) {
+	tests := []struct {
+		name   string
+		result int64
+		err    error
+	}{
+		{name: "Acquired", result: PermitAcquired},
+		{name: "Exhausted", result: PermitsExhausted, err: &domain.SemaphoreExhaustedError{}},
+		{name: "NoSemaphore", result: PermitNoSemaphore, err: &domain.NotFoundError{}},
+		{name: "AlreadyHeld", result: PermitAlreadyHeld, err: &domain.LockConflictError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := NewSemaphoreRepository(&stubSemaphoreStore{result: tt.result}, infrastructure.NewMockLogger())
+			permit := &domain.SemaphorePermit{Key: "builds", Owner: "ci", Duration: 60}
+
+			// Act
+			result, err := repo.Acquire(context.Background(), permit, time.Minute)
+
+			// Assert
+			if tt.err != nil {
+				assert.IsType(t, tt.err, err)
+				assert.Nil(t, result)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, permit, result)
+		})
+	}
+}
+
+func TestSemaphoreRepositoryDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		result int64
+		err    error
+	}{
+		{name: "Deleted", result: SemaphoreDeleted},
+		{name: "InUse", result: SemaphoreInUse, err: &domain.LockConflictError{}},
+		{name: "NotFound", result: SemaphoreNotFound, err: &domain.NotFoundError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := NewSemaphoreRepository(&stubSemaphoreStore{result: tt.result}, infrastructure.NewMockLogger())
+
+			// Act
+			err := repo.Delete(context.Background(), "builds")
+
+			// Assert
+			if tt.err != nil {
+				assert.IsType(t, tt.err, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSemaphoreRepositoryRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		released bool
+		err      error
+	}{
+		{name: "Held", released: true},
+		{name: "NotHeld", err: &domain.LockNotHeldError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := NewSemaphoreRepository(&stubSemaphoreStore{released: tt.released}, infrastructure.NewMockLogger())
+
+			// Act
+			err := repo.Release(context.Background(), "builds", "ci")
+
+			// Assert
+			if tt.err != nil {
+				assert.IsType(t, tt.err, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
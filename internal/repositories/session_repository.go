@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// Results of SessionStoreHandler.AcquireInSession besides those of KVStoreHandler.Acquire.
+const SessionMissing int64 = -2
+
+// SessionStoreHandler is implemented by stores that can bind locks to sessions.
+type SessionStoreHandler interface {
+	// CreateSession stores a new session for ttl and reports whether the id was still unused.
+	CreateSession(ctx context.Context, id string, value string, ttl time.Duration) (bool, error)
+	// GetSession returns the session and the keys of the locks it holds, the session is empty if it does not exist.
+	GetSession(ctx context.Context, id string) (string, []string, error)
+	// RenewSession replaces the session with value for ttl, all locks it holds expire with it at expireAt.
+	// It reports whether the session exists.
+	RenewSession(ctx context.Context, id string, value string, expireAt time.Time, ttl time.Duration) (bool, error)
+	// DeleteSession removes the session and releases all locks it holds at once.
+	// It returns the number of released locks or -1 if the session does not exist.
+	DeleteSession(ctx context.Context, id string) (int64, error)
+	// AcquireInSession acquires the key like KVStoreHandler.Acquire and binds the new holder to the session,
+	// it expires with the session then. It returns SessionMissing if the session does not exist.
+	AcquireInSession(ctx context.Context, key string, owner string, session string, expected string, value string, expiration time.Duration) (int64, error)
+}
+
+type SessionRepository struct {
+	handler SessionStoreHandler
+	logger  domain.Logger
+}
+
+func NewSessionRepository(handler SessionStoreHandler, logger domain.Logger) *SessionRepository {
+	return &SessionRepository{
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// Create stores a new session, it returns a *domain.LockConflictError if the id is already used.
+func (repo *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Create(%s) - START", session.ID))
+	value, err := encodeSession(session)
+	if err != nil {
+		const msg = "SessionRepository.Create - json.Marshal > %w"
+		return fmt.Errorf(msg, err)
+	}
+	created, err := repo.handler.CreateSession(ctx, session.ID, value, time.Until(session.ExpireAt))
+	if err != nil {
+		const msg = "SessionRepository.Create - repo.handler.CreateSession > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if !created {
+		const msg = "SessionRepository.Create(%s) >"
+		return &domain.LockConflictError{Message: fmt.Sprintf(msg, session.ID)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Create(%s) - END", session.ID))
+	return nil
+}
+
+// Get returns the session with the keys of its locks or nil if it does not exist (anymore).
+func (repo *SessionRepository) Get(ctx context.Context, id string) (*domain.Session, error) {
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Get(%s) - START", id))
+	value, keys, err := repo.handler.GetSession(ctx, id)
+	if err != nil {
+		const msg = "SessionRepository.Get - repo.handler.GetSession > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	var session domain.Session
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		const msg = "SessionRepository.Get - json.Unmarshal(%s) > %w"
+		return nil, fmt.Errorf(msg, value, err)
+	}
+	session.Keys = keys
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Get(%s) - END", id))
+	return &session, nil
+}
+
+// Renew stores the heartbeat of the session, it and all its locks expire at session.ExpireAt then.
+// It returns a *domain.NotFoundError if the session expired already.
+func (repo *SessionRepository) Renew(ctx context.Context, session *domain.Session) (*domain.Session, error) {
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Renew(%s) - START", session.ID))
+	value, err := encodeSession(session)
+	if err != nil {
+		const msg = "SessionRepository.Renew - json.Marshal > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	renewed, err := repo.handler.RenewSession(ctx, session.ID, value, session.ExpireAt, time.Until(session.ExpireAt))
+	if err != nil {
+		const msg = "SessionRepository.Renew - repo.handler.RenewSession > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	if !renewed {
+		const msg = "SessionRepository.Renew(%s) >"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, session.ID)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Renew(%s) - END", session.ID))
+	return session, nil
+}
+
+// Delete removes the session and releases all its locks, it returns the number of released locks.
+// It returns a *domain.NotFoundError if the session does not exist (anymore).
+func (repo *SessionRepository) Delete(ctx context.Context, id string) (int, error) {
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Delete(%s) - START", id))
+	released, err := repo.handler.DeleteSession(ctx, id)
+	if err != nil {
+		const msg = "SessionRepository.Delete - repo.handler.DeleteSession > %w"
+		return 0, fmt.Errorf(msg, err)
+	}
+	if released < 0 {
+		const msg = "SessionRepository.Delete(%s) >"
+		return 0, &domain.NotFoundError{Message: fmt.Sprintf(msg, id)}
+	}
+	repo.logger.Debug(fmt.Sprintf("SessionRepository.Delete(%s) - END", id))
+	return int(released), nil
+}
+
+// encodeSession returns the stored value of a session, its locks are kept by the handler.
+func encodeSession(session *domain.Session) (string, error) {
+	definition := *session
+	definition.Keys = nil
+	value, err := json.Marshal(definition)
+	return string(value), err
+}
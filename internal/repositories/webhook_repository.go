@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// webhookDeliveryLogSize is the number of deliveries kept in the log of a webhook.
+const webhookDeliveryLogSize = 100
+
+// WebhookStoreHandler is implemented by stores that can keep webhooks and their delivery logs.
+type WebhookStoreHandler interface {
+	// CreateWebhook stores a new webhook and reports whether the id was still unused.
+	CreateWebhook(ctx context.Context, id string, value string) (bool, error)
+	// GetWebhook returns the webhook, it is empty if the webhook does not exist.
+	GetWebhook(ctx context.Context, id string) (string, error)
+	ListWebhooks(ctx context.Context) ([]string, error)
+	// DeleteWebhook removes the webhook with its delivery log and reports whether it existed.
+	DeleteWebhook(ctx context.Context, id string) (bool, error)
+	// LogWebhookDelivery adds the delivery to the log of the webhook, it keeps the last size deliveries.
+	LogWebhookDelivery(ctx context.Context, id string, value string, size int64) error
+	// ListWebhookDeliveries returns the delivery log of the webhook, the latest delivery first.
+	ListWebhookDeliveries(ctx context.Context, id string) ([]string, error)
+}
+
+type WebhookRepository struct {
+	handler WebhookStoreHandler
+	logger  domain.Logger
+}
+
+func NewWebhookRepository(handler WebhookStoreHandler, logger domain.Logger) *WebhookRepository {
+	return &WebhookRepository{
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// Create stores a new webhook, it fails if the id is already used.
+func (repo *WebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Create(%s) - START", webhook.ID))
+	value, err := json.Marshal(webhook)
+	if err != nil {
+		const msg = "WebhookRepository.Create - json.Marshal > %w"
+		return fmt.Errorf(msg, err)
+	}
+	created, err := repo.handler.CreateWebhook(ctx, webhook.ID, string(value))
+	if err != nil {
+		const msg = "WebhookRepository.Create - repo.handler.CreateWebhook > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if !created {
+		const msg = "WebhookRepository.Create(%s) - id is already used"
+		return fmt.Errorf(msg, webhook.ID)
+	}
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Create(%s) - END", webhook.ID))
+	return nil
+}
+
+// Get returns the webhook or nil if it does not exist.
+func (repo *WebhookRepository) Get(ctx context.Context, id string) (*domain.Webhook, error) {
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Get(%s) - START", id))
+	value, err := repo.handler.GetWebhook(ctx, id)
+	if err != nil {
+		const msg = "WebhookRepository.Get - repo.handler.GetWebhook > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	var webhook domain.Webhook
+	if err := json.Unmarshal([]byte(value), &webhook); err != nil {
+		const msg = "WebhookRepository.Get - json.Unmarshal(%s) > %w"
+		return nil, fmt.Errorf(msg, id, err)
+	}
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Get(%s) - END", id))
+	return &webhook, nil
+}
+
+// List returns all registered webhooks.
+func (repo *WebhookRepository) List(ctx context.Context) ([]*domain.Webhook, error) {
+	repo.logger.Debug("WebhookRepository.List - START")
+	values, err := repo.handler.ListWebhooks(ctx)
+	if err != nil {
+		const msg = "WebhookRepository.List - repo.handler.ListWebhooks > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	webhooks := make([]*domain.Webhook, 0, len(values))
+	for _, value := range values {
+		var webhook domain.Webhook
+		if err := json.Unmarshal([]byte(value), &webhook); err != nil {
+			const msg = "WebhookRepository.List - json.Unmarshal > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	repo.logger.Debug("WebhookRepository.List - END")
+	return webhooks, nil
+}
+
+// Delete removes the webhook, it returns a *domain.NotFoundError if it does not exist.
+func (repo *WebhookRepository) Delete(ctx context.Context, id string) error {
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Delete(%s) - START", id))
+	deleted, err := repo.handler.DeleteWebhook(ctx, id)
+	if err != nil {
+		const msg = "WebhookRepository.Delete - repo.handler.DeleteWebhook > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if !deleted {
+		const msg = "WebhookRepository.Delete(%s) >"
+		return &domain.NotFoundError{Message: fmt.Sprintf(msg, id)}
+	}
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Delete(%s) - END", id))
+	return nil
+}
+
+// LogDelivery adds the delivery attempt to the log of its webhook.
+func (repo *WebhookRepository) LogDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		const msg = "WebhookRepository.LogDelivery - json.Marshal > %w"
+		return fmt.Errorf(msg, err)
+	}
+	if err := repo.handler.LogWebhookDelivery(ctx, delivery.WebhookID, string(value), webhookDeliveryLogSize); err != nil {
+		const msg = "WebhookRepository.LogDelivery - repo.handler.LogWebhookDelivery > %w"
+		return fmt.Errorf(msg, err)
+	}
+	return nil
+}
+
+// Deliveries returns the logged delivery attempts of the webhook, the latest first.
+func (repo *WebhookRepository) Deliveries(ctx context.Context, id string) ([]*domain.WebhookDelivery, error) {
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Deliveries(%s) - START", id))
+	values, err := repo.handler.ListWebhookDeliveries(ctx, id)
+	if err != nil {
+		const msg = "WebhookRepository.Deliveries - repo.handler.ListWebhookDeliveries > %w"
+		return nil, fmt.Errorf(msg, err)
+	}
+	deliveries := make([]*domain.WebhookDelivery, 0, len(values))
+	for _, value := range values {
+		var delivery domain.WebhookDelivery
+		if err := json.Unmarshal([]byte(value), &delivery); err != nil {
+			const msg = "WebhookRepository.Deliveries - json.Unmarshal > %w"
+			return nil, fmt.Errorf(msg, err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	repo.logger.Debug(fmt.Sprintf("WebhookRepository.Deliveries(%s) - END", id))
+	return deliveries, nil
+}
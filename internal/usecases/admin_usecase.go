@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// AdminUseCase handles administrative changes of locks that bypass the owner checks.
+type AdminUseCase struct {
+	lockRepo    domain.LockRepository
+	eventRepo   domain.EventRepository
+	historyRepo domain.HistoryRepository
+	tokens      []domain.AdminToken
+	logger      domain.Logger
+}
+
+// NewAdminUseCase creates a new AdminUseCase, without tokens all administrative changes are refused.
+func NewAdminUseCase(
+	lockRepo domain.LockRepository,
+	eventRepo domain.EventRepository,
+	historyRepo domain.HistoryRepository,
+	tokens []domain.AdminToken,
+	logger domain.Logger,
+) *AdminUseCase {
+	return &AdminUseCase{
+		lockRepo:    lockRepo,
+		eventRepo:   eventRepo,
+		historyRepo: historyRepo,
+		tokens:      tokens,
+		logger:      logger,
+	}
+}
+
+// Authorize checks the admin credential of a request, it returns the name of the credential
+// that is recorded as the actor of the changes made with it.
+func (uc *AdminUseCase) Authorize(credential string) (string, error) {
+	if len(uc.tokens) == 0 {
+		const msg = "AdminUseCase.Authorize - admin endpoints are disabled >"
+		return "", &domain.UnauthorizedError{Message: msg}
+	}
+	actor := ""
+	// every token is compared, so the time taken does not tell which one matched
+	for _, token := range uc.tokens {
+		if subtle.ConstantTimeCompare([]byte(credential), []byte(token.Token.Value)) == 1 && token.Token.Value != "" {
+			actor = token.Name
+		}
+	}
+	if actor == "" {
+		const msg = "AdminUseCase.Authorize - invalid credential >"
+		return "", &domain.UnauthorizedError{Message: msg}
+	}
+	return actor, nil
+}
+
+// TakeoverLock reassigns a held lock to a new owner with a new fencing token,
+// the previous holders are notified with a takeover event.
+func (uc *AdminUseCase) TakeoverLock(ctx context.Context, key string, input *domain.LockAdminInput) (*domain.Lock, error) {
+	uc.logger.Debug("AdminUseCase.TakeoverLock - START")
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil {
+		const msg = "AdminUseCase.TakeoverLock - time.ParseDuration > %s"
+		return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+
+	now := time.Now().UTC()
+	lock := &domain.Lock{
+		Key:       key,
+		Owner:     input.Owner,
+		Mode:      domain.LockModeExclusive,
+		Duration:  int64(duration.Seconds()),
+		CreatedAt: now,
+		ExpireAt:  now.Add(duration),
+		HoldCount: 1,
+	}
+	lock.Metadata = map[string]string{
+		"takenOverBy": input.Actor,
+		"takenOverAt": now.Format(time.RFC3339),
+		"reason":      input.Reason,
+	}
+
+	result, previous, err := uc.lockRepo.Takeover(ctx, lock, duration)
+	var notHeldErr *domain.LockNotHeldError
+	if errors.As(err, &notHeldErr) {
+		const msg = "AdminUseCase.TakeoverLock(%s) >"
+		return nil, &domain.LockNotHeldError{Message: fmt.Sprintf(msg, key)}
+	}
+	if err != nil {
+		const msg = "AdminUseCase.TakeoverLock - uc.lockRepo.Takeover > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.publish(ctx, &domain.LockEvent{
+		Type:           domain.LockEventTakeover,
+		Key:            key,
+		Owner:          input.Owner,
+		PreviousOwners: owners(previous),
+		Actor:          input.Actor,
+		Reason:         input.Reason,
+		At:             now,
+	})
+	const msg = "AdminUseCase.TakeoverLock - Lock %s taken over by %s for %s: %s"
+	uc.logger.Warn(fmt.Sprintf(msg, key, input.Actor, input.Owner, input.Reason))
+	uc.logger.Debug("AdminUseCase.TakeoverLock - END")
+	return result, nil
+}
+
+// ForceReleaseLock releases a held lock regardless of its owner,
+// the previous holders are notified with a force_released event.
+func (uc *AdminUseCase) ForceReleaseLock(ctx context.Context, key string, input *domain.LockAdminInput) error {
+	uc.logger.Debug("AdminUseCase.ForceReleaseLock - START")
+	previous, err := uc.lockRepo.ForceRelease(ctx, key)
+	var notHeldErr *domain.LockNotHeldError
+	if errors.As(err, &notHeldErr) {
+		const msg = "AdminUseCase.ForceReleaseLock(%s) >"
+		return &domain.LockNotHeldError{Message: fmt.Sprintf(msg, key)}
+	}
+	if err != nil {
+		const msg = "AdminUseCase.ForceReleaseLock - uc.lockRepo.ForceRelease > %s"
+		return &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.publish(ctx, &domain.LockEvent{
+		Type:           domain.LockEventForceReleased,
+		Key:            key,
+		PreviousOwners: owners(previous),
+		Actor:          input.Actor,
+		Reason:         input.Reason,
+		At:             time.Now().UTC(),
+	})
+	const msg = "AdminUseCase.ForceReleaseLock - Lock %s force released by %s: %s"
+	uc.logger.Warn(fmt.Sprintf(msg, key, input.Actor, input.Reason))
+	uc.logger.Debug("AdminUseCase.ForceReleaseLock - END")
+	return nil
+}
+
+// publish sends the event and adds it to the history of the lock,
+// the change happened already so failures are only logged.
+func (uc *AdminUseCase) publish(ctx context.Context, event *domain.LockEvent) {
+	if err := uc.eventRepo.Publish(ctx, event); err != nil {
+		const msg = "AdminUseCase.publish - uc.eventRepo.Publish > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+	if err := uc.historyRepo.Append(ctx, historyEntry(ctx, event)); err != nil {
+		const msg = "AdminUseCase.publish - uc.historyRepo.Append > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+}
+
+// owners returns the owners of the given holders.
+func owners(holders []*domain.Lock) []string {
+	result := make([]string, 0, len(holders))
+	for _, holder := range holders {
+		result = append(result, holder.Owner)
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+)
+
+const testAdminToken = "secret"
+
+// testAdminTokens are the admin credentials of the tests, testAdminToken is the one of the actor "admin".
+var testAdminTokens = []domain.AdminToken{{Name: "ops", Token: domain.Secret{Value: "other-secret"}}, {Name: "admin", Token: domain.Secret{Value: testAdminToken}}}
+
+func TestAuthorize(t *testing.T) {
+	mockLogger := infrastructure.NewMockLogger()
+
+	t.Run("Valid", func(t *testing.T) {
+		uc := NewAdminUseCase(new(repositories.MockLockRepository), new(repositories.MockEventRepository), new(repositories.MockHistoryRepository), testAdminTokens, mockLogger)
+		actor, err := uc.Authorize(testAdminToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", actor)
+	})
+
+	t.Run("Named", func(t *testing.T) {
+		uc := NewAdminUseCase(new(repositories.MockLockRepository), new(repositories.MockEventRepository), new(repositories.MockHistoryRepository), testAdminTokens, mockLogger)
+		actor, err := uc.Authorize("other-secret")
+		assert.NoError(t, err)
+		assert.Equal(t, "ops", actor)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		uc := NewAdminUseCase(new(repositories.MockLockRepository), new(repositories.MockEventRepository), new(repositories.MockHistoryRepository), testAdminTokens, mockLogger)
+		_, err := uc.Authorize("guess")
+		assert.IsType(t, &domain.UnauthorizedError{}, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		uc := NewAdminUseCase(new(repositories.MockLockRepository), new(repositories.MockEventRepository), new(repositories.MockHistoryRepository), nil, mockLogger)
+		_, err := uc.Authorize("")
+		assert.IsType(t, &domain.UnauthorizedError{}, err)
+	})
+}
+
+func TestTakeoverLockSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockEvents := new(repositories.MockEventRepository)
+	var stored *domain.Lock
+	mockRepo.On("Takeover", mock.Anything, mock.AnythingOfType("*domain.Lock"), time.Hour).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.Lock) }).
+		Return(&domain.Lock{Key: testKeyValue, Owner: "new-owner", FencingToken: 2}, []*domain.Lock{testLock}, nil)
+	isTakeover := mock.MatchedBy(func(event *domain.LockEvent) bool {
+		return event.Type == domain.LockEventTakeover && event.Owner == "new-owner" &&
+			event.PreviousOwners[0] == testOwnerValue && event.Actor == "admin" && event.Reason == "crashed"
+	})
+	mockEvents.On("Publish", mock.Anything, isTakeover).Return(nil)
+
+	uc := NewAdminUseCase(mockRepo, mockEvents, newMockHistoryRepository(), testAdminTokens, mockLogger)
+
+	input := &domain.LockAdminInput{Owner: "new-owner", Duration: "1h", Actor: "admin", Reason: "crashed"}
+
+	// Act
+	result, err := uc.TakeoverLock(context.Background(), testKeyValue, input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	mockEvents.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.FencingToken)
+	assert.Equal(t, "admin", stored.Metadata["takenOverBy"])
+	assert.Equal(t, "crashed", stored.Metadata["reason"])
+}
+
+func TestTakeoverLockNotHeld(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockEvents := new(repositories.MockEventRepository)
+	mockRepo.On("Takeover", mock.Anything, mock.Anything, time.Hour).
+		Return(nil, nil, &domain.LockNotHeldError{Message: testKeyValue})
+
+	uc := NewAdminUseCase(mockRepo, mockEvents, newMockHistoryRepository(), testAdminTokens, mockLogger)
+
+	input := &domain.LockAdminInput{Owner: "new-owner", Duration: "1h", Actor: "admin", Reason: "crashed"}
+
+	// Act
+	result, err := uc.TakeoverLock(context.Background(), testKeyValue, input)
+
+	// Assert
+	mockEvents.AssertNotCalled(t, "Publish")
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.LockNotHeldError{}, err)
+}
+
+func TestForceReleaseLockPublishFailure(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockEvents := new(repositories.MockEventRepository)
+	mockRepo.On("ForceRelease", mock.Anything, testKeyValue).Return([]*domain.Lock{testLock}, nil)
+	isForceReleased := mock.MatchedBy(func(event *domain.LockEvent) bool {
+		return event.Type == domain.LockEventForceReleased && event.PreviousOwners[0] == testOwnerValue
+	})
+	mockEvents.On("Publish", mock.Anything, isForceReleased).Return(errors.New("connection refused"))
+
+	uc := NewAdminUseCase(mockRepo, mockEvents, newMockHistoryRepository(), testAdminTokens, mockLogger)
+
+	// Act
+	err := uc.ForceReleaseLock(context.Background(), testKeyValue, &domain.LockAdminInput{Actor: "admin", Reason: "stuck"})
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	mockEvents.AssertExpectations(t)
+	assert.NoError(t, err)
+}
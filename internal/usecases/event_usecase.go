@@ -0,0 +1,49 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// EventUseCase handles the business logic for the lock event stream.
+type EventUseCase struct {
+	eventRepo domain.EventRepository
+	logger    domain.Logger
+}
+
+// NewEventUseCase creates a new EventUseCase with the given repository and logger.
+func NewEventUseCase(eventRepo domain.EventRepository, logger domain.Logger) *EventUseCase {
+	return &EventUseCase{
+		eventRepo: eventRepo,
+		logger:    logger,
+	}
+}
+
+// StreamEvents streams the lock events that match the filter until ctx is done.
+func (uc *EventUseCase) StreamEvents(ctx context.Context, filter *domain.LockEventFilter) (<-chan *domain.LockEvent, error) {
+	uc.logger.Debug("EventUseCase.StreamEvents - START")
+	events, err := uc.eventRepo.Subscribe(ctx, filter.KeyPrefix)
+	if err != nil {
+		const msg = "EventUseCase.StreamEvents - uc.eventRepo.Subscribe > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+
+	matching := make(chan *domain.LockEvent)
+	go func() {
+		defer close(matching)
+		for event := range events {
+			if !filter.Matches(event) {
+				continue
+			}
+			select {
+			case matching <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	uc.logger.Debug("EventUseCase.StreamEvents - END")
+	return matching, nil
+}
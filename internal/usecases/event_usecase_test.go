@@ -0,0 +1,56 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+)
+
+func TestStreamEventsFiltersOwner(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockEvents := new(repositories.MockEventRepository)
+	published := make(chan *domain.LockEvent, 4)
+	published <- &domain.LockEvent{Type: domain.LockEventAcquired, Key: "jobs/a", Owner: "other"}
+	published <- &domain.LockEvent{Type: domain.LockEventAcquired, Key: "jobs/b", Owner: testOwnerValue}
+	published <- &domain.LockEvent{Type: domain.LockEventReleased, Key: "jobs/b", PreviousOwners: []string{testOwnerValue}}
+	published <- &domain.LockEvent{Type: domain.LockEventExpired, Key: "jobs/c"}
+	close(published)
+	mockEvents.On("Subscribe", mock.Anything, "jobs/").Return((<-chan *domain.LockEvent)(published), nil)
+
+	uc := NewEventUseCase(mockEvents, mockLogger)
+
+	// Act
+	events, err := uc.StreamEvents(context.Background(), &domain.LockEventFilter{KeyPrefix: "jobs/", Owner: testOwnerValue})
+
+	// Assert
+	assert.NoError(t, err)
+	var types []string
+	for event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{domain.LockEventAcquired, domain.LockEventReleased}, types)
+}
+
+func TestStreamEventsSubscribeError(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockEvents := new(repositories.MockEventRepository)
+	mockEvents.On("Subscribe", mock.Anything, "").Return(nil, errors.New("connection refused"))
+
+	uc := NewEventUseCase(mockEvents, mockLogger)
+
+	// Act
+	events, err := uc.StreamEvents(context.Background(), &domain.LockEventFilter{})
+
+	// Assert
+	assert.Nil(t, events)
+	assert.IsType(t, &domain.InternalError{}, err)
+}
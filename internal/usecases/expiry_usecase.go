@@ -0,0 +1,210 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// expiryClaimTTL is the time an expired lock is claimed by the replica that reports it
+const expiryClaimTTL = time.Minute
+
+// ExpiryUseCase reports the locks that expired without being released, every expiry is logged
+// with the last owner of the lock, counted and added to the history of the lock once across all replicas.
+// The store only notices a lock expiring as a whole, a shared holder whose lease ended while others still
+// hold the lock is watched by every replica from the events of the lock and published as an expired event
+// by one of them. Holders acquired while no replica runs are not watched.
+type ExpiryUseCase struct {
+	lockRepo    domain.LockRepository
+	eventRepo   domain.EventRepository
+	historyRepo domain.HistoryRepository
+	metrics     domain.MetricsRecorder
+	namespaces  []*domain.Namespace
+	logger      domain.Logger
+	// mu guards the lapses of the watched shared holders
+	mu     sync.Mutex
+	lapses map[lapseID]*lapse
+}
+
+// lapseID identifies a shared holder of a lock in a namespace.
+type lapseID struct {
+	namespace string
+	key       string
+	owner     string
+}
+
+// lapse checks on a shared holder once its lease ended at expireAt.
+type lapse struct {
+	timer    *time.Timer
+	expireAt time.Time
+}
+
+// NewExpiryUseCase creates a new ExpiryUseCase that watches the locks of the given namespaces.
+func NewExpiryUseCase(
+	lockRepo domain.LockRepository,
+	eventRepo domain.EventRepository,
+	historyRepo domain.HistoryRepository,
+	metrics domain.MetricsRecorder,
+	namespaces []*domain.Namespace,
+	logger domain.Logger,
+) *ExpiryUseCase {
+	return &ExpiryUseCase{
+		lockRepo:    lockRepo,
+		eventRepo:   eventRepo,
+		historyRepo: historyRepo,
+		metrics:     metrics,
+		namespaces:  namespaces,
+		logger:      logger,
+		lapses:      map[lapseID]*lapse{},
+	}
+}
+
+// Start subscribes to the lock events of all namespaces and reports the expired locks until ctx is done.
+func (uc *ExpiryUseCase) Start(ctx context.Context) error {
+	for _, ns := range uc.namespaces {
+		events, err := uc.eventRepo.Subscribe(domain.WithNamespace(ctx, ns), "")
+		if err != nil {
+			const msg = "ExpiryUseCase.Start - uc.eventRepo.Subscribe(%s) > %w"
+			return fmt.Errorf(msg, ns.Name, err)
+		}
+		go func(ns *domain.Namespace) {
+			for event := range events {
+				if event.Type == domain.LockEventExpired {
+					uc.report(domain.WithNamespace(ctx, ns), event)
+				}
+				uc.watch(domain.WithNamespace(ctx, ns), event.Key)
+			}
+		}(ns)
+	}
+	go func() {
+		<-ctx.Done()
+		uc.mu.Lock()
+		defer uc.mu.Unlock()
+		for id, lapse := range uc.lapses {
+			lapse.timer.Stop()
+			delete(uc.lapses, id)
+		}
+	}()
+	return nil
+}
+
+// watch checks on every shared holder of the lock once its lease ends,
+// holders that are gone since the last change of the lock are no longer watched.
+func (uc *ExpiryUseCase) watch(ctx context.Context, key string) {
+	holders, err := uc.lockRepo.Get(ctx, key)
+	if err != nil {
+		const msg = "ExpiryUseCase.watch - uc.lockRepo.Get > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	namespace := domain.NamespaceFromContext(ctx).Name
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	held := map[string]bool{}
+	for _, holder := range holders {
+		if holder.Mode == domain.LockModeShared {
+			held[holder.Owner] = true
+			uc.schedule(ctx, lapseID{namespace: namespace, key: key, owner: holder.Owner}, holder)
+		}
+	}
+	for id, lapse := range uc.lapses {
+		if id.namespace == namespace && id.key == key && !held[id.owner] {
+			lapse.timer.Stop()
+			delete(uc.lapses, id)
+		}
+	}
+}
+
+// schedule checks on the shared holder once its lease ends, uc.mu must be held.
+func (uc *ExpiryUseCase) schedule(ctx context.Context, id lapseID, holder *domain.Lock) {
+	if scheduled, ok := uc.lapses[id]; ok {
+		if scheduled.expireAt.Equal(holder.ExpireAt) {
+			return
+		}
+		scheduled.timer.Stop()
+	}
+	scheduled := &lapse{expireAt: holder.ExpireAt}
+	scheduled.timer = time.AfterFunc(time.Until(holder.ExpireAt), func() { uc.lapsed(ctx, id, scheduled, holder) })
+	uc.lapses[id] = scheduled
+}
+
+// lapsed publishes the expired event of the shared holder if its lease ended while others still hold the lock,
+// unless another replica claimed it already. A lock without holders expired as a whole and is reported by the store.
+func (uc *ExpiryUseCase) lapsed(ctx context.Context, id lapseID, scheduled *lapse, holder *domain.Lock) {
+	uc.mu.Lock()
+	current := uc.lapses[id] == scheduled
+	if current {
+		delete(uc.lapses, id)
+	}
+	uc.mu.Unlock()
+	if !current || ctx.Err() != nil {
+		return
+	}
+	holders, err := uc.lockRepo.Get(ctx, id.key)
+	if err != nil {
+		const msg = "ExpiryUseCase.lapsed - uc.lockRepo.Get > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	if len(holders) == 0 {
+		return
+	}
+	for _, renewed := range holders {
+		if renewed.Owner == id.owner {
+			uc.mu.Lock()
+			uc.schedule(ctx, id, renewed)
+			uc.mu.Unlock()
+			return
+		}
+	}
+	event := &domain.LockEvent{
+		Type:           domain.LockEventExpired,
+		Key:            id.key,
+		PreviousOwners: []string{id.owner},
+		FencingToken:   holder.FencingToken,
+		At:             holder.ExpireAt,
+	}
+	claimed, err := uc.eventRepo.Claim(ctx, eventID("lapse", id.namespace, event), expiryClaimTTL)
+	if err != nil {
+		const msg = "ExpiryUseCase.lapsed - uc.eventRepo.Claim > %s"
+		uc.logger.Error(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	if !claimed {
+		return
+	}
+	// the event reaches the subscribers of every replica, the expiry is reported from there
+	if err := uc.eventRepo.Publish(ctx, event); err != nil {
+		const msg = "ExpiryUseCase.lapsed - uc.eventRepo.Publish > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+}
+
+// report logs, counts and records the expired lock, unless another replica claimed its report already.
+func (uc *ExpiryUseCase) report(ctx context.Context, event *domain.LockEvent) {
+	namespace := domain.NamespaceFromContext(ctx).Name
+	claimed, err := uc.eventRepo.Claim(ctx, eventID("expiry", namespace, event), expiryClaimTTL)
+	if err != nil {
+		const msg = "ExpiryUseCase.report - uc.eventRepo.Claim > %s"
+		uc.logger.Error(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	if !claimed {
+		return
+	}
+	owner := "an unknown owner"
+	if len(event.PreviousOwners) > 0 {
+		owner = strings.Join(event.PreviousOwners, ", ")
+	}
+	const msg = "ExpiryUseCase.report - lock %s in namespace %s expired, held by %s"
+	uc.logger.Warn(fmt.Sprintf(msg, event.Key, namespace, owner))
+	uc.metrics.IncrementExpiredLocks(namespace)
+	if err := uc.historyRepo.Append(ctx, historyEntry(ctx, event)); err != nil {
+		const msg = "ExpiryUseCase.report - uc.historyRepo.Append > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+}
@@ -0,0 +1,130 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+)
+
+func TestExpiryCountsExpiredLocks(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockEvents := new(repositories.MockEventRepository)
+	published := make(chan *domain.LockEvent, 2)
+	published <- &domain.LockEvent{Type: domain.LockEventReleased, Key: "jobs/a", PreviousOwners: []string{testOwnerValue}}
+	published <- &domain.LockEvent{Type: domain.LockEventExpired, Key: "jobs/b", PreviousOwners: []string{testOwnerValue}, FencingToken: 7}
+	close(published)
+	mockEvents.On("Subscribe", mock.Anything, "").Return((<-chan *domain.LockEvent)(published), nil)
+	mockEvents.On("Claim", mock.Anything, mock.Anything, expiryClaimTTL).Return(true, nil)
+	mockMetrics := new(infrastructure.MockMetricsRecorder)
+	mockMetrics.On("IncrementExpiredLocks", domain.DefaultNamespace).Return()
+	mockHistory := new(repositories.MockHistoryRepository)
+	recorded := make(chan *domain.LockHistoryEntry, 1)
+	mockHistory.On("Append", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { recorded <- args.Get(1).(*domain.LockHistoryEntry) }).
+		Return(nil)
+
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+
+	uc := NewExpiryUseCase(mockRepo, mockEvents, mockHistory, mockMetrics, testNamespaces, mockLogger)
+
+	// Act
+	err := uc.Start(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case entry := <-recorded:
+		assert.Equal(t, "jobs/b", entry.Key)
+		assert.Equal(t, []string{testOwnerValue}, entry.PreviousOwners)
+	case <-time.After(time.Second):
+		t.Fatal("expired lock was not recorded")
+	}
+	mockEvents.AssertNumberOfCalls(t, "Claim", 1)
+	mockMetrics.AssertExpectations(t)
+}
+
+func TestExpiryClaimedByAnotherReplica(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockEvents := new(repositories.MockEventRepository)
+	mockEvents.On("Claim", mock.Anything, mock.Anything, expiryClaimTTL).Return(false, nil)
+	mockMetrics := new(infrastructure.MockMetricsRecorder)
+	mockHistory := new(repositories.MockHistoryRepository)
+
+	uc := NewExpiryUseCase(new(repositories.MockLockRepository), mockEvents, mockHistory, mockMetrics, testNamespaces, mockLogger)
+
+	// Act
+	uc.report(context.Background(), &domain.LockEvent{Type: domain.LockEventExpired, Key: "jobs/b", FencingToken: 7})
+
+	// Assert
+	mockEvents.AssertExpectations(t)
+	mockMetrics.AssertNotCalled(t, "IncrementExpiredLocks", mock.Anything)
+	mockHistory.AssertNotCalled(t, "Append", mock.Anything, mock.Anything)
+}
+
+func TestExpirySubscribeError(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockEvents := new(repositories.MockEventRepository)
+	mockEvents.On("Subscribe", mock.Anything, "").Return(nil, errors.New("connection refused"))
+
+	uc := NewExpiryUseCase(new(repositories.MockLockRepository), mockEvents, new(repositories.MockHistoryRepository), new(infrastructure.MockMetricsRecorder), testNamespaces, mockLogger)
+
+	// Act
+	err := uc.Start(context.Background())
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestExpiryPublishesLapsedSharedHolder(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	now := time.Now().UTC()
+	lapsing := &domain.Lock{Key: "jobs/a", Owner: "reader-1", Mode: domain.LockModeShared, FencingToken: 3, ExpireAt: now.Add(20 * time.Millisecond)}
+	staying := &domain.Lock{Key: "jobs/a", Owner: "reader-2", Mode: domain.LockModeShared, FencingToken: 4, ExpireAt: now.Add(time.Hour)}
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Get", mock.Anything, "jobs/a").Return([]*domain.Lock{lapsing, staying}, nil).Once()
+	mockRepo.On("Get", mock.Anything, "jobs/a").Return([]*domain.Lock{staying}, nil)
+	mockEvents := new(repositories.MockEventRepository)
+	published := make(chan *domain.LockEvent, 1)
+	published <- &domain.LockEvent{Type: domain.LockEventAcquired, Key: "jobs/a", Owner: "reader-2", FencingToken: 4}
+	mockEvents.On("Subscribe", mock.Anything, "").Return((<-chan *domain.LockEvent)(published), nil)
+	mockEvents.On("Claim", mock.Anything, mock.Anything, expiryClaimTTL).Return(true, nil)
+	lapsed := make(chan *domain.LockEvent, 1)
+	mockEvents.On("Publish", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { lapsed <- args.Get(1).(*domain.LockEvent) }).
+		Return(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	uc := NewExpiryUseCase(mockRepo, mockEvents, new(repositories.MockHistoryRepository), new(infrastructure.MockMetricsRecorder), testNamespaces, mockLogger)
+
+	// Act
+	err := uc.Start(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case event := <-lapsed:
+		assert.Equal(t, domain.LockEventExpired, event.Type)
+		assert.Equal(t, "jobs/a", event.Key)
+		assert.Equal(t, []string{"reader-1"}, event.PreviousOwners)
+		assert.Equal(t, int64(3), event.FencingToken)
+	case <-time.After(time.Second):
+		t.Fatal("lapsed holder was not published")
+	}
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	assert.Len(t, uc.lapses, 1)
+}
@@ -2,8 +2,11 @@
 package usecases
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/tyriis/go-locking-service/internal/domain"
@@ -11,102 +14,508 @@ import (
 
 // LockUseCase handles the business logic for lock management.
 type LockUseCase struct {
-	lockRepo domain.LockRepository
-	logger   domain.Logger
+	lockRepo    domain.LockRepository
+	eventRepo   domain.EventRepository
+	historyRepo domain.HistoryRepository
+	logger      domain.Logger
 }
 
-// NewLockUseCase creates a new LockUseCase with the given repository and logger.
-func NewLockUseCase(lockRepo domain.LockRepository, logger domain.Logger) *LockUseCase {
+// NewLockUseCase creates a new LockUseCase with the given repositories and logger,
+// the changes of the locks are published to the event repository and kept in their history.
+func NewLockUseCase(lockRepo domain.LockRepository, eventRepo domain.EventRepository, historyRepo domain.HistoryRepository, logger domain.Logger) *LockUseCase {
 	return &LockUseCase{
-		lockRepo: lockRepo,
-		logger:   logger,
+		lockRepo:    lockRepo,
+		eventRepo:   eventRepo,
+		historyRepo: historyRepo,
+		logger:      logger,
 	}
 }
 
+// waitRecheckInterval is the minimal time a waiting CreateLock sleeps before it tries again
+// when it was not notified about a change of the held lock.
+const waitRecheckInterval = 100 * time.Millisecond
+
+// queueLease is how long a waiter keeps its place in the wait queue of a lock without renewing it,
+// so a waiter that went away holds up the owners queued behind it at most this long.
+// Waiting requests renew their place every queueRenewInterval.
+const (
+	queueLease         = 5 * time.Second
+	queueRenewInterval = queueLease / 3
+)
+
 // CreateLock creates a new lock if it doesn't exist.
-func (uc *LockUseCase) CreateLock(lockInput *domain.LockInput) (*domain.Lock, error) {
+// The acquisition is atomic, concurrent calls for the same key can only succeed once.
+// If the input has a wait duration, a held lock is awaited up to this long before a conflict is returned.
+func (uc *LockUseCase) CreateLock(ctx context.Context, lockInput *domain.LockInput) (*domain.Lock, error) {
 	uc.logger.Debug("LockUseCase.CreateLock - START")
-	// Check if lock exists
-	existingLock, _ := uc.lockRepo.Get(lockInput.Key)
-	if existingLock != nil {
+	// Parse duration, a lock of a session lives as long as the session without one
+	var duration time.Duration
+	var err error
+	if lockInput.SessionID == "" || lockInput.Duration != "" {
+		duration, err = time.ParseDuration(lockInput.Duration)
+		if err != nil {
+			const msg = "LockUseCase.CreateLock - time.ParseDuration > %s"
+			return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
+		}
+	}
+
+	// Parse optional wait
+	var wait time.Duration
+	if lockInput.Wait != "" {
+		wait, err = time.ParseDuration(lockInput.Wait)
+		if err != nil {
+			const msg = "LockUseCase.CreateLock - time.ParseDuration > %s"
+			return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
+		}
+	}
+
+	// Enforce the policy of the namespace
+	ns := domain.NamespaceFromContext(ctx)
+	if err := ns.CheckDuration(duration); err != nil {
+		return nil, err
+	}
+	if err := ns.CheckWait(wait); err != nil {
+		return nil, err
+	}
+
+	var result *domain.Lock
+	if wait > 0 {
+		result, err = uc.acquireWaiting(ctx, lockInput, duration, wait)
+	} else {
+		result, err = uc.acquire(ctx, lockInput, duration)
+	}
+	var conflictErr *domain.LockConflictError
+	if errors.As(err, &conflictErr) {
 		const msg = "LockUseCase.CreateLock(%s) >"
 		return nil, &domain.LockConflictError{Message: fmt.Sprintf(msg, lockInput.Key)}
 	}
+	var notFoundErr *domain.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		const msg = "LockUseCase.CreateLock(%s) > session %s"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, lockInput.Key, lockInput.SessionID)}
+	}
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return nil, validationErr
+	}
+	if err != nil {
+		const msg = "LockUseCase.CreateLock - uc.acquire > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.publish(ctx, lockEvent(domain.LockEventAcquired, result))
+	const msg = "LockUseCase.CreateLock - Lock created > %s"
+	uc.logger.Info(fmt.Sprintf(msg, lockInput.Key))
+	uc.logger.Debug("LockUseCase.CreateLock - END")
+	return result, nil
+}
 
-	// Parse duration
-	duration, err := time.ParseDuration(lockInput.Duration)
+// CreateLocks acquires the locks of all keys for one owner at once, or none of them.
+// A *domain.LockConflictError names the keys that are held.
+func (uc *LockUseCase) CreateLocks(ctx context.Context, batchInput *domain.LockBatchInput) ([]*domain.Lock, error) {
+	uc.logger.Debug("LockUseCase.CreateLocks - START")
+	duration, err := time.ParseDuration(batchInput.Duration)
 	if err != nil {
-		const msg = "LockUseCase.CreateLock - time.ParseDuration > %s"
+		const msg = "LockUseCase.CreateLocks - time.ParseDuration > %s"
 		return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
 	}
+	if err := domain.NamespaceFromContext(ctx).CheckDuration(duration); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	locks := make([]*domain.Lock, 0, len(batchInput.Keys))
+	for _, key := range batchInput.Keys {
+		locks = append(locks, &domain.Lock{
+			Key:       key,
+			Owner:     batchInput.Owner,
+			Mode:      domain.LockModeExclusive,
+			Duration:  int64(duration.Seconds()),
+			CreatedAt: now,
+			ExpireAt:  now.Add(duration),
+			HoldCount: 1,
+		})
+	}
+
+	result, err := uc.lockRepo.SetMultiple(ctx, locks)
+	var conflictErr *domain.LockConflictError
+	if errors.As(err, &conflictErr) {
+		const msg = "LockUseCase.CreateLocks(%s) >"
+		return nil, &domain.LockConflictError{
+			Message: fmt.Sprintf(msg, strings.Join(conflictErr.Keys, ", ")),
+			Keys:    conflictErr.Keys,
+		}
+	}
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return nil, validationErr
+	}
+	if err != nil {
+		const msg = "LockUseCase.CreateLocks - uc.lockRepo.SetMultiple > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	for _, lock := range result {
+		uc.publish(ctx, lockEvent(domain.LockEventAcquired, lock))
+	}
+	const msg = "LockUseCase.CreateLocks - Locks created > %s"
+	uc.logger.Info(fmt.Sprintf(msg, strings.Join(batchInput.Keys, ", ")))
+	uc.logger.Debug("LockUseCase.CreateLocks - END")
+	return result, nil
+}
+
+// acquire tries to set the lock once, it returns a *domain.LockConflictError if the lock is held.
+func (uc *LockUseCase) acquire(ctx context.Context, lockInput *domain.LockInput, duration time.Duration) (*domain.Lock, error) {
+	mode := lockInput.Mode
+	if mode == "" {
+		mode = domain.LockModeExclusive
+	}
 
 	// Create lock structure
 	now := time.Now().UTC()
 	lock := &domain.Lock{
 		Key:       lockInput.Key,
 		Owner:     lockInput.Owner,
+		Mode:      mode,
 		Duration:  int64(duration.Seconds()),
 		CreatedAt: now,
 		ExpireAt:  now.Add(duration),
+		Reentrant: lockInput.Reentrant,
+		HoldCount: 1,
+		SessionID: lockInput.SessionID,
 	}
 
 	// Convert to JSON
 	lockValue, err := json.Marshal(lock)
 	if err != nil {
-		const msg = "LockUseCase.CreateLock - json.Marshal > %s"
-		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+		const msg = "LockUseCase.acquire - json.Marshal > %w"
+		return nil, fmt.Errorf(msg, err)
 	}
 
-	// Set lock
-	result, err := uc.lockRepo.Set(lock.Key, string(lockValue), duration)
+	// Set lock, only succeeds if the key is not held or, for a reentrant lock, held by the same owner
+	return uc.lockRepo.Set(ctx, lock.Key, string(lockValue), duration)
+}
+
+// acquireWaiting tries to set the lock until it succeeds or wait passed.
+// While the lock is held the owner waits in the queue of the lock, so waiters are served in arrival order,
+// and renews its place there until it acquired the lock or gave up.
+// It is woken up by changes of the held lock or the queue and, as a fallback, when the held lock is expected to expire.
+func (uc *LockUseCase) acquireWaiting(ctx context.Context, lockInput *domain.LockInput, duration time.Duration, wait time.Duration) (*domain.Lock, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	// watch before the first attempt, so a release right after it can't be missed
+	changes, err := uc.lockRepo.Watch(ctx, lockInput.Key)
 	if err != nil {
-		const msg = "LockUseCase.CreateLock - uc.lockRepo.Set > %s"
-		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+		return nil, waitError(ctx, lockInput.Key, err)
+	}
+
+	queued := false
+	var renewAt time.Time
+	for {
+		lock, err := uc.acquire(ctx, lockInput, duration)
+		var conflictErr *domain.LockConflictError
+		if !errors.As(err, &conflictErr) {
+			// a successful acquisition removes the owner from the queue already
+			if err != nil && queued {
+				uc.leaveQueue(context.WithoutCancel(ctx), lockInput.Key, lockInput.Owner)
+			}
+			if err != nil {
+				return nil, waitError(ctx, lockInput.Key, err)
+			}
+			return lock, nil
+		}
+
+		if now := time.Now(); !queued || !now.Before(renewAt) {
+			// the place in the queue lasts for queueLease unless it is renewed, at most until the wait passed
+			deadline, _ := ctx.Deadline()
+			if lease := now.Add(queueLease); lease.Before(deadline) {
+				deadline = lease
+			}
+			position, err := uc.lockRepo.Enqueue(ctx, lockInput.Key, lockInput.Owner, deadline)
+			if err != nil {
+				return nil, waitError(ctx, lockInput.Key, err)
+			}
+			renewAt = now.Add(queueRenewInterval)
+			if !queued {
+				queued = true
+				const msg = "LockUseCase.acquireWaiting(%s) - %s queued at position %d"
+				uc.logger.Debug(fmt.Sprintf(msg, lockInput.Key, lockInput.Owner, position))
+				// the queue may have changed our chances, try again right away
+				continue
+			}
+		}
+
+		// shared holders expire individually without a notification, wake up when the first of them expires
+		recheck := waitRecheckInterval
+		held, err := uc.lockRepo.Get(ctx, lockInput.Key)
+		if err == nil && len(held) > 0 {
+			first := time.Until(held[0].ExpireAt)
+			for _, holder := range held[1:] {
+				if d := time.Until(holder.ExpireAt); d < first {
+					first = d
+				}
+			}
+			if first > recheck {
+				recheck = first
+			}
+		}
+		if until := time.Until(renewAt); recheck > until {
+			recheck = until
+		}
+
+		const msg = "LockUseCase.acquireWaiting(%s) - lock is held, waiting up to %s"
+		uc.logger.Debug(fmt.Sprintf(msg, lockInput.Key, recheck))
+		timer := time.NewTimer(recheck)
+		select {
+		case <-changes:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			uc.leaveQueue(context.WithoutCancel(ctx), lockInput.Key, lockInput.Owner)
+			return nil, conflictErr
+		}
+		timer.Stop()
 	}
-	const msg = "LockUseCase.CreateLock - Lock created > %s"
-	uc.logger.Info(fmt.Sprintf(msg, lockInput.Key))
-	uc.logger.Debug("LockUseCase.CreateLock - END")
-	return result, nil
 }
 
-// DeleteLock removes an existing lock.
-func (uc *LockUseCase) DeleteLock(key string) error {
+// waitError returns a *domain.LockConflictError for an error of the store that occurred because the wait
+// passed, the store calls fail with the deadline of ctx then, other errors are returned as they are.
+func waitError(ctx context.Context, key string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		const msg = "LockUseCase.acquireWaiting(%s) > wait passed: %s"
+		return &domain.LockConflictError{Message: fmt.Sprintf(msg, key, err.Error())}
+	}
+	return err
+}
+
+// leaveQueue removes the owner from the queue of the lock, failures are only logged
+// as waiters are dropped from the queue anyway once their deadline passed.
+func (uc *LockUseCase) leaveQueue(ctx context.Context, key string, owner string) {
+	if err := uc.lockRepo.Dequeue(ctx, key, owner); err != nil {
+		const msg = "LockUseCase.leaveQueue - uc.lockRepo.Dequeue > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+}
+
+// DeleteLock releases an existing lock, it must be held by the given owner.
+// A reentrant lock is only freed by the release of its last hold.
+func (uc *LockUseCase) DeleteLock(ctx context.Context, key string, owner string) error {
 	uc.logger.Debug("LockUseCase.DeleteLock - START")
 	if key == "" {
 		const msg = "LockUseCase.DeleteLock - key is empty >"
 		return &domain.InputError{Message: msg}
 	}
-	err := uc.lockRepo.Del(key)
+	if owner == "" {
+		const msg = "LockUseCase.DeleteLock - owner is empty >"
+		return &domain.InputError{Message: msg}
+	}
+	err := uc.lockRepo.Release(ctx, key, owner)
+	if ownerErr := ownerError("DeleteLock", key, err); ownerErr != nil {
+		return ownerErr
+	}
 	if err != nil {
-		const msg = "LockUseCase.DeleteLock - uc.lockRepo.Del > %s"
+		const msg = "LockUseCase.DeleteLock - uc.lockRepo.Release > %s"
 		return &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
 	}
-	const msg = "LockUseCase.DeleteLock - Lock deleted: %s"
+	uc.publish(ctx, &domain.LockEvent{
+		Type:           domain.LockEventReleased,
+		Key:            key,
+		PreviousOwners: []string{owner},
+		At:             time.Now().UTC(),
+	})
+	const msg = "LockUseCase.DeleteLock - Lock released: %s"
 	uc.logger.Info(fmt.Sprintf(msg, key))
 	uc.logger.Debug("LockUseCase.DeleteLock - END")
 	return nil
 }
 
-// GetLock retrieves a specific lock by key.
-func (uc *LockUseCase) GetLock(key string) (*domain.Lock, error) {
+// RenewLock extends the TTL of a lock held by the given owner, the lock expires the given duration from now.
+func (uc *LockUseCase) RenewLock(ctx context.Context, key string, renewInput *domain.LockRenewInput) (*domain.Lock, error) {
+	uc.logger.Debug("LockUseCase.RenewLock - START")
+	if key == "" {
+		const msg = "LockUseCase.RenewLock - key is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	if renewInput.Owner == "" {
+		const msg = "LockUseCase.RenewLock - owner is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	duration, err := time.ParseDuration(renewInput.Duration)
+	if err != nil {
+		const msg = "LockUseCase.RenewLock - time.ParseDuration > %s"
+		return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	if err := domain.NamespaceFromContext(ctx).CheckDuration(duration); err != nil {
+		return nil, err
+	}
+
+	lock, err := uc.lockRepo.Renew(ctx, key, renewInput.Owner, duration)
+	if ownerErr := ownerError("RenewLock", key, err); ownerErr != nil {
+		return nil, ownerErr
+	}
+	if err != nil {
+		const msg = "LockUseCase.RenewLock - uc.lockRepo.Renew > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.publish(ctx, lockEvent(domain.LockEventRenewed, lock))
+	const msg = "LockUseCase.RenewLock - Lock renewed > %s"
+	uc.logger.Info(fmt.Sprintf(msg, key))
+	uc.logger.Debug("LockUseCase.RenewLock - END")
+	return lock, nil
+}
+
+// HandoffLock passes a lock held by the given owner on to a new owner without releasing it in between,
+// the lock keeps its remaining TTL and fencing token.
+func (uc *LockUseCase) HandoffLock(ctx context.Context, key string, handoffInput *domain.LockHandoffInput) (*domain.Lock, error) {
+	uc.logger.Debug("LockUseCase.HandoffLock - START")
+	if key == "" {
+		const msg = "LockUseCase.HandoffLock - key is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	if handoffInput.Owner == "" {
+		const msg = "LockUseCase.HandoffLock - owner is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+
+	lock, err := uc.lockRepo.Handoff(ctx, key, handoffInput.Owner, handoffInput.NewOwner)
+	if ownerErr := ownerError("HandoffLock", key, err); ownerErr != nil {
+		return nil, ownerErr
+	}
+	var conflictErr *domain.LockConflictError
+	if errors.As(err, &conflictErr) {
+		const msg = "LockUseCase.HandoffLock(%s) >"
+		return nil, &domain.LockConflictError{Message: fmt.Sprintf(msg, key)}
+	}
+	if err != nil {
+		const msg = "LockUseCase.HandoffLock - uc.lockRepo.Handoff > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	event := lockEvent(domain.LockEventHandoff, lock)
+	event.PreviousOwners = []string{handoffInput.Owner}
+	uc.publish(ctx, event)
+	const msg = "LockUseCase.HandoffLock - Lock %s handed off from %s to %s"
+	uc.logger.Info(fmt.Sprintf(msg, key, handoffInput.Owner, handoffInput.NewOwner))
+	uc.logger.Debug("LockUseCase.HandoffLock - END")
+	return lock, nil
+}
+
+// publish sends the event and adds it to the history of the lock,
+// the change happened already so failures are only logged.
+func (uc *LockUseCase) publish(ctx context.Context, event *domain.LockEvent) {
+	if err := uc.eventRepo.Publish(ctx, event); err != nil {
+		const msg = "LockUseCase.publish - uc.eventRepo.Publish > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+	if err := uc.historyRepo.Append(ctx, historyEntry(ctx, event)); err != nil {
+		const msg = "LockUseCase.publish - uc.historyRepo.Append > %s"
+		uc.logger.Warn(fmt.Sprintf(msg, err.Error()))
+	}
+}
+
+// historyEntry returns the history entry of the event, made by the client of ctx.
+func historyEntry(ctx context.Context, event *domain.LockEvent) *domain.LockHistoryEntry {
+	return &domain.LockHistoryEntry{LockEvent: *event, ClientIP: domain.ClientIPFromContext(ctx)}
+}
+
+// lockEvent returns the event of the given type about the lock and its owner.
+func lockEvent(eventType string, lock *domain.Lock) *domain.LockEvent {
+	return &domain.LockEvent{
+		Type:         eventType,
+		Key:          lock.Key,
+		Owner:        lock.Owner,
+		FencingToken: lock.FencingToken,
+		At:           time.Now().UTC(),
+	}
+}
+
+// ownerError maps the errors of a repository operation that requires the lock to be held by an owner,
+// it returns nil for any other error.
+func ownerError(operation string, key string, err error) error {
+	var lostErr *domain.LockLostError
+	if errors.As(err, &lostErr) {
+		const msg = "LockUseCase.%s(%s) >"
+		return &domain.LockLostError{Message: fmt.Sprintf(msg, operation, key), Owners: lostErr.Owners, ExpiredAt: lostErr.ExpiredAt}
+	}
+	var mismatchErr *domain.LockOwnerMismatchError
+	if errors.As(err, &mismatchErr) {
+		const msg = "LockUseCase.%s(%s) >"
+		return &domain.LockOwnerMismatchError{Message: fmt.Sprintf(msg, operation, key)}
+	}
+	var notHeldErr *domain.LockNotHeldError
+	if errors.As(err, &notHeldErr) {
+		const msg = "LockUseCase.%s(%s) >"
+		return &domain.LockNotHeldError{Message: fmt.Sprintf(msg, operation, key)}
+	}
+	return nil
+}
+
+// GetLockQueue retrieves the owners waiting for a lock in the order they will be granted it.
+func (uc *LockUseCase) GetLockQueue(ctx context.Context, key string) ([]*domain.LockQueueEntry, error) {
+	uc.logger.Debug("LockUseCase.GetLockQueue - START")
+	if key == "" {
+		const msg = "LockUseCase.GetLockQueue - key is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	entries, err := uc.lockRepo.Queue(ctx, key)
+	if err != nil {
+		const msg = "LockUseCase.GetLockQueue - uc.lockRepo.Queue > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.logger.Debug("LockUseCase.GetLockQueue - END")
+	return entries, nil
+}
+
+// GetLockHistory retrieves the changes of a lock between from and to, the oldest first.
+// A zero time leaves its end of the range open.
+func (uc *LockUseCase) GetLockHistory(ctx context.Context, key string, from time.Time, to time.Time) ([]*domain.LockHistoryEntry, error) {
+	uc.logger.Debug("LockUseCase.GetLockHistory - START")
+	if key == "" {
+		const msg = "LockUseCase.GetLockHistory - key is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		const msg = "LockUseCase.GetLockHistory - to is before from >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	entries, err := uc.historyRepo.List(ctx, key, from, to)
+	if err != nil {
+		const msg = "LockUseCase.GetLockHistory - uc.historyRepo.List > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.logger.Debug("LockUseCase.GetLockHistory - END")
+	return entries, nil
+}
+
+// GetLock retrieves a specific lock by key, a shared lock is represented by its first holder.
+func (uc *LockUseCase) GetLock(ctx context.Context, key string) (*domain.Lock, error) {
 	uc.logger.Debug("LockUseCase.GetLock - START")
-	lock, err := uc.lockRepo.Get(key)
+	locks, err := uc.GetLockHolders(ctx, key)
 	if err != nil {
-		const msg = "LockUseCase.GetLock - uc.lockRepo.Get > %s"
+		return nil, err
+	}
+	uc.logger.Debug("LockUseCase.GetLock - END")
+	return locks[0], nil
+}
+
+// GetLockHolders retrieves every current holder of a specific lock by key.
+func (uc *LockUseCase) GetLockHolders(ctx context.Context, key string) ([]*domain.Lock, error) {
+	uc.logger.Debug("LockUseCase.GetLockHolders - START")
+	locks, err := uc.lockRepo.Get(ctx, key)
+	if err != nil {
+		const msg = "LockUseCase.GetLockHolders - uc.lockRepo.Get > %s"
 		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
 	}
-	if lock == nil {
-		const msg = "LockUseCase.GetLock - uc.lockRepo.Get(%s) >"
+	if len(locks) == 0 {
+		const msg = "LockUseCase.GetLockHolders - uc.lockRepo.Get(%s) >"
 		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, key)}
 	}
-	uc.logger.Debug("LockUseCase.GetLock - END")
-	return lock[0], nil
+	uc.logger.Debug("LockUseCase.GetLockHolders - END")
+	return locks, nil
 }
 
 // ListLocks retrieves all existing locks.
-func (uc *LockUseCase) ListLocks() ([]*domain.Lock, error) {
+func (uc *LockUseCase) ListLocks(ctx context.Context) ([]*domain.Lock, error) {
 	uc.logger.Debug("LockUseCase.ListLocks - START")
-	locks, err := uc.lockRepo.Get("*")
+	locks, err := uc.lockRepo.Get(ctx, "*")
 	if err != nil {
 		const msg = "LockUseCase.ListLocks - uc.lockRepo.Get > %s"
 		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
@@ -1,6 +1,9 @@
 package usecases
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,11 +21,26 @@ const (
 )
 
 var testLock = &domain.Lock{
-	Key:       testKeyValue,
-	Owner:     testOwnerValue,
-	Duration:  3600,
-	CreatedAt: time.Now(),
-	ExpireAt:  time.Now().Add(time.Hour),
+	Key:          testKeyValue,
+	Owner:        testOwnerValue,
+	Duration:     3600,
+	CreatedAt:    time.Now(),
+	ExpireAt:     time.Now().Add(time.Hour),
+	FencingToken: 1,
+}
+
+// newMockEventRepository returns an event repository that accepts every published event.
+func newMockEventRepository() *repositories.MockEventRepository {
+	mockEvents := new(repositories.MockEventRepository)
+	mockEvents.On("Publish", mock.Anything, mock.Anything).Return(nil)
+	return mockEvents
+}
+
+// newMockHistoryRepository returns a history repository that accepts every entry.
+func newMockHistoryRepository() *repositories.MockHistoryRepository {
+	mockHistory := new(repositories.MockHistoryRepository)
+	mockHistory.On("Append", mock.Anything, mock.Anything).Return(nil)
+	return mockHistory
 }
 
 func TestCreateLockSuccess(t *testing.T) {
@@ -30,10 +48,9 @@ func TestCreateLockSuccess(t *testing.T) {
 	testDuration := "1h"
 	mockLogger := infrastructure.NewMockLogger()
 	mockRepo := new(repositories.MockLockRepository)
-	mockRepo.On("Set", testKeyValue, mock.AnythingOfType("string"), mock.Anything).Return(testLock, nil)
-	mockRepo.On("Get", testKeyValue).Return(nil, nil)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), mock.Anything).Return(testLock, nil)
 
-	uc := NewLockUseCase(mockRepo, mockLogger)
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
 
 	input := &domain.LockInput{
 		Key:      testKeyValue,
@@ -42,7 +59,7 @@ func TestCreateLockSuccess(t *testing.T) {
 	}
 
 	// Act
-	result, err := uc.CreateLock(input)
+	result, err := uc.CreateLock(context.Background(), input)
 
 	// Assert
 	mockRepo.AssertExpectations(t)
@@ -54,15 +71,17 @@ func TestCreateLockSuccess(t *testing.T) {
 	assert.False(t, result.CreatedAt.IsZero())
 	assert.False(t, result.ExpireAt.IsZero())
 	assert.True(t, result.ExpireAt.After(result.CreatedAt))
+	assert.Equal(t, int64(1), result.FencingToken)
 }
 
 func TestCreateLockConflict(t *testing.T) {
 	// Arrange
 	mockLogger := infrastructure.NewMockLogger()
 	mockRepo := new(repositories.MockLockRepository)
-	mockRepo.On("Get", testKeyValue).Return([]*domain.Lock{testLock}, nil)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), mock.Anything).
+		Return(nil, &domain.LockConflictError{Message: testKeyValue})
 
-	uc := NewLockUseCase(mockRepo, mockLogger)
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
 
 	input := &domain.LockInput{
 		Key:      testKeyValue,
@@ -71,7 +90,7 @@ func TestCreateLockConflict(t *testing.T) {
 	}
 
 	// Act
-	result, err := uc.CreateLock(input)
+	result, err := uc.CreateLock(context.Background(), input)
 
 	// Assert
 	mockRepo.AssertExpectations(t)
@@ -81,16 +100,40 @@ func TestCreateLockConflict(t *testing.T) {
 
 }
 
+func TestCreateLockStoreError(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), mock.Anything).
+		Return(nil, errors.New("connection refused"))
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:      testKeyValue,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+	}
+
+	// Act
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.InternalError{}, err)
+}
+
 func TestDeleteLockSuccess(t *testing.T) {
 	// Arrange
 	mockLogger := infrastructure.NewMockLogger()
 	mockRepo := new(repositories.MockLockRepository)
-	mockRepo.On("Del", testKeyValue).Return(nil)
+	mockRepo.On("Release", mock.Anything, testKeyValue, testOwnerValue).Return(nil)
 
-	uc := NewLockUseCase(mockRepo, mockLogger)
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
 
 	// Act
-	err := uc.DeleteLock(testKeyValue)
+	err := uc.DeleteLock(context.Background(), testKeyValue, testOwnerValue)
 
 	// Assert
 	mockRepo.AssertExpectations(t)
@@ -102,10 +145,10 @@ func TestDeleteLockEmptyKey(t *testing.T) {
 	mockLogger := infrastructure.NewMockLogger()
 	mockRepo := new(repositories.MockLockRepository)
 
-	uc := NewLockUseCase(mockRepo, mockLogger)
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
 
 	// Act
-	err := uc.DeleteLock("")
+	err := uc.DeleteLock(context.Background(), "", testOwnerValue)
 
 	// Assert
 	mockRepo.AssertExpectations(t)
@@ -114,19 +157,764 @@ func TestDeleteLockEmptyKey(t *testing.T) {
 
 }
 
+func TestDeleteLockEmptyOwner(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	err := uc.DeleteLock(context.Background(), testKeyValue, "")
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.IsType(t, &domain.InputError{}, err)
+}
+
+func TestDeleteLockOwnerMismatch(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Release", mock.Anything, testKeyValue, "other-owner").
+		Return(&domain.LockOwnerMismatchError{Message: testKeyValue})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	err := uc.DeleteLock(context.Background(), testKeyValue, "other-owner")
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.IsType(t, &domain.LockOwnerMismatchError{}, err)
+}
+
+func TestDeleteLockNotHeld(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Release", mock.Anything, testKeyValue, testOwnerValue).
+		Return(&domain.LockNotHeldError{Message: testKeyValue})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	err := uc.DeleteLock(context.Background(), testKeyValue, testOwnerValue)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.IsType(t, &domain.LockNotHeldError{}, err)
+}
+
+func TestRenewLockSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Renew", mock.Anything, testKeyValue, testOwnerValue, 2*time.Hour).Return(testLock, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockRenewInput{
+		Owner:    testOwnerValue,
+		Duration: "2h",
+	}
+
+	// Act
+	result, err := uc.RenewLock(context.Background(), testKeyValue, input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, testLock, result)
+}
+
+func TestRenewLockNotHeld(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Renew", mock.Anything, testKeyValue, testOwnerValue, time.Hour).
+		Return(nil, &domain.LockNotHeldError{Message: testKeyValue})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockRenewInput{
+		Owner:    testOwnerValue,
+		Duration: "1h",
+	}
+
+	// Act
+	result, err := uc.RenewLock(context.Background(), testKeyValue, input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.LockNotHeldError{}, err)
+}
+
+func TestDeleteLockLost(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	expiredAt := time.Now().Add(-time.Minute).UTC()
+	mockRepo.On("Release", mock.Anything, testKeyValue, testOwnerValue).
+		Return(&domain.LockLostError{Message: testKeyValue, Owners: []string{"other-owner"}, ExpiredAt: expiredAt})
+	mockEvents := newMockEventRepository()
+
+	uc := NewLockUseCase(mockRepo, mockEvents, newMockHistoryRepository(), mockLogger)
+
+	// Act
+	err := uc.DeleteLock(context.Background(), testKeyValue, testOwnerValue)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	mockEvents.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+	var lostErr *domain.LockLostError
+	assert.ErrorAs(t, err, &lostErr)
+	assert.Equal(t, []string{"other-owner"}, lostErr.Owners)
+	assert.Equal(t, expiredAt, lostErr.ExpiredAt)
+}
+
+func TestRenewLockLost(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	expiredAt := time.Now().Add(-time.Minute).UTC()
+	mockRepo.On("Renew", mock.Anything, testKeyValue, testOwnerValue, time.Hour).
+		Return(nil, &domain.LockLostError{Message: testKeyValue, Owners: []string{}, ExpiredAt: expiredAt})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockRenewInput{
+		Owner:    testOwnerValue,
+		Duration: "1h",
+	}
+
+	// Act
+	result, err := uc.RenewLock(context.Background(), testKeyValue, input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	var lostErr *domain.LockLostError
+	assert.ErrorAs(t, err, &lostErr)
+	assert.Empty(t, lostErr.Owners)
+	assert.Equal(t, expiredAt, lostErr.ExpiredAt)
+}
+
+func TestRenewLockInvalidDuration(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockRenewInput{
+		Owner:    testOwnerValue,
+		Duration: "forever",
+	}
+
+	// Act
+	result, err := uc.RenewLock(context.Background(), testKeyValue, input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.InputError{}, err)
+}
+
 func TestGetLockNotFound(t *testing.T) {
 	// Arrange
 	mockLogger := infrastructure.NewMockLogger()
 	mockRepo := new(repositories.MockLockRepository)
-	mockRepo.On("Get", testKeyValue).Return([]*domain.Lock{nil}, nil)
+	mockRepo.On("Get", mock.Anything, testKeyValue).Return(nil, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	result, err := uc.GetLock(context.Background(), testKeyValue)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.IsType(t, &domain.NotFoundError{}, err)
+	assert.Nil(t, result)
+}
+
+func TestGetLockSharedHolders(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	holders := []*domain.Lock{
+		{Key: testKeyValue, Owner: "first-reader", Mode: domain.LockModeShared},
+		{Key: testKeyValue, Owner: "second-reader", Mode: domain.LockModeShared},
+	}
+	mockRepo.On("Get", mock.Anything, testKeyValue).Return(holders, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	result, err := uc.GetLockHolders(context.Background(), testKeyValue)
+	first, firstErr := uc.GetLock(context.Background(), testKeyValue)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.NoError(t, firstErr)
+	assert.Equal(t, holders, result)
+	assert.Equal(t, holders[0], first)
+}
+
+func TestCreateLockShared(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	isShared := mock.MatchedBy(func(value string) bool {
+		return strings.Contains(value, `"mode":"shared"`)
+	})
+	mockRepo.On("Set", mock.Anything, testKeyValue, isShared, time.Hour).Return(testLock, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:      testKeyValue,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+		Mode:     domain.LockModeShared,
+	}
+
+	// Act
+	_, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+}
+
+func TestCreateLockReentrant(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	isReentrant := mock.MatchedBy(func(value string) bool {
+		return strings.Contains(value, `"reentrant":true`) && strings.Contains(value, `"holdCount":1`)
+	})
+	reentered := *testLock
+	reentered.Reentrant = true
+	reentered.HoldCount = 2
+	mockRepo.On("Set", mock.Anything, testKeyValue, isReentrant, time.Hour).Return(&reentered, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:       testKeyValue,
+		Owner:     testOwnerValue,
+		Duration:  "1h",
+		Reentrant: true,
+	}
+
+	// Act
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.HoldCount)
+	assert.Equal(t, testLock.FencingToken, result.FencingToken)
+}
+
+func TestCreateLockWaitAcquiresAfterRelease(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	changes := make(chan struct{}, 1)
+	changes <- struct{}{}
+	mockRepo.On("Watch", mock.Anything, testKeyValue).Return((<-chan struct{})(changes), nil)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).
+		Return(nil, &domain.LockConflictError{Message: testKeyValue}).Once()
+	mockRepo.On("Enqueue", mock.Anything, testKeyValue, testOwnerValue, mock.AnythingOfType("time.Time")).Return(1, nil)
+	mockRepo.On("Get", mock.Anything, testKeyValue).Return([]*domain.Lock{testLock}, nil).Once()
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).
+		Return(nil, &domain.LockConflictError{Message: testKeyValue}).Once()
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).Return(testLock, nil).Once()
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:      testKeyValue,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+		Wait:     "10s",
+	}
+
+	// Act
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, testLock, result)
+}
+
+func TestCreateLockWaitTimeout(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Watch", mock.Anything, testKeyValue).Return((<-chan struct{})(make(chan struct{})), nil)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).
+		Return(nil, &domain.LockConflictError{Message: testKeyValue})
+	mockRepo.On("Enqueue", mock.Anything, testKeyValue, testOwnerValue, mock.AnythingOfType("time.Time")).Return(2, nil)
+	mockRepo.On("Get", mock.Anything, testKeyValue).Return([]*domain.Lock{testLock}, nil)
+	mockRepo.On("Dequeue", mock.Anything, testKeyValue, testOwnerValue).Return(nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:      testKeyValue,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+		Wait:     "50ms",
+	}
+
+	// Act
+	start := time.Now()
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.LockConflictError{}, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestCreateLockWaitLeasesQueuePlace(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	changes := make(chan struct{}, 1)
+	changes <- struct{}{}
+	mockRepo.On("Watch", mock.Anything, testKeyValue).Return((<-chan struct{})(changes), nil)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).
+		Return(nil, &domain.LockConflictError{Message: testKeyValue}).Twice()
+	leaseEnd := time.Now().Add(queueLease + time.Second)
+	mockRepo.On("Enqueue", mock.Anything, testKeyValue, testOwnerValue,
+		mock.MatchedBy(func(deadline time.Time) bool { return deadline.Before(leaseEnd) })).Return(1, nil).Once()
+	mockRepo.On("Get", mock.Anything, testKeyValue).Return([]*domain.Lock{testLock}, nil).Once()
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).Return(testLock, nil).Once()
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:      testKeyValue,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+		Wait:     "1h",
+	}
+
+	// Act
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, testLock, result)
+}
+
+func TestCreateLockWaitTimeoutInStore(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	changes := make(chan struct{}, 1)
+	changes <- struct{}{}
+	mockRepo.On("Watch", mock.Anything, testKeyValue).Return((<-chan struct{})(changes), nil)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).
+		Return(nil, &domain.LockConflictError{Message: testKeyValue}).Twice()
+	mockRepo.On("Enqueue", mock.Anything, testKeyValue, testOwnerValue, mock.AnythingOfType("time.Time")).Return(1, nil)
+	mockRepo.On("Get", mock.Anything, testKeyValue).Return([]*domain.Lock{testLock}, nil)
+	mockRepo.On("Dequeue", mock.Anything, testKeyValue, testOwnerValue).Return(nil)
+	// the store call that is running when the wait passes fails with the deadline of the context
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Hour).
+		Return(nil, errors.New("i/o timeout")).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() })
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:      testKeyValue,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+		Wait:     "50ms",
+	}
+
+	// Act
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.LockConflictError{}, err)
+}
+
+func TestGetLockQueue(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	entries := []*domain.LockQueueEntry{
+		{Key: testKeyValue, Owner: "first-owner", Position: 1},
+		{Key: testKeyValue, Owner: "second-owner", Position: 2},
+	}
+	mockRepo.On("Queue", mock.Anything, testKeyValue).Return(entries, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	result, err := uc.GetLockQueue(context.Background(), testKeyValue)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, result)
+}
+
+func TestCreateLocksSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	keys := []string{"first-lock", "second-lock"}
+	allKeys := mock.MatchedBy(func(locks []*domain.Lock) bool {
+		return len(locks) == 2 && locks[0].Key == keys[0] && locks[1].Key == keys[1] &&
+			locks[0].Owner == testOwnerValue && locks[1].Owner == testOwnerValue
+	})
+	mockRepo.On("SetMultiple", mock.Anything, allKeys).Return([]*domain.Lock{testLock, testLock}, nil)
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockBatchInput{
+		Keys:     keys,
+		Owner:    testOwnerValue,
+		Duration: "1h",
+	}
+
+	// Act
+	result, err := uc.CreateLocks(context.Background(), input)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+}
+
+func TestCreateLocksConflict(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("SetMultiple", mock.Anything, mock.Anything).
+		Return(nil, &domain.LockConflictError{Message: "second-lock", Keys: []string{"second-lock"}})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockBatchInput{
+		Keys:     []string{"first-lock", "second-lock"},
+		Owner:    testOwnerValue,
+		Duration: "1h",
+	}
+
+	// Act
+	result, err := uc.CreateLocks(context.Background(), input)
+
+	// Assert
+	assert.Nil(t, result)
+	var conflictErr *domain.LockConflictError
+	assert.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, []string{"second-lock"}, conflictErr.Keys)
+}
+
+func TestCreateLockInSession(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	inSession := mock.MatchedBy(func(value string) bool {
+		return strings.Contains(value, `"sessionId":"test-session"`)
+	})
+	mockRepo.On("Set", mock.Anything, testKeyValue, inSession, time.Duration(0)).Return(testLock, nil)
 
-	uc := NewLockUseCase(mockRepo, mockLogger)
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:       testKeyValue,
+		Owner:     testOwnerValue,
+		SessionID: "test-session",
+	}
 
 	// Act
-	result, err := uc.GetLock(testKeyValue)
+	_, err := uc.CreateLock(context.Background(), input)
 
 	// Assert
 	mockRepo.AssertExpectations(t)
 	assert.NoError(t, err)
+}
+
+func TestCreateLockSessionNotFound(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), time.Duration(0)).
+		Return(nil, &domain.NotFoundError{Message: "test-session"})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	input := &domain.LockInput{
+		Key:       testKeyValue,
+		Owner:     testOwnerValue,
+		SessionID: "test-session",
+	}
+
+	// Act
+	result, err := uc.CreateLock(context.Background(), input)
+
+	// Assert
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.NotFoundError{}, err)
+}
+
+func TestHandoffLock(t *testing.T) {
+	input := &domain.LockHandoffInput{Owner: testOwnerValue, NewOwner: "next-owner"}
+
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockLockRepository)
+		handedOff := *testLock
+		handedOff.Owner = "next-owner"
+		mockRepo.On("Handoff", mock.Anything, testKeyValue, testOwnerValue, "next-owner").Return(&handedOff, nil)
+
+		uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+		// Act
+		result, err := uc.HandoffLock(context.Background(), testKeyValue, input)
+
+		// Assert
+		mockRepo.AssertExpectations(t)
+		assert.NoError(t, err)
+		assert.Equal(t, "next-owner", result.Owner)
+		assert.Equal(t, testLock.FencingToken, result.FencingToken)
+	})
+
+	t.Run("OwnerMismatch", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockLockRepository)
+		mockRepo.On("Handoff", mock.Anything, testKeyValue, testOwnerValue, "next-owner").
+			Return(nil, &domain.LockOwnerMismatchError{Message: testKeyValue})
+
+		uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+		// Act
+		result, err := uc.HandoffLock(context.Background(), testKeyValue, input)
+
+		// Assert
+		assert.Nil(t, result)
+		assert.IsType(t, &domain.LockOwnerMismatchError{}, err)
+	})
+
+	t.Run("NewOwnerHoldsLock", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockLockRepository)
+		mockRepo.On("Handoff", mock.Anything, testKeyValue, testOwnerValue, "next-owner").
+			Return(nil, &domain.LockConflictError{Message: testKeyValue})
+
+		uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+		// Act
+		result, err := uc.HandoffLock(context.Background(), testKeyValue, input)
+
+		// Assert
+		assert.Nil(t, result)
+		assert.IsType(t, &domain.LockConflictError{}, err)
+	})
+}
+
+func TestCreateLockNamespacePolicy(t *testing.T) {
+	ns := &domain.Namespace{Name: "team-a", MaxDuration: "10m", MaxWait: "30s"}
+
+	t.Run("DurationExceeded", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockLockRepository)
+
+		uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+		// Act
+		result, err := uc.CreateLock(domain.WithNamespace(context.Background(), ns), &domain.LockInput{
+			Key:      testKeyValue,
+			Owner:    testOwnerValue,
+			Duration: "1h",
+		})
+
+		// Assert
+		mockRepo.AssertNotCalled(t, "Set")
+		assert.Nil(t, result)
+		assert.IsType(t, &domain.ValidationError{}, err)
+	})
+
+	t.Run("WaitExceeded", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockLockRepository)
+
+		uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+		// Act
+		result, err := uc.CreateLock(domain.WithNamespace(context.Background(), ns), &domain.LockInput{
+			Key:      testKeyValue,
+			Owner:    testOwnerValue,
+			Duration: "5m",
+			Wait:     "1m",
+		})
+
+		// Assert
+		mockRepo.AssertNotCalled(t, "Set")
+		assert.Nil(t, result)
+		assert.IsType(t, &domain.ValidationError{}, err)
+	})
+
+	t.Run("WithinPolicy", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockLockRepository)
+		mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), 10*time.Minute).Return(testLock, nil)
+
+		uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+		// Act
+		result, err := uc.CreateLock(domain.WithNamespace(context.Background(), ns), &domain.LockInput{
+			Key:      testKeyValue,
+			Owner:    testOwnerValue,
+			Duration: "10m",
+		})
+
+		// Assert
+		mockRepo.AssertExpectations(t)
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+	})
+}
+
+func TestRenewLockNamespacePolicy(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	ctx := domain.WithNamespace(context.Background(), &domain.Namespace{Name: "team-a", MaxDuration: "10m"})
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), newMockHistoryRepository(), mockLogger)
+
+	// Act
+	result, err := uc.RenewLock(ctx, testKeyValue, &domain.LockRenewInput{Owner: testOwnerValue, Duration: "1h"})
+
+	// Assert
+	mockRepo.AssertNotCalled(t, "Renew")
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.ValidationError{}, err)
+}
+
+func TestCreateLockPublishesEvent(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Set", mock.Anything, testKeyValue, mock.AnythingOfType("string"), mock.Anything).Return(testLock, nil)
+	mockEvents := new(repositories.MockEventRepository)
+	isAcquired := mock.MatchedBy(func(event *domain.LockEvent) bool {
+		return event.Type == domain.LockEventAcquired && event.Key == testKeyValue &&
+			event.Owner == testOwnerValue && event.FencingToken == testLock.FencingToken
+	})
+	mockEvents.On("Publish", mock.Anything, isAcquired).Return(nil)
+
+	uc := NewLockUseCase(mockRepo, mockEvents, newMockHistoryRepository(), mockLogger)
+
+	// Act
+	_, err := uc.CreateLock(context.Background(), &domain.LockInput{Key: testKeyValue, Owner: testOwnerValue, Duration: "1h"})
+
+	// Assert
+	mockEvents.AssertExpectations(t)
+	assert.NoError(t, err)
+}
+
+func TestDeleteLockPublishFailure(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Release", mock.Anything, testKeyValue, testOwnerValue).Return(nil)
+	mockEvents := new(repositories.MockEventRepository)
+	isReleased := mock.MatchedBy(func(event *domain.LockEvent) bool {
+		return event.Type == domain.LockEventReleased && event.PreviousOwners[0] == testOwnerValue
+	})
+	mockEvents.On("Publish", mock.Anything, isReleased).Return(errors.New("connection refused"))
+
+	uc := NewLockUseCase(mockRepo, mockEvents, newMockHistoryRepository(), mockLogger)
+
+	// Act
+	err := uc.DeleteLock(context.Background(), testKeyValue, testOwnerValue)
+
+	// Assert
+	mockEvents.AssertExpectations(t)
+	assert.NoError(t, err)
+}
+
+func TestDeleteLockRecordsHistory(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockLockRepository)
+	mockRepo.On("Release", mock.Anything, testKeyValue, testOwnerValue).Return(nil)
+	mockHistory := new(repositories.MockHistoryRepository)
+	isReleased := mock.MatchedBy(func(entry *domain.LockHistoryEntry) bool {
+		return entry.Type == domain.LockEventReleased && entry.Key == testKeyValue &&
+			entry.PreviousOwners[0] == testOwnerValue && entry.ClientIP == "10.0.0.7"
+	})
+	mockHistory.On("Append", mock.Anything, isReleased).Return(errors.New("connection refused"))
+
+	uc := NewLockUseCase(mockRepo, newMockEventRepository(), mockHistory, mockLogger)
+
+	// Act
+	err := uc.DeleteLock(domain.WithClientIP(context.Background(), "10.0.0.7"), testKeyValue, testOwnerValue)
+
+	// Assert
+	mockHistory.AssertExpectations(t)
+	assert.NoError(t, err)
+}
+
+func TestGetLockHistory(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	from := time.Now().Add(-time.Hour)
+	entries := []*domain.LockHistoryEntry{
+		{LockEvent: domain.LockEvent{Type: domain.LockEventAcquired, Key: testKeyValue, Owner: testOwnerValue}},
+		{LockEvent: domain.LockEvent{Type: domain.LockEventExpired, Key: testKeyValue, PreviousOwners: []string{testOwnerValue}}},
+	}
+	mockHistory := new(repositories.MockHistoryRepository)
+	mockHistory.On("List", mock.Anything, testKeyValue, from, time.Time{}).Return(entries, nil)
+
+	uc := NewLockUseCase(new(repositories.MockLockRepository), newMockEventRepository(), mockHistory, mockLogger)
+
+	// Act
+	result, err := uc.GetLockHistory(context.Background(), testKeyValue, from, time.Time{})
+
+	// Assert
+	mockHistory.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, result)
+}
+
+func TestGetLockHistoryInvalidRange(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockHistory := new(repositories.MockHistoryRepository)
+	from := time.Now()
+
+	uc := NewLockUseCase(new(repositories.MockLockRepository), newMockEventRepository(), mockHistory, mockLogger)
+
+	// Act
+	result, err := uc.GetLockHistory(context.Background(), testKeyValue, from, from.Add(-time.Hour))
+
+	// Assert
+	mockHistory.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	assert.Nil(t, result)
+	assert.IsType(t, &domain.InputError{}, err)
 }
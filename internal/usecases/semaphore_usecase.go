@@ -0,0 +1,144 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// SemaphoreUseCase handles the business logic for counting semaphores.
+type SemaphoreUseCase struct {
+	semaphoreRepo domain.SemaphoreRepository
+	logger        domain.Logger
+}
+
+// NewSemaphoreUseCase creates a new SemaphoreUseCase with the given repository and logger.
+func NewSemaphoreUseCase(semaphoreRepo domain.SemaphoreRepository, logger domain.Logger) *SemaphoreUseCase {
+	return &SemaphoreUseCase{
+		semaphoreRepo: semaphoreRepo,
+		logger:        logger,
+	}
+}
+
+// CreateSemaphore creates a new semaphore with the given number of permits.
+func (uc *SemaphoreUseCase) CreateSemaphore(ctx context.Context, input *domain.SemaphoreInput) (*domain.Semaphore, error) {
+	uc.logger.Debug("SemaphoreUseCase.CreateSemaphore - START")
+	semaphore := &domain.Semaphore{
+		Key:       input.Key,
+		Permits:   input.Permits,
+		Available: input.Permits,
+		Holders:   []*domain.SemaphorePermit{},
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := uc.semaphoreRepo.Create(ctx, semaphore); err != nil {
+		return nil, semaphoreError("SemaphoreUseCase.CreateSemaphore - uc.semaphoreRepo.Create", err)
+	}
+	const msg = "SemaphoreUseCase.CreateSemaphore - Semaphore created > %s"
+	uc.logger.Info(fmt.Sprintf(msg, input.Key))
+	uc.logger.Debug("SemaphoreUseCase.CreateSemaphore - END")
+	return semaphore, nil
+}
+
+// DeleteSemaphore removes a semaphore, it fails while permits are held.
+func (uc *SemaphoreUseCase) DeleteSemaphore(ctx context.Context, key string) error {
+	uc.logger.Debug("SemaphoreUseCase.DeleteSemaphore - START")
+	if key == "" {
+		const msg = "SemaphoreUseCase.DeleteSemaphore - key is empty >"
+		return &domain.InputError{Message: msg}
+	}
+	if err := uc.semaphoreRepo.Delete(ctx, key); err != nil {
+		return semaphoreError("SemaphoreUseCase.DeleteSemaphore - uc.semaphoreRepo.Delete", err)
+	}
+	const msg = "SemaphoreUseCase.DeleteSemaphore - Semaphore deleted: %s"
+	uc.logger.Info(fmt.Sprintf(msg, key))
+	uc.logger.Debug("SemaphoreUseCase.DeleteSemaphore - END")
+	return nil
+}
+
+// GetSemaphore retrieves a semaphore with its current holders.
+func (uc *SemaphoreUseCase) GetSemaphore(ctx context.Context, key string) (*domain.Semaphore, error) {
+	uc.logger.Debug("SemaphoreUseCase.GetSemaphore - START")
+	semaphore, err := uc.semaphoreRepo.Get(ctx, key)
+	if err != nil {
+		return nil, semaphoreError("SemaphoreUseCase.GetSemaphore - uc.semaphoreRepo.Get", err)
+	}
+	if semaphore == nil {
+		const msg = "SemaphoreUseCase.GetSemaphore - uc.semaphoreRepo.Get(%s) >"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, key)}
+	}
+	uc.logger.Debug("SemaphoreUseCase.GetSemaphore - END")
+	return semaphore, nil
+}
+
+// ListSemaphores retrieves all semaphores with their current holders.
+func (uc *SemaphoreUseCase) ListSemaphores(ctx context.Context) ([]*domain.Semaphore, error) {
+	uc.logger.Debug("SemaphoreUseCase.ListSemaphores - START")
+	semaphores, err := uc.semaphoreRepo.List(ctx)
+	if err != nil {
+		return nil, semaphoreError("SemaphoreUseCase.ListSemaphores - uc.semaphoreRepo.List", err)
+	}
+	uc.logger.Debug("SemaphoreUseCase.ListSemaphores - END")
+	return semaphores, nil
+}
+
+// AcquirePermit takes one permit of a semaphore for the given owner and duration.
+func (uc *SemaphoreUseCase) AcquirePermit(ctx context.Context, key string, input *domain.SemaphoreAcquireInput) (*domain.SemaphorePermit, error) {
+	uc.logger.Debug("SemaphoreUseCase.AcquirePermit - START")
+	if key == "" {
+		const msg = "SemaphoreUseCase.AcquirePermit - key is empty >"
+		return nil, &domain.InputError{Message: msg}
+	}
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil {
+		const msg = "SemaphoreUseCase.AcquirePermit - time.ParseDuration > %s"
+		return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+
+	now := time.Now().UTC()
+	permit := &domain.SemaphorePermit{
+		Key:       key,
+		Owner:     input.Owner,
+		Duration:  int64(duration.Seconds()),
+		CreatedAt: now,
+		ExpireAt:  now.Add(duration),
+	}
+	result, err := uc.semaphoreRepo.Acquire(ctx, permit, duration)
+	if err != nil {
+		return nil, semaphoreError("SemaphoreUseCase.AcquirePermit - uc.semaphoreRepo.Acquire", err)
+	}
+	const msg = "SemaphoreUseCase.AcquirePermit - Permit acquired > %s by %s"
+	uc.logger.Info(fmt.Sprintf(msg, key, input.Owner))
+	uc.logger.Debug("SemaphoreUseCase.AcquirePermit - END")
+	return result, nil
+}
+
+// ReleasePermit returns the permit held by the given owner.
+func (uc *SemaphoreUseCase) ReleasePermit(ctx context.Context, key string, owner string) error {
+	uc.logger.Debug("SemaphoreUseCase.ReleasePermit - START")
+	if key == "" {
+		const msg = "SemaphoreUseCase.ReleasePermit - key is empty >"
+		return &domain.InputError{Message: msg}
+	}
+	if owner == "" {
+		const msg = "SemaphoreUseCase.ReleasePermit - owner is empty >"
+		return &domain.InputError{Message: msg}
+	}
+	if err := uc.semaphoreRepo.Release(ctx, key, owner); err != nil {
+		return semaphoreError("SemaphoreUseCase.ReleasePermit - uc.semaphoreRepo.Release", err)
+	}
+	const msg = "SemaphoreUseCase.ReleasePermit - Permit released > %s by %s"
+	uc.logger.Info(fmt.Sprintf(msg, key, owner))
+	uc.logger.Debug("SemaphoreUseCase.ReleasePermit - END")
+	return nil
+}
+
+// semaphoreError passes the domain errors of the repository through and wraps any other error as internal error.
+func semaphoreError(operation string, err error) error {
+	switch err.(type) {
+	case *domain.NotFoundError, *domain.LockConflictError, *domain.LockNotHeldError, *domain.SemaphoreExhaustedError:
+		return err
+	}
+	return &domain.InternalError{Message: fmt.Sprintf("%s > %s", operation, err.Error())}
+}
@@ -0,0 +1,183 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+)
+
+const testSemaphoreKey = "test-semaphore"
+
+func TestCreateSemaphoreSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSemaphoreRepository)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Semaphore")).Return(nil)
+
+	uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.CreateSemaphore(context.Background(), &domain.SemaphoreInput{Key: testSemaphoreKey, Permits: 3})
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, testSemaphoreKey, result.Key)
+	assert.Equal(t, 3, result.Permits)
+	assert.Equal(t, 3, result.Available)
+	assert.Empty(t, result.Holders)
+}
+
+func TestCreateSemaphoreConflict(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSemaphoreRepository)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(&domain.LockConflictError{Message: "exists"})
+
+	uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.CreateSemaphore(context.Background(), &domain.SemaphoreInput{Key: testSemaphoreKey, Permits: 3})
+
+	// Assert
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.LockConflictError{}, err)
+}
+
+func TestAcquirePermitSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSemaphoreRepository)
+	var stored *domain.SemaphorePermit
+	mockRepo.On("Acquire", mock.Anything, mock.AnythingOfType("*domain.SemaphorePermit"), time.Minute).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.SemaphorePermit) }).
+		Return(&domain.SemaphorePermit{Key: testSemaphoreKey, Owner: testOwnerValue}, nil)
+
+	uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.AcquirePermit(context.Background(), testSemaphoreKey, &domain.SemaphoreAcquireInput{
+		Owner:    testOwnerValue,
+		Duration: "1m",
+	})
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, testSemaphoreKey, stored.Key)
+	assert.Equal(t, testOwnerValue, stored.Owner)
+	assert.Equal(t, int64(60), stored.Duration)
+	assert.True(t, stored.ExpireAt.After(stored.CreatedAt))
+}
+
+func TestAcquirePermitExhausted(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSemaphoreRepository)
+	mockRepo.On("Acquire", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, &domain.SemaphoreExhaustedError{Message: "exhausted"})
+
+	uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.AcquirePermit(context.Background(), testSemaphoreKey, &domain.SemaphoreAcquireInput{
+		Owner:    testOwnerValue,
+		Duration: "1m",
+	})
+
+	// Assert
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.SemaphoreExhaustedError{}, err)
+}
+
+func TestAcquirePermitStoreError(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSemaphoreRepository)
+	mockRepo.On("Acquire", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+	// Act
+	_, err := uc.AcquirePermit(context.Background(), testSemaphoreKey, &domain.SemaphoreAcquireInput{
+		Owner:    testOwnerValue,
+		Duration: "1m",
+	})
+
+	// Assert
+	assert.IsType(t, &domain.InternalError{}, err)
+}
+
+func TestReleasePermit(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockSemaphoreRepository)
+		mockRepo.On("Release", mock.Anything, testSemaphoreKey, testOwnerValue).Return(nil)
+
+		uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+		// Act
+		err := uc.ReleasePermit(context.Background(), testSemaphoreKey, testOwnerValue)
+
+		// Assert
+		mockRepo.AssertExpectations(t)
+		assert.NoError(t, err)
+	})
+
+	t.Run("EmptyOwner", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockSemaphoreRepository)
+
+		uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+		// Act
+		err := uc.ReleasePermit(context.Background(), testSemaphoreKey, "")
+
+		// Assert
+		mockRepo.AssertNotCalled(t, "Release")
+		assert.IsType(t, &domain.InputError{}, err)
+	})
+
+	t.Run("NotHeld", func(t *testing.T) {
+		// Arrange
+		mockLogger := infrastructure.NewMockLogger()
+		mockRepo := new(repositories.MockSemaphoreRepository)
+		mockRepo.On("Release", mock.Anything, testSemaphoreKey, testOwnerValue).
+			Return(&domain.LockNotHeldError{Message: "not held"})
+
+		uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+		// Act
+		err := uc.ReleasePermit(context.Background(), testSemaphoreKey, testOwnerValue)
+
+		// Assert
+		assert.IsType(t, &domain.LockNotHeldError{}, err)
+	})
+}
+
+func TestGetSemaphoreNotFound(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSemaphoreRepository)
+	mockRepo.On("Get", mock.Anything, testSemaphoreKey).Return(nil, nil)
+
+	uc := NewSemaphoreUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.GetSemaphore(context.Background(), testSemaphoreKey)
+
+	// Assert
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.NotFoundError{}, err)
+}
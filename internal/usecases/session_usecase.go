@@ -0,0 +1,133 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+// SessionUseCase handles the business logic for sessions that own locks.
+type SessionUseCase struct {
+	sessionRepo domain.SessionRepository
+	logger      domain.Logger
+}
+
+// NewSessionUseCase creates a new SessionUseCase with the given repository and logger.
+func NewSessionUseCase(sessionRepo domain.SessionRepository, logger domain.Logger) *SessionUseCase {
+	return &SessionUseCase{
+		sessionRepo: sessionRepo,
+		logger:      logger,
+	}
+}
+
+// CreateSession creates a new session with a random id, it expires unless a heartbeat arrives within its TTL.
+func (uc *SessionUseCase) CreateSession(ctx context.Context, input *domain.SessionInput) (*domain.Session, error) {
+	uc.logger.Debug("SessionUseCase.CreateSession - START")
+	ttl, err := time.ParseDuration(input.TTL)
+	if err != nil {
+		const msg = "SessionUseCase.CreateSession - time.ParseDuration > %s"
+		return nil, &domain.InputError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	id, err := newRandomID()
+	if err != nil {
+		const msg = "SessionUseCase.CreateSession - newRandomID > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+
+	now := time.Now().UTC()
+	session := &domain.Session{
+		ID:        id,
+		TTL:       int64(ttl.Seconds()),
+		CreatedAt: now,
+		ExpireAt:  now.Add(ttl),
+		Keys:      []string{},
+	}
+	err = uc.sessionRepo.Create(ctx, session)
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return nil, validationErr
+	}
+	if err != nil {
+		const msg = "SessionUseCase.CreateSession - uc.sessionRepo.Create > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	const msg = "SessionUseCase.CreateSession - Session created > %s"
+	uc.logger.Info(fmt.Sprintf(msg, id))
+	uc.logger.Debug("SessionUseCase.CreateSession - END")
+	return session, nil
+}
+
+// GetSession retrieves a session with the keys of the locks it holds.
+func (uc *SessionUseCase) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	uc.logger.Debug("SessionUseCase.GetSession - START")
+	session, err := uc.sessionRepo.Get(ctx, id)
+	if err != nil {
+		const msg = "SessionUseCase.GetSession - uc.sessionRepo.Get > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	if session == nil {
+		const msg = "SessionUseCase.GetSession - uc.sessionRepo.Get(%s) >"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, id)}
+	}
+	uc.logger.Debug("SessionUseCase.GetSession - END")
+	return session, nil
+}
+
+// RenewSession records a heartbeat, the session and all its locks live for another TTL.
+func (uc *SessionUseCase) RenewSession(ctx context.Context, id string) (*domain.Session, error) {
+	uc.logger.Debug("SessionUseCase.RenewSession - START")
+	session, err := uc.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	session.ExpireAt = time.Now().UTC().Add(time.Duration(session.TTL) * time.Second)
+	result, err := uc.sessionRepo.Renew(ctx, session)
+	var notFoundErr *domain.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		const msg = "SessionUseCase.RenewSession(%s) >"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, id)}
+	}
+	if err != nil {
+		const msg = "SessionUseCase.RenewSession - uc.sessionRepo.Renew > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.logger.Debug("SessionUseCase.RenewSession - END")
+	return result, nil
+}
+
+// DeleteSession destroys a session and releases all its locks, it returns the number of released locks.
+func (uc *SessionUseCase) DeleteSession(ctx context.Context, id string) (int, error) {
+	uc.logger.Debug("SessionUseCase.DeleteSession - START")
+	if id == "" {
+		const msg = "SessionUseCase.DeleteSession - id is empty >"
+		return 0, &domain.InputError{Message: msg}
+	}
+	released, err := uc.sessionRepo.Delete(ctx, id)
+	var notFoundErr *domain.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		const msg = "SessionUseCase.DeleteSession(%s) >"
+		return 0, &domain.NotFoundError{Message: fmt.Sprintf(msg, id)}
+	}
+	if err != nil {
+		const msg = "SessionUseCase.DeleteSession - uc.sessionRepo.Delete > %s"
+		return 0, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	const msg = "SessionUseCase.DeleteSession - Session deleted: %s, %d locks released"
+	uc.logger.Info(fmt.Sprintf(msg, id, released))
+	uc.logger.Debug("SessionUseCase.DeleteSession - END")
+	return released, nil
+}
+
+// newRandomID returns a random 128 bit id, hex encoded.
+func newRandomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
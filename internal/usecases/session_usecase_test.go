@@ -0,0 +1,88 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+)
+
+const testSessionID = "test-session"
+
+func TestCreateSessionSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSessionRepository)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Session")).Return(nil)
+
+	uc := NewSessionUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.CreateSession(context.Background(), &domain.SessionInput{TTL: "30s"})
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Len(t, result.ID, 32)
+	assert.Equal(t, int64(30), result.TTL)
+	assert.Equal(t, 30*time.Second, result.ExpireAt.Sub(result.CreatedAt))
+}
+
+func TestRenewSessionSuccess(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSessionRepository)
+	session := &domain.Session{ID: testSessionID, TTL: 30, ExpireAt: time.Now().Add(time.Second)}
+	mockRepo.On("Get", mock.Anything, testSessionID).Return(session, nil)
+	mockRepo.On("Renew", mock.Anything, session).Return(session, nil)
+
+	uc := NewSessionUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.RenewSession(context.Background(), testSessionID)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), result.ExpireAt, time.Second)
+}
+
+func TestRenewSessionExpired(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSessionRepository)
+	mockRepo.On("Get", mock.Anything, testSessionID).Return(nil, nil)
+
+	uc := NewSessionUseCase(mockRepo, mockLogger)
+
+	// Act
+	result, err := uc.RenewSession(context.Background(), testSessionID)
+
+	// Assert
+	mockRepo.AssertNotCalled(t, "Renew")
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.NotFoundError{}, err)
+}
+
+func TestDeleteSessionReleasesLocks(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockSessionRepository)
+	mockRepo.On("Delete", mock.Anything, testSessionID).Return(2, nil)
+
+	uc := NewSessionUseCase(mockRepo, mockLogger)
+
+	// Act
+	released, err := uc.DeleteSession(context.Background(), testSessionID)
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, released)
+}
@@ -0,0 +1,362 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+)
+
+const (
+	// webhookMaxAttempts is the number of times a delivery is tried before it is given up
+	webhookMaxAttempts = 5
+	// webhookRetryBackoff is the wait before the first retry of a delivery, it doubles with every retry
+	webhookRetryBackoff = time.Second
+	// webhookClaimTTL is the time an event is claimed by the replica that delivers it
+	webhookClaimTTL = time.Minute
+	// webhookCacheTTL is the time the registered webhooks are cached for the delivery of events,
+	// webhooks registered or deleted on another replica are seen after it
+	webhookCacheTTL = 30 * time.Second
+)
+
+// WebhookUseCase handles the business logic for webhooks, it delivers the lock events to the matching webhooks.
+// Deliveries are best-effort: pending retries live in memory only and are lost on a restart, and events
+// published while no replica is subscribed are never delivered. Receivers that must not miss a change
+// need to reconcile with the API.
+type WebhookUseCase struct {
+	webhookRepo  domain.WebhookRepository
+	eventRepo    domain.EventRepository
+	client       domain.WebhookClient
+	configured   []*domain.Webhook
+	namespaces   []*domain.Namespace
+	logger       domain.Logger
+	retryBackoff time.Duration
+	// mu guards the cached registered webhooks, they are loaded again once cachedAt is webhookCacheTTL ago
+	mu       sync.Mutex
+	cached   []*domain.Webhook
+	cachedAt time.Time
+}
+
+// NewWebhookUseCase creates a new WebhookUseCase, the configured webhooks are served besides
+// the registered ones and receive the events of the given namespaces.
+func NewWebhookUseCase(
+	webhookRepo domain.WebhookRepository,
+	eventRepo domain.EventRepository,
+	client domain.WebhookClient,
+	configured []*domain.Webhook,
+	namespaces []*domain.Namespace,
+	logger domain.Logger,
+) *WebhookUseCase {
+	return &WebhookUseCase{
+		webhookRepo:  webhookRepo,
+		eventRepo:    eventRepo,
+		client:       client,
+		configured:   configured,
+		namespaces:   namespaces,
+		logger:       logger,
+		retryBackoff: webhookRetryBackoff,
+	}
+}
+
+// CreateWebhook registers a new webhook, it is returned with its secret only once.
+func (uc *WebhookUseCase) CreateWebhook(ctx context.Context, input *domain.WebhookInput) (*domain.Webhook, error) {
+	uc.logger.Debug("WebhookUseCase.CreateWebhook - START")
+	if !domain.HasNamespace(uc.namespaces, input.Namespace) {
+		return nil, domain.NewValidationError("WEBHOOK_INVALID_NAMESPACE", fmt.Sprintf("namespace '%s' is not configured", input.Namespace))
+	}
+	id, err := newRandomID()
+	if err != nil {
+		const msg = "WebhookUseCase.CreateWebhook - newRandomID > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	secret := input.Secret
+	if secret == "" {
+		if secret, err = newRandomID(); err != nil {
+			const msg = "WebhookUseCase.CreateWebhook - newRandomID > %s"
+			return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+		}
+	}
+	webhook := &domain.Webhook{
+		ID:         id,
+		URL:        input.URL,
+		KeyPattern: input.KeyPattern,
+		Namespace:  input.Namespace,
+		Events:     input.Events,
+		Secret:     secret,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := uc.webhookRepo.Create(ctx, webhook); err != nil {
+		const msg = "WebhookUseCase.CreateWebhook - uc.webhookRepo.Create > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.invalidate()
+	const msg = "WebhookUseCase.CreateWebhook - Webhook created > %s for %s"
+	uc.logger.Info(fmt.Sprintf(msg, webhook.ID, webhook.URL))
+	uc.logger.Debug("WebhookUseCase.CreateWebhook - END")
+	return webhook, nil
+}
+
+// GetWebhook retrieves a configured or registered webhook without its secret.
+func (uc *WebhookUseCase) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	uc.logger.Debug("WebhookUseCase.GetWebhook - START")
+	webhook, err := uc.webhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	uc.logger.Debug("WebhookUseCase.GetWebhook - END")
+	return redacted(webhook), nil
+}
+
+// ListWebhooks retrieves the configured and registered webhooks without their secrets.
+func (uc *WebhookUseCase) ListWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	uc.logger.Debug("WebhookUseCase.ListWebhooks - START")
+	webhooks, err := uc.webhooks(ctx)
+	if err != nil {
+		const msg = "WebhookUseCase.ListWebhooks - uc.webhooks > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	result := make([]*domain.Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		result = append(result, redacted(webhook))
+	}
+	uc.logger.Debug("WebhookUseCase.ListWebhooks - END")
+	return result, nil
+}
+
+// DeleteWebhook removes a registered webhook with its delivery log, configured webhooks can not be removed.
+func (uc *WebhookUseCase) DeleteWebhook(ctx context.Context, id string) error {
+	uc.logger.Debug("WebhookUseCase.DeleteWebhook - START")
+	if uc.configuredWebhook(id) != nil {
+		const msg = "WebhookUseCase.DeleteWebhook - webhook %s is declared in the config >"
+		return &domain.InputError{Message: fmt.Sprintf(msg, id)}
+	}
+	err := uc.webhookRepo.Delete(ctx, id)
+	uc.invalidate()
+	var notFoundErr *domain.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return err
+	}
+	if err != nil {
+		const msg = "WebhookUseCase.DeleteWebhook - uc.webhookRepo.Delete > %s"
+		return &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	const msg = "WebhookUseCase.DeleteWebhook - Webhook deleted: %s"
+	uc.logger.Info(fmt.Sprintf(msg, id))
+	uc.logger.Debug("WebhookUseCase.DeleteWebhook - END")
+	return nil
+}
+
+// GetDeliveries retrieves the logged delivery attempts of a webhook, the latest first.
+func (uc *WebhookUseCase) GetDeliveries(ctx context.Context, id string) ([]*domain.WebhookDelivery, error) {
+	uc.logger.Debug("WebhookUseCase.GetDeliveries - START")
+	if _, err := uc.webhook(ctx, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := uc.webhookRepo.Deliveries(ctx, id)
+	if err != nil {
+		const msg = "WebhookUseCase.GetDeliveries - uc.webhookRepo.Deliveries > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	uc.logger.Debug("WebhookUseCase.GetDeliveries - END")
+	return deliveries, nil
+}
+
+// Start subscribes to the lock events of all namespaces and delivers them to the matching webhooks until ctx is done.
+func (uc *WebhookUseCase) Start(ctx context.Context) error {
+	for _, ns := range uc.namespaces {
+		events, err := uc.eventRepo.Subscribe(domain.WithNamespace(ctx, ns), "")
+		if err != nil {
+			const msg = "WebhookUseCase.Start - uc.eventRepo.Subscribe(%s) > %w"
+			return fmt.Errorf(msg, ns.Name, err)
+		}
+		go func(namespace string) {
+			for event := range events {
+				uc.dispatch(ctx, namespace, event)
+			}
+		}(ns.Name)
+	}
+	return nil
+}
+
+// dispatch delivers the event to every matching webhook, unless another replica claimed its delivery already.
+func (uc *WebhookUseCase) dispatch(ctx context.Context, namespace string, event *domain.LockEvent) {
+	webhooks, err := uc.cachedWebhooks(ctx)
+	if err != nil {
+		const msg = "WebhookUseCase.dispatch - uc.cachedWebhooks > %s"
+		uc.logger.Error(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	for _, webhook := range webhooks {
+		if !webhook.Matches(namespace, event) {
+			continue
+		}
+		claimed, err := uc.eventRepo.Claim(ctx, eventID(webhook.ID, namespace, event), webhookClaimTTL)
+		if err != nil {
+			const msg = "WebhookUseCase.dispatch - uc.eventRepo.Claim > %s"
+			uc.logger.Error(fmt.Sprintf(msg, err.Error()))
+			continue
+		}
+		if claimed {
+			go uc.deliver(ctx, webhook, namespace, event)
+		}
+	}
+}
+
+// deliver posts the signed event to the webhook, attempts that failed with a network error or a 5xx status
+// are retried with an exponential backoff, other statuses are not retried. Every attempt is added to the
+// delivery log of the webhook.
+func (uc *WebhookUseCase) deliver(ctx context.Context, webhook *domain.Webhook, namespace string, event *domain.LockEvent) {
+	id, err := newRandomID()
+	if err != nil {
+		const msg = "WebhookUseCase.deliver - newRandomID > %s"
+		uc.logger.Error(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	body, err := json.Marshal(&domain.WebhookPayload{Delivery: id, Webhook: webhook.ID, Namespace: namespace, Event: event})
+	if err != nil {
+		const msg = "WebhookUseCase.deliver - json.Marshal > %s"
+		uc.logger.Error(fmt.Sprintf(msg, err.Error()))
+		return
+	}
+	headers := map[string]string{
+		"X-Webhook-Id":        webhook.ID,
+		"X-Webhook-Delivery":  id,
+		"X-Webhook-Event":     event.Type,
+		"X-Webhook-Signature": "sha256=" + sign(webhook.Secret, body),
+	}
+
+	backoff := uc.retryBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		status, err := uc.client.Post(ctx, webhook.URL, headers, body)
+		delivery := &domain.WebhookDelivery{
+			ID:         id,
+			WebhookID:  webhook.ID,
+			EventType:  event.Type,
+			Key:        event.Key,
+			Attempt:    attempt,
+			StatusCode: status,
+			Success:    err == nil && status >= 200 && status < 300,
+			At:         time.Now().UTC(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if logErr := uc.webhookRepo.LogDelivery(ctx, delivery); logErr != nil {
+			const msg = "WebhookUseCase.deliver - uc.webhookRepo.LogDelivery > %s"
+			uc.logger.Warn(fmt.Sprintf(msg, logErr.Error()))
+		}
+		if delivery.Success {
+			return
+		}
+		const msg = "WebhookUseCase.deliver - delivery %s to %s failed at attempt %d > %d %s"
+		uc.logger.Warn(fmt.Sprintf(msg, id, webhook.ID, attempt, status, delivery.Error))
+		// the receiver rejected the delivery, sending it again would not change that
+		if err == nil && status < 500 {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// webhook returns the configured or registered webhook with the id, it returns a *domain.NotFoundError if there is none.
+func (uc *WebhookUseCase) webhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	if webhook := uc.configuredWebhook(id); webhook != nil {
+		return webhook, nil
+	}
+	webhook, err := uc.webhookRepo.Get(ctx, id)
+	if err != nil {
+		const msg = "WebhookUseCase.webhook - uc.webhookRepo.Get > %s"
+		return nil, &domain.InternalError{Message: fmt.Sprintf(msg, err.Error())}
+	}
+	if webhook == nil {
+		const msg = "WebhookUseCase.webhook - uc.webhookRepo.Get(%s) >"
+		return nil, &domain.NotFoundError{Message: fmt.Sprintf(msg, id)}
+	}
+	return webhook, nil
+}
+
+// webhooks returns the configured and the registered webhooks.
+func (uc *WebhookUseCase) webhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	registered, err := uc.webhookRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]*domain.Webhook{}, uc.configured...), registered...), nil
+}
+
+// cachedWebhooks returns the configured and the registered webhooks like webhooks,
+// the registered ones are cached for webhookCacheTTL.
+func (uc *WebhookUseCase) cachedWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.cached == nil || time.Since(uc.cachedAt) >= webhookCacheTTL {
+		webhooks, err := uc.webhooks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		uc.cached, uc.cachedAt = webhooks, time.Now()
+	}
+	return uc.cached, nil
+}
+
+// invalidate drops the cached webhooks, so the next event loads them again.
+func (uc *WebhookUseCase) invalidate() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.cached = nil
+}
+
+// configuredWebhook returns the webhook with the id declared in the config or nil.
+func (uc *WebhookUseCase) configuredWebhook(id string) *domain.Webhook {
+	for _, webhook := range uc.configured {
+		if webhook.ID == id {
+			return webhook
+		}
+	}
+	return nil
+}
+
+// redacted returns a copy of the webhook without its secret.
+func redacted(webhook *domain.Webhook) *domain.Webhook {
+	result := *webhook
+	result.Secret = ""
+	return &result
+}
+
+// sign returns the hex encoded HMAC-SHA256 of body with the secret.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// eventID identifies the handling of an event by a consumer across replicas. The time of an expired event
+// is taken by each replica on its own, so it only counts to the second, or not at all if the fencing
+// token of the expired lock is known.
+func eventID(consumer string, namespace string, event *domain.LockEvent) string {
+	at := event.At
+	if event.Type == domain.LockEventExpired {
+		at = at.Truncate(time.Second)
+		if event.FencingToken != 0 {
+			at = time.Time{}
+		}
+	}
+	id := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s", consumer, namespace, event.Type, event.Key, event.Owner, event.FencingToken, at.Format(time.RFC3339Nano))
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
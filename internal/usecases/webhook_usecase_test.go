@@ -0,0 +1,266 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/tyriis/go-locking-service/internal/domain"
+	"github.com/tyriis/go-locking-service/internal/infrastructure"
+	"github.com/tyriis/go-locking-service/internal/repositories"
+)
+
+const testWebhookURL = "https://chatops.example.com/locks"
+
+var testNamespaces = []*domain.Namespace{{Name: domain.DefaultNamespace}}
+
+var testConfiguredWebhook = &domain.Webhook{
+	ID:         "chatops",
+	URL:        testWebhookURL,
+	KeyPattern: "deploy/*",
+	Events:     []string{domain.LockEventAcquired, domain.LockEventExpired},
+	Secret:     "s3cret",
+}
+
+func TestCreateWebhookGeneratesSecret(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Webhook")).Return(nil)
+
+	uc := NewWebhookUseCase(mockRepo, nil, nil, nil, testNamespaces, mockLogger)
+
+	// Act
+	result, err := uc.CreateWebhook(context.Background(), &domain.WebhookInput{URL: testWebhookURL, KeyPattern: "*"})
+
+	// Assert
+	mockRepo.AssertExpectations(t)
+	assert.NoError(t, err)
+	assert.Len(t, result.ID, 32)
+	assert.Len(t, result.Secret, 32)
+	assert.NotEqual(t, result.ID, result.Secret)
+}
+
+func TestCreateWebhookUnknownNamespace(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+
+	uc := NewWebhookUseCase(mockRepo, nil, nil, nil, testNamespaces, mockLogger)
+
+	// Act
+	result, err := uc.CreateWebhook(context.Background(), &domain.WebhookInput{URL: testWebhookURL, KeyPattern: "*", Namespace: "team-a"})
+
+	// Assert
+	mockRepo.AssertNotCalled(t, "Create")
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.ValidationError{}, err)
+}
+
+func TestListWebhooksRedactsSecrets(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	registered := &domain.Webhook{ID: "registered", URL: testWebhookURL, KeyPattern: "*", Secret: "other"}
+	mockRepo.On("List", mock.Anything).Return([]*domain.Webhook{registered}, nil)
+
+	uc := NewWebhookUseCase(mockRepo, nil, nil, []*domain.Webhook{testConfiguredWebhook}, testNamespaces, mockLogger)
+
+	// Act
+	result, err := uc.ListWebhooks(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	for _, webhook := range result {
+		assert.Empty(t, webhook.Secret)
+	}
+	assert.Equal(t, "s3cret", testConfiguredWebhook.Secret)
+}
+
+func TestDeleteConfiguredWebhook(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+
+	uc := NewWebhookUseCase(mockRepo, nil, nil, []*domain.Webhook{testConfiguredWebhook}, testNamespaces, mockLogger)
+
+	// Act
+	err := uc.DeleteWebhook(context.Background(), testConfiguredWebhook.ID)
+
+	// Assert
+	mockRepo.AssertNotCalled(t, "Delete")
+	assert.IsType(t, &domain.InputError{}, err)
+}
+
+func TestGetDeliveriesNotFound(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("Get", mock.Anything, "unknown").Return(nil, nil)
+
+	uc := NewWebhookUseCase(mockRepo, nil, nil, nil, testNamespaces, mockLogger)
+
+	// Act
+	result, err := uc.GetDeliveries(context.Background(), "unknown")
+
+	// Assert
+	mockRepo.AssertNotCalled(t, "Deliveries")
+	assert.Nil(t, result)
+	assert.IsType(t, &domain.NotFoundError{}, err)
+}
+
+func TestDispatchDeliversSignedEvent(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("List", mock.Anything).Return([]*domain.Webhook{}, nil)
+	mockRepo.On("LogDelivery", mock.Anything, mock.Anything).Return(nil)
+	mockEvents := new(repositories.MockEventRepository)
+	mockEvents.On("Claim", mock.Anything, mock.Anything, webhookClaimTTL).Return(true, nil)
+	mockClient := new(infrastructure.MockWebhookClient)
+	delivered := make(chan []byte, 1)
+	var headers map[string]string
+	mockClient.On("Post", mock.Anything, testWebhookURL, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			headers = args.Get(2).(map[string]string)
+			delivered <- args.Get(3).([]byte)
+		}).
+		Return(204, nil)
+
+	uc := NewWebhookUseCase(mockRepo, mockEvents, mockClient, []*domain.Webhook{testConfiguredWebhook}, testNamespaces, mockLogger)
+	event := &domain.LockEvent{Type: domain.LockEventAcquired, Key: "deploy/api", Owner: testOwnerValue, At: time.Now().UTC()}
+
+	// Act
+	uc.dispatch(context.Background(), domain.DefaultNamespace, &domain.LockEvent{Type: domain.LockEventAcquired, Key: "build/api"})
+	uc.dispatch(context.Background(), domain.DefaultNamespace, &domain.LockEvent{Type: domain.LockEventReleased, Key: "deploy/api"})
+	uc.dispatch(context.Background(), domain.DefaultNamespace, event)
+
+	// Assert
+	select {
+	case body := <-delivered:
+		var payload domain.WebhookPayload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, "chatops", payload.Webhook)
+		assert.Equal(t, "deploy/api", payload.Event.Key)
+		assert.Equal(t, "sha256="+sign("s3cret", body), headers["X-Webhook-Signature"])
+		assert.Equal(t, domain.LockEventAcquired, headers["X-Webhook-Event"])
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+	mockEvents.AssertNumberOfCalls(t, "Claim", 1)
+}
+
+func TestDispatchClaimedByAnotherReplica(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("List", mock.Anything).Return([]*domain.Webhook{}, nil)
+	mockEvents := new(repositories.MockEventRepository)
+	mockEvents.On("Claim", mock.Anything, mock.Anything, webhookClaimTTL).Return(false, nil)
+	mockClient := new(infrastructure.MockWebhookClient)
+
+	uc := NewWebhookUseCase(mockRepo, mockEvents, mockClient, []*domain.Webhook{testConfiguredWebhook}, testNamespaces, mockLogger)
+
+	// Act
+	uc.dispatch(context.Background(), domain.DefaultNamespace, &domain.LockEvent{Type: domain.LockEventExpired, Key: "deploy/api"})
+
+	// Assert
+	mockEvents.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "Post")
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	var logged []*domain.WebhookDelivery
+	mockRepo.On("LogDelivery", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { logged = append(logged, args.Get(1).(*domain.WebhookDelivery)) }).
+		Return(nil)
+	mockClient := new(infrastructure.MockWebhookClient)
+	mockClient.On("Post", mock.Anything, testWebhookURL, mock.Anything, mock.Anything).Return(0, errors.New("connection refused")).Once()
+	mockClient.On("Post", mock.Anything, testWebhookURL, mock.Anything, mock.Anything).Return(502, nil).Once()
+	mockClient.On("Post", mock.Anything, testWebhookURL, mock.Anything, mock.Anything).Return(200, nil).Once()
+
+	uc := NewWebhookUseCase(mockRepo, nil, mockClient, nil, testNamespaces, mockLogger)
+	uc.retryBackoff = time.Millisecond
+
+	// Act
+	uc.deliver(context.Background(), testConfiguredWebhook, domain.DefaultNamespace, &domain.LockEvent{Type: domain.LockEventAcquired, Key: "deploy/api"})
+
+	// Assert
+	mockClient.AssertExpectations(t)
+	assert.Len(t, logged, 3)
+	assert.Equal(t, "connection refused", logged[0].Error)
+	assert.Equal(t, 502, logged[1].StatusCode)
+	assert.False(t, logged[1].Success)
+	assert.True(t, logged[2].Success)
+	assert.Equal(t, 3, logged[2].Attempt)
+	assert.Equal(t, logged[0].ID, logged[2].ID)
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("LogDelivery", mock.Anything, mock.Anything).Return(nil)
+	mockClient := new(infrastructure.MockWebhookClient)
+	mockClient.On("Post", mock.Anything, testWebhookURL, mock.Anything, mock.Anything).Return(500, nil)
+
+	uc := NewWebhookUseCase(mockRepo, nil, mockClient, nil, testNamespaces, mockLogger)
+	uc.retryBackoff = time.Millisecond
+
+	// Act
+	uc.deliver(context.Background(), testConfiguredWebhook, domain.DefaultNamespace, &domain.LockEvent{Type: domain.LockEventAcquired, Key: "deploy/api"})
+
+	// Assert
+	mockClient.AssertNumberOfCalls(t, "Post", webhookMaxAttempts)
+	mockRepo.AssertNumberOfCalls(t, "LogDelivery", webhookMaxAttempts)
+}
+
+func TestDispatchCachesWebhooks(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("List", mock.Anything).Return([]*domain.Webhook{}, nil)
+	mockRepo.On("Delete", mock.Anything, "abc").Return(nil)
+	mockEvents := new(repositories.MockEventRepository)
+	mockClient := new(infrastructure.MockWebhookClient)
+
+	uc := NewWebhookUseCase(mockRepo, mockEvents, mockClient, nil, testNamespaces, mockLogger)
+	event := &domain.LockEvent{Type: domain.LockEventAcquired, Key: "deploy/api"}
+
+	// Act
+	uc.dispatch(context.Background(), domain.DefaultNamespace, event)
+	uc.dispatch(context.Background(), domain.DefaultNamespace, event)
+	assert.NoError(t, uc.DeleteWebhook(context.Background(), "abc"))
+	uc.dispatch(context.Background(), domain.DefaultNamespace, event)
+
+	// Assert
+	mockRepo.AssertNumberOfCalls(t, "List", 2)
+}
+
+func TestDeliverDoesNotRetryRejection(t *testing.T) {
+	// Arrange
+	mockLogger := infrastructure.NewMockLogger()
+	mockRepo := new(repositories.MockWebhookRepository)
+	mockRepo.On("LogDelivery", mock.Anything, mock.Anything).Return(nil)
+	mockClient := new(infrastructure.MockWebhookClient)
+	mockClient.On("Post", mock.Anything, testWebhookURL, mock.Anything, mock.Anything).Return(400, nil)
+
+	uc := NewWebhookUseCase(mockRepo, nil, mockClient, nil, testNamespaces, mockLogger)
+	uc.retryBackoff = time.Millisecond
+
+	// Act
+	uc.deliver(context.Background(), testConfiguredWebhook, domain.DefaultNamespace, &domain.LockEvent{Type: domain.LockEventAcquired, Key: "deploy/api"})
+
+	// Assert
+	mockClient.AssertNumberOfCalls(t, "Post", 1)
+	mockRepo.AssertNumberOfCalls(t, "LogDelivery", 1)
+}